@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type AggregateDocument struct {
+	SchemaVersion int                `json:"schemaVersion"`
+	BaseURL       string             `json:"baseUrl"`
+	Requests      []AggregateRequest `json:"requests"`
+}
+
+type AggregateRequest struct {
+	Name        string            `json:"name"`
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	Handler     string            `json:"handler"`
+	File        string            `json:"file"`
+	Tags        []string          `json:"tags,omitempty"`
+	Auth        string            `json:"auth"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	QueryParams map[string]string `json:"queryParams,omitempty"`
+	Body        interface{}       `json:"body,omitempty"`
+	Docs        string            `json:"docs,omitempty"`
+}
+
+const AggregateSchemaVersion = 1
+
+// GenerateAggregate writes a single JSON document describing every request as it is
+// rendered into the collection, for custom importers and snapshot tests
+func (g *BrunoGenerator) GenerateAggregate(outputPath string, routes []*Route) error {
+	doc, err := g.buildAggregate(routes)
+	if err != nil {
+		return err
+	}
+
+	jsonBytes, err := json.MarshalIndent(doc, "", JSONOutputIndent)
+	if err != nil {
+		return err
+	}
+
+	// Make sure the parent directory exists.
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(outputPath, jsonBytes, 0644)
+}
+
+// buildAggregate resolves each route into its final rendered request
+func (g *BrunoGenerator) buildAggregate(routes []*Route) (*AggregateDocument, error) {
+	doc := &AggregateDocument{
+		SchemaVersion: AggregateSchemaVersion,
+		BaseURL:       g.Config.BaseURL,
+		Requests:      []AggregateRequest{},
+	}
+
+	// Files are named the same way GenerateCollection names them
+	g.assignFileNames(g.orderRoutes(routes))
+
+	for _, route := range routes {
+		docs, err := g.generateDocs(route)
+		if err != nil {
+			return nil, err
+		}
+
+		request := AggregateRequest{
+			Name:    route.Name,
+			Method:  strings.ToUpper(route.Method),
+			URL:     g.requestURL(route),
+			Handler: route.Handler,
+			File:    g.requestFilePath(route),
+			Tags:    route.Tags,
+			Auth:    "none",
+			Docs:    strings.TrimSpace(docs),
+		}
+
+		if route.Auth != "" && !isQueryAPIKey(route) {
+			request.Auth = route.Auth
+		}
+
+		for _, header := range route.Headers {
+			if request.Headers == nil {
+				request.Headers = make(map[string]string)
+			}
+			request.Headers[header.Name] = header.Value
+		}
+
+		for _, param := range route.QueryParams {
+			if request.QueryParams == nil {
+				request.QueryParams = make(map[string]string)
+			}
+			request.QueryParams[param.Name] = queryParamExample(param)
+		}
+
+		if route.Auth == "apikey" {
+			value := "{{" + APIKeyVariable + "}}"
+			if isQueryAPIKey(route) {
+				if request.QueryParams == nil {
+					request.QueryParams = make(map[string]string)
+				}
+				request.QueryParams[apiKeyName(route)] = value
+			} else {
+				if request.Headers == nil {
+					request.Headers = make(map[string]string)
+				}
+				request.Headers[apiKeyName(route)] = value
+			}
+		}
+
+		if route.RequestBody != nil {
+			request.Body = exampleBody(route.RequestBody, g.newRouteExampleOptions(route))
+		}
+
+		doc.Requests = append(doc.Requests, request)
+	}
+
+	return doc, nil
+}
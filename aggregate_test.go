@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateAggregateRendersRequests(t *testing.T) {
+	routes := []*Route{{
+		Name:        "Create User",
+		Method:      "post",
+		Path:        "/users/:id",
+		Handler:     "CreateUser",
+		SourceFile:  "handlers/users.go",
+		Tags:        []string{"users"},
+		Auth:        "apikey",
+		AuthOptions: AuthOptions{In: "query", Key: "key"},
+		Description: "Creates a user",
+		Headers:     []RequestHeader{{Name: "X-Tenant", Value: "acme"}},
+		RequestBody: &RequestBody{TypeName: "CreateUserRequest", Fields: []RequestBodyField{{Name: "Name", Type: "string", JSONName: "name"}}},
+	}}
+
+	path := filepath.Join(t.TempDir(), "out", "requests.json")
+	if err := NewBrunoGenerator(t.TempDir(), "https://api.example.com").GenerateAggregate(path, routes); err != nil {
+		t.Fatalf("GenerateAggregate() error = %v", err)
+	}
+
+	var doc AggregateDocument
+	if err := json.Unmarshal([]byte(readFile(t, path)), &doc); err != nil {
+		t.Fatalf("aggregate is not JSON: %v", err)
+	}
+	want := AggregateDocument{
+		SchemaVersion: AggregateSchemaVersion,
+		BaseURL:       "https://api.example.com",
+		Requests: []AggregateRequest{{
+			Name:        "Create User",
+			Method:      "POST",
+			URL:         "{{baseUrl}}/users/:id?key={{" + APIKeyVariable + "}}",
+			Handler:     "CreateUser",
+			File:        "users/create-user.bru",
+			Tags:        []string{"users"},
+			Auth:        "none", // the key travels in the query, not an auth block
+			Headers:     map[string]string{"X-Tenant": "acme"},
+			QueryParams: map[string]string{"key": "{{" + APIKeyVariable + "}}"},
+			Body:        map[string]interface{}{"name": ""},
+			Docs:        "Creates a user",
+		}},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("aggregate =\n%+v\nwant\n%+v", doc, want)
+	}
+}
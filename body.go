@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BodyRenderer renders example request bodies for one content type
+type BodyRenderer interface {
+	BlockName() string // Bruno block holding the body, e.g. body:json
+	BodyMode() string  // Value of body: in the request block
+	MIMEType() string  // Content type advertised in OpenAPI documents
+	Render(requestBody *RequestBody, opts *exampleOptions) (string, error)
+}
+
+// DefaultContentType is used for routes without a @bodytype annotation.
+const DefaultContentType = "json"
+
+// GraphQLContentType is the @bodytype of routes sending a GraphQL query, with the @body
+// fields as its variables
+const GraphQLContentType = "graphql"
+
+// bodyRenderers maps the @bodytype values onto their renderers
+var bodyRenderers = map[string]BodyRenderer{
+	"json":      jsonBodyRenderer{},
+	"xml":       xmlBodyRenderer{},
+	"form":      formBodyRenderer{},
+	"multipart": multipartBodyRenderer{},
+	"graphql":   graphqlBodyRenderer{},
+}
+
+// bodyRendererFor returns the renderer for a route's content type, falling back to JSON
+func bodyRendererFor(route *Route) BodyRenderer {
+	contentType := route.ContentType
+	if contentType == "" {
+		contentType = DefaultContentType
+	}
+
+	renderer, ok := bodyRenderers[contentType]
+	if !ok {
+		getLogger().Warn(fmt.Sprintf("Unsupported body type %q on handler %s, using %s", contentType, route.Handler, DefaultContentType))
+		return bodyRenderers[DefaultContentType]
+	}
+	return renderer
+}
+
+type jsonBodyRenderer struct{}
+
+func (jsonBodyRenderer) BlockName() string { return "body:json" }
+func (jsonBodyRenderer) BodyMode() string  { return "json" }
+func (jsonBodyRenderer) MIMEType() string  { return "application/json" }
+
+// Render marshals the example body as indented JSON
+func (jsonBodyRenderer) Render(requestBody *RequestBody, opts *exampleOptions) (string, error) {
+	jsonBytes, err := json.MarshalIndent(exampleBody(requestBody, opts), "", JSONOutputIndent)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+type graphqlBodyRenderer struct{}
+
+func (graphqlBodyRenderer) BlockName() string { return "body:graphql:vars" }
+func (graphqlBodyRenderer) BodyMode() string  { return "graphql" }
+func (graphqlBodyRenderer) MIMEType() string  { return "application/json" }
+
+// Render marshals the example body as the query's variables. The query has a block of its
+// own, see generateRequestBodySection.
+func (graphqlBodyRenderer) Render(requestBody *RequestBody, opts *exampleOptions) (string, error) {
+	return jsonBodyRenderer{}.Render(requestBody, opts)
+}
+
+type xmlBodyRenderer struct{}
+
+func (xmlBodyRenderer) BlockName() string { return "body:xml" }
+func (xmlBodyRenderer) BodyMode() string  { return "xml" }
+func (xmlBodyRenderer) MIMEType() string  { return "application/xml" }
+
+// Render writes the example body as XML, naming elements after their xml tags
+// (or the Go field name, like encoding/xml does)
+func (xmlBodyRenderer) Render(requestBody *RequestBody, opts *exampleOptions) (string, error) {
+	var builder strings.Builder
+	writeXMLElement(&builder, xmlRootName(requestBody), requestBody, 0, opts)
+	return strings.TrimSuffix(builder.String(), "\n"), nil
+}
+
+// xmlRootName returns the name set through an XMLName field, or the struct name
+func xmlRootName(requestBody *RequestBody) string {
+	for _, field := range requestBody.Fields {
+		if field.Name == "XMLName" && field.XMLName != "" {
+			return field.XMLName
+		}
+	}
+	return requestBody.TypeName
+}
+
+// writeXMLElement writes one struct as an element: attr fields become attributes,
+// a chardata field becomes its text and the rest become child elements. Once the
+// budget runs out the remaining fields are replaced by a comment, open elements are
+// still closed, and false is returned.
+func writeXMLElement(builder *strings.Builder, name string, requestBody *RequestBody, depth int, opts *exampleOptions) bool {
+	indent := strings.Repeat(JSONOutputIndent, depth)
+
+	truncated := func() bool {
+		builder.WriteString(fmt.Sprintf("%s%s<!-- example body exceeded %d bytes, remaining fields omitted -->\n", indent, JSONOutputIndent, opts.budget.limit))
+		builder.WriteString(fmt.Sprintf("%s</%s>\n", indent, name))
+		return false
+	}
+
+	var attrs strings.Builder
+	text := ""
+	children := []RequestBodyField{}
+	for _, field := range xmlFields(requestBody.Fields) {
+		fieldName := xmlFieldName(field)
+		if fieldName == "-" || field.Inline || field.Name == "XMLName" {
+			continue
+		}
+
+		switch {
+		case field.XMLAttr:
+			value := xmlEscape(formatExampleValue(fieldExampleValue(field)))
+			if !opts.budget.spend(fieldName, value) {
+				builder.WriteString(fmt.Sprintf("%s<%s>\n", indent, name))
+				return truncated()
+			}
+			attrs.WriteString(fmt.Sprintf(" %s=\"%s\"", fieldName, value))
+		case field.XMLCharData:
+			text = xmlEscape(formatExampleValue(fieldExampleValue(field)))
+			if !opts.budget.spend(fieldName, text) {
+				builder.WriteString(fmt.Sprintf("%s<%s>\n", indent, name))
+				return truncated()
+			}
+		default:
+			children = append(children, field)
+		}
+	}
+
+	if len(children) == 0 {
+		builder.WriteString(fmt.Sprintf("%s<%s%s>%s</%s>\n", indent, name, attrs.String(), text, name))
+		return true
+	}
+
+	builder.WriteString(fmt.Sprintf("%s<%s%s>\n", indent, name, attrs.String()))
+	if text != "" {
+		builder.WriteString(fmt.Sprintf("%s%s%s\n", indent, JSONOutputIndent, text))
+	}
+
+	for _, field := range children {
+		fieldName := xmlFieldName(field)
+
+		count := 1
+		if field.Type == "array" && depth == 0 && opts.itemCount > 1 {
+			count = opts.itemCount
+		}
+
+		for i := 0; i < count; i++ {
+			// encoding/xml can't marshal maps, so their values are never written as elements
+			if field.Nested != nil && field.Type != "map" {
+				if !writeXMLElement(builder, fieldName, field.Nested, depth+1, opts) {
+					builder.WriteString(fmt.Sprintf("%s</%s>\n", indent, name))
+					return false
+				}
+				continue
+			}
+
+			value := xmlEscape(formatExampleValue(fieldExampleValue(field)))
+			if field.Type == "array" {
+				value = xmlEscape(formatExampleValue(defaultValueForType(field.ElemType)))
+			}
+
+			if !opts.budget.spend(fieldName, value) {
+				return truncated()
+			}
+			builder.WriteString(fmt.Sprintf("%s%s<%s>%s</%s>\n", indent, JSONOutputIndent, fieldName, value, fieldName))
+		}
+	}
+
+	builder.WriteString(fmt.Sprintf("%s</%s>\n", indent, name))
+	return true
+}
+
+// xmlFields promotes the fields of embedded structs without an xml name, which encoding/xml
+// flattens into the parent even when their json name nests them in JSON
+func xmlFields(fields []RequestBodyField) []RequestBodyField {
+	promoted := []RequestBodyField{}
+	for _, field := range fields {
+		if field.Embedded && field.XMLName == "" && field.Nested != nil {
+			promoted = append(promoted, xmlFields(field.Nested.Fields)...)
+			continue
+		}
+		promoted = append(promoted, field)
+	}
+	return promoted
+}
+
+// xmlFieldName returns the element or attribute name for a field
+func xmlFieldName(field RequestBodyField) string {
+	if field.XMLName != "" {
+		return field.XMLName
+	}
+	return field.Name
+}
+
+// xmlEscape escapes text for use in element content and attribute values
+func xmlEscape(text string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(text))
+	return escaped.String()
+}
+
+type formBodyRenderer struct{}
+
+func (formBodyRenderer) BlockName() string { return "body:form-urlencoded" }
+func (formBodyRenderer) BodyMode() string  { return "formUrlEncoded" }
+func (formBodyRenderer) MIMEType() string  { return "application/x-www-form-urlencoded" }
+
+// Render writes one key: value line per field
+func (formBodyRenderer) Render(requestBody *RequestBody, opts *exampleOptions) (string, error) {
+	return renderFormFields(requestBody, opts, nil), nil
+}
+
+type multipartBodyRenderer struct{}
+
+func (multipartBodyRenderer) BlockName() string { return "body:multipart-form" }
+func (multipartBodyRenderer) BodyMode() string  { return "multipartForm" }
+func (multipartBodyRenderer) MIMEType() string  { return "multipart/form-data" }
+
+// MultipartFileValue is the placeholder written for uploaded file fields, Bruno's file picker syntax.
+const MultipartFileValue = "@file()"
+
+// Render writes one key: value line per field like form bodies, with uploaded files
+// (multipart.FileHeader fields) left for a file to be picked in Bruno
+func (multipartBodyRenderer) Render(requestBody *RequestBody, opts *exampleOptions) (string, error) {
+	files := make(map[string]bool)
+	for _, field := range requestBody.Fields {
+		if field.Type == "multipart.FileHeader" || field.ElemType == "multipart.FileHeader" {
+			files[formFieldName(field)] = true
+		}
+	}
+	return renderFormFields(requestBody, opts, files), nil
+}
+
+// renderFormFields writes the fields of a form or multipart body. These bodies are flat,
+// so nested structs always use dotted keys, array elements repeat their key and fields are
+// named by their form tag. Keys in files get the file placeholder instead of a value.
+func renderFormFields(requestBody *RequestBody, opts *exampleOptions, files map[string]bool) string {
+	flatOpts := *opts
+	flatOpts.flatten = true
+	flatOpts.formNames = true
+	body := exampleBody(requestBody, &flatOpts)
+
+	keys := make([]string, 0, len(body))
+	for key := range body {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	line := func(key string, value interface{}) string {
+		if files[key] {
+			return fmt.Sprintf("%s: %s", key, MultipartFileValue)
+		}
+		return strings.TrimSpace(fmt.Sprintf("%s: %s", key, formatExampleValue(value)))
+	}
+
+	lines := []string{}
+	for _, key := range keys {
+		if items, ok := body[key].([]interface{}); ok {
+			// Arrays of files have no example elements, but still need one file to upload
+			if len(items) == 0 && files[key] {
+				items = []interface{}{nil}
+			}
+			for _, item := range items {
+				lines = append(lines, line(key, item))
+			}
+			continue
+		}
+		lines = append(lines, line(key, body[key]))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// wireName returns the name a field is sent under for a body content type: its form tag
+// for form and multipart bodies, its xml name for XML and its json name otherwise
+func wireName(field RequestBodyField, contentType string) string {
+	switch contentType {
+	case "form", "multipart":
+		return formFieldName(field)
+	case "xml":
+		return xmlFieldName(field)
+	}
+	return field.JSONName
+}
+
+// formatExampleValue renders an example value as plain text
+func formatExampleValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		jsonBytes, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(jsonBytes)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import "testing"
+
+func TestXMLBodyRendererAttrAndCharData(t *testing.T) {
+	line := &RequestBody{
+		TypeName: "InvoiceLine",
+		Fields: []RequestBodyField{
+			{Name: "SKU", Type: "string", XMLName: "sku", XMLAttr: true},
+			{Name: "Amount", Type: "int", XMLCharData: true},
+		},
+	}
+	invoice := &RequestBody{
+		TypeName: "Invoice",
+		Fields: []RequestBodyField{
+			{Name: "XMLName", Type: "Name", XMLName: "invoice"},
+			{Name: "ID", Type: "string", XMLName: "id", XMLAttr: true},
+			{Name: "Lines", Type: "array", ElemType: "InvoiceLine", XMLName: "line", Nested: line},
+			{Name: "Secret", Type: "string", XMLName: "-"},
+		},
+	}
+
+	got, err := xmlBodyRenderer{}.Render(invoice, &exampleOptions{budget: newBodyBudget(0), itemCount: 1})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	want := "<invoice id=\"\">\n  <line sku=\"\">0</line>\n</invoice>"
+	if got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestXMLBodyRendererFlattensNamedEmbeddedStructs(t *testing.T) {
+	inner := &RequestBody{TypeName: "Inner", Fields: []RequestBodyField{
+		{Name: "Note", Type: "string", JSONName: "note", XMLName: "note"},
+	}}
+	order := &RequestBody{
+		TypeName: "Order",
+		Fields: []RequestBodyField{
+			{Name: "Inner", Type: "Inner", JSONName: "inner", Embedded: true, Nested: inner},
+			{Name: "ID", Type: "string", JSONName: "id", XMLName: "id"},
+		},
+	}
+
+	got, err := xmlBodyRenderer{}.Render(order, &exampleOptions{budget: newBodyBudget(0), itemCount: 1})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	want := "<Order>\n  <note></note>\n  <id></id>\n</Order>"
+	if got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestBodyRendererForContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        BodyRenderer
+	}{
+		{"", jsonBodyRenderer{}},
+		{"json", jsonBodyRenderer{}},
+		{"xml", xmlBodyRenderer{}},
+		{"form", formBodyRenderer{}},
+		{"yaml", jsonBodyRenderer{}}, // unsupported types fall back to JSON
+	}
+
+	for _, tt := range tests {
+		if got := bodyRendererFor(&Route{Handler: "CreateUser", ContentType: tt.contentType}); got != tt.want {
+			t.Errorf("bodyRendererFor(%q) = %T, want %T", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestJSONBodyRendererRendersNestedObjects(t *testing.T) {
+	got, err := jsonBodyRenderer{}.Render(addressBody(), &exampleOptions{budget: newBodyBudget(0), itemCount: 1})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	want := "{\n  \"address\": {\n    \"city\": \"\",\n    \"geo\": {\n      \"lat\": 0,\n      \"lng\": 0\n    }\n  },\n  \"name\": \"\"\n}"
+	if got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestFormBodyRendererFlattensFields(t *testing.T) {
+	body := addressBody()
+	body.Fields = append(body.Fields, RequestBodyField{Name: "Tags", Type: "array", ElemType: "string", JSONName: "tags"})
+
+	got, err := formBodyRenderer{}.Render(body, &exampleOptions{budget: newBodyBudget(0), itemCount: 1})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	want := "address.city:\naddress.geo.lat: 0\naddress.geo.lng: 0\nname:\ntags:"
+	if got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestMultipartBodyRendererUsesFormNamesAndFiles(t *testing.T) {
+	upload := &RequestBody{
+		TypeName: "Upload",
+		Fields: []RequestBodyField{
+			{Name: "Title", Type: "string", JSONName: "title", FormName: "document_title"},
+			{Name: "Public", Type: "bool", JSONName: "public"},
+			{Name: "File", Type: "multipart.FileHeader", JSONName: "File", FormName: "file"},
+			{Name: "Attachments", Type: "array", ElemType: "multipart.FileHeader", JSONName: "Attachments", FormName: "attachments"},
+		},
+	}
+
+	got, err := multipartBodyRenderer{}.Render(upload, &exampleOptions{budget: newBodyBudget(0), itemCount: 1})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	want := "attachments: @file()\ndocument_title:\nfile: @file()\npublic: false"
+	if got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestWireNameFollowsContentType(t *testing.T) {
+	field := RequestBodyField{Name: "UserName", JSONName: "user_name", FormName: "username"}
+
+	if got := wireName(field, "form"); got != "username" {
+		t.Errorf("form name = %q, want username", got)
+	}
+	if got := wireName(field, "multipart"); got != "username" {
+		t.Errorf("multipart name = %q, want username", got)
+	}
+	if got := wireName(field, "json"); got != "user_name" {
+		t.Errorf("json name = %q, want user_name", got)
+	}
+}
+
+func TestGenerateGraphQLRequestBodySection(t *testing.T) {
+	src := `package main
+
+// @route POST /graphql
+// @bodytype graphql
+// @graphql-query
+// query GetUser($id: ID!) {
+//   user(id: $id) {
+//     name
+//   }
+// }
+// @body GetUserVariables
+func Handler() {}
+`
+	annotations := NewParser().extractAnnotations(parseDocComment(t, src))
+	route := &Route{
+		Method:       "POST",
+		Path:         "/graphql",
+		ContentType:  annotations["bodytype"],
+		GraphQLQuery: annotations["graphql_query"],
+		RequestBody: &RequestBody{TypeName: "GetUserVariables", Fields: []RequestBodyField{
+			{Name: "ID", Type: "string", JSONName: "id"},
+		}},
+	}
+
+	got, err := NewBrunoGenerator(t.TempDir(), "").generateRequestBodySection(route)
+	if err != nil {
+		t.Fatalf("generateRequestBodySection() error = %v", err)
+	}
+	want := `body:graphql {
+  query GetUser($id: ID!) {
+    user(id: $id) {
+      name
+    }
+  }
+}
+
+body:graphql:vars {
+  {
+    "id": ""
+  }
+}`
+	if got != want {
+		t.Errorf("generateRequestBodySection() =\n%s\nwant\n%s", got, want)
+	}
+	if mode := bodyRendererFor(route).BodyMode(); mode != "graphql" {
+		t.Errorf("BodyMode() = %q, want graphql", mode)
+	}
+}
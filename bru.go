@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bruPair is one key: value entry of a .bru dictionary block
+type bruPair struct {
+	Key      string
+	Value    string
+	Disabled bool     // Written as ~key, which Bruno shows as an unchecked entry
+	List     []string // Written as a [ ... ] list instead of Value, e.g. meta tags
+}
+
+// bruDictBlock serializes a dictionary block such as meta, headers or params:query.
+// Returns an empty string when there are no entries, so optional blocks drop out.
+func bruDictBlock(name string, pairs []bruPair) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	lines := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		key := bruKey(pair.Key)
+		if pair.Disabled {
+			key = "~" + key
+		}
+		if pair.List != nil {
+			lines = append(lines, fmt.Sprintf("%s: [\n%s\n]", key, indentBlock(strings.Join(pair.List, "\n"))))
+			continue
+		}
+		lines = append(lines, key+":"+bruValue(pair.Value))
+	}
+
+	return fmt.Sprintf("%s {\n%s\n}", name, indentBlock(strings.Join(lines, "\n")))
+}
+
+// bruKey quotes keys Bruno would otherwise split on, i.e. ones holding a colon,
+// whitespace or a quote
+func bruKey(key string) string {
+	if !strings.ContainsAny(key, ": \t\"") {
+		return key
+	}
+	return `"` + strings.ReplaceAll(key, `"`, `\"`) + `"`
+}
+
+// bruValue formats a value to follow the key's colon. Bruno reads single-line values
+// verbatim up to the end of the line, so quotes, colons and braces need no escaping.
+// Values spanning several lines are wrapped in Bruno's triple-quoted multiline form.
+func bruValue(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	if !strings.Contains(value, "\n") {
+		return " " + value
+	}
+
+	return fmt.Sprintf(" '''\n%s\n'''", indentBlock(value))
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestBruDictBlock(t *testing.T) {
+	block := bruDictBlock("meta", []bruPair{
+		{Key: "name", Value: `Say "hi": {now}`},
+		{Key: "X Trace", Value: "abc"},
+		{Key: "page", Disabled: true},
+		{Key: "note", Value: "first\nsecond"},
+		{Key: "tags", List: []string{"users", "tier:gold"}},
+	})
+
+	expected := "meta {\n" +
+		"  name: Say \"hi\": {now}\n" +
+		"  \"X Trace\": abc\n" +
+		"  ~page:\n" +
+		"  note: '''\n" +
+		"    first\n" +
+		"    second\n" +
+		"  '''\n" +
+		"  tags: [\n" +
+		"    users\n" +
+		"    tier:gold\n" +
+		"  ]\n" +
+		"}"
+	if block != expected {
+		t.Errorf("bruDictBlock() =\n%s\nwant\n%s", block, expected)
+	}
+
+	if block := bruDictBlock("headers", nil); block != "" {
+		t.Errorf("bruDictBlock() with no pairs = %q, want empty", block)
+	}
+}
@@ -2,201 +2,1509 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
 type BrunoGenerator struct {
-	OutputDir string
-	Config    *BrunoCollectionConfig
+	OutputDir   string
+	Config      *BrunoCollectionConfig
+	secretVars  map[string]bool     // Secret variables referenced by the generated requests
+	folders     map[string]bool     // Folders that already have a folder.bru this run
+	sequences   map[*Route]int      // seq values, assigned in order as requests are generated
+	fileNames   map[*Route]string   // File names changed to avoid collisions, set by assignFileNames
+	bodies      map[string][]string // Request names by rendered body, when DetectDuplicateBodies is set
+	dryRunFiles int                 // Files printed instead of written, when DryRun is set
+	dryRunOut   io.Writer           // Where dry runs print the files, os.Stdout by default
+	indexDocs   string              // Route index written to collection.bru's docs, set by GenerateCollectionDocs
 }
 
-type BrunoMetadata struct {
-	Name     string `json:"name"`
-	Type     string `json:"type"`
-	Sequence string `json:"seq,omitempty"` // TODO: automatically order this based on alphabetic order or something.
+type BrunoRequestDocs struct {
+	Docs string
 }
 
-type BrunoRequestData struct {
-	URL      string `json:"url"`
-	BodyType string `json:"body"` // focusing on JSON for time being.
-	Auth     string `json:"auth"` // currently not supported.
+type BrunoCollectionConfig struct {
+	BaseURL               string
+	MaxBodyBytes          int    // Upper bound on the size of a rendered example body, 0 disables the check
+	TagMode               string // How requests are placed in their secondary tag folders
+	Environments          []BrunoEnvironment
+	EnvironmentVariables  map[string]string    // Extra variables written to every environment
+	NoBaseURL             bool                 // Emit relative request URLs for collections that set the host themselves
+	FlattenBody           bool                 // Render nested structs as dotted keys (address.city) instead of nested objects
+	OmitOptional          bool                 // Leave omitempty fields that aren't required out of example request bodies
+	PrefixFolder          string               // Top-level folder for the path prefix every route shares, e.g. api/v1
+	PathFolders           bool                 // Place untagged requests in folders mirroring the static segments of their path
+	SortMode              string               // Order requests are numbered in (source, path)
+	Variables             []CollectionVariable // Collection-level variables from @var annotations
+	Strict                bool                 // Stop at the first request that fails to generate instead of skipping it
+	DetectDuplicateBodies bool                 // Track requests whose rendered bodies are identical
+	CollectionName        string               // Name written to bruno.json, defaults to the output directory name
+	DryRun                bool                 // Print the generated files to stdout instead of writing them
+	Scripts               bool                 // Add a post-response script stub to every request
+	Overwrite             bool                 // Replace existing request and environment files, otherwise they are skipped
+	Merge                 bool                 // Regenerate existing files in place, keeping their hand-written assert and script blocks
 }
 
-type BrunoRequestDocs struct {
-	Docs string
+type BrunoEnvironment struct {
+	Name     string
+	BaseURL  string
+	BasePath string // Optional path prefix for deployments that mount the API below the host root
 }
 
-type BrunoCollectionConfig struct {
-	BaseURL string
+// APIKeyVariable is the secret variable api key auth references.
+const APIKeyVariable = "apiKey"
+
+// DefaultAPIKeyHeader is used for api key auth when the annotation doesn't name a header.
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// BearerTokenVariable is the secret variable bearer tokens reference.
+const BearerTokenVariable = "authToken"
+
+const (
+	BasicUsernameVariable = "username"
+	BasicPasswordVariable = "password"
+)
+
+const (
+	OAuth2ClientIDVariable     = "clientId"
+	OAuth2ClientSecretVariable = "clientSecret"
+)
+
+const (
+	OAuth2AuthorizationCode = "authorization_code"
+	OAuth2ClientCredentials = "client_credentials"
+)
+
+const (
+	DefaultBearerHeader = "Authorization"
+	DefaultBearerPrefix = "Bearer"
+)
+
+// DefaultBaseURL is used for request URLs when no -url is given.
+const DefaultBaseURL = "http://localhost:8080"
+
+// DefaultEnvironmentName is the environment generated for the base URL when no
+// environments are configured.
+const DefaultEnvironmentName = "local"
+
+// BaseURLVariable is the environment variable requests use for their host when environments are generated.
+const BaseURLVariable = "baseUrl"
+
+const (
+	SortSource = "source" // Requests keep the order their handlers appear in the source
+	SortPath   = "path"   // Requests are ordered by path, then by method
+	SortMethod = "method" // Requests are ordered by method, then by path
+)
+
+// methodOrder is the conventional order of methods sharing a path, others sort after these
+var methodOrder = map[string]int{
+	"GET":    0,
+	"POST":   1,
+	"PUT":    2,
+	"PATCH":  3,
+	"DELETE": 4,
 }
 
+const (
+	TagModeAlias     = "alias"     // Secondary folders get a lightweight pointer to the primary request
+	TagModeDuplicate = "duplicate" // Secondary folders get a full copy of the request
+)
+
 const JSONOutputIndent = "  "
 
+// DefaultMaxBodyBytes is large enough that only pathological schemas hit it.
+const DefaultMaxBodyBytes = 1 << 20
+
 // NewBrunoGenerator creates a new Bruno generator instance
 func NewBrunoGenerator(outputDir string, baseURL string) *BrunoGenerator {
 	return &BrunoGenerator{
-		OutputDir: outputDir,
+		OutputDir:  outputDir,
+		secretVars: make(map[string]bool),
+		folders:    make(map[string]bool),
+		sequences:  make(map[*Route]int),
+		fileNames:  make(map[*Route]string),
+		bodies:     make(map[string][]string),
+		dryRunOut:  os.Stdout,
 		Config: &BrunoCollectionConfig{
-			BaseURL: baseURL,
+			BaseURL:      baseURL,
+			MaxBodyBytes: DefaultMaxBodyBytes,
+			TagMode:      TagModeAlias,
+			Overwrite:    true,
 		},
 	}
 }
 
 // GenerateRequestFile generates a Bruno request file for a given route
 func (g *BrunoGenerator) GenerateRequestFile(route *Route) error {
+	fileName := g.requestFileName(route)
 
-	fileName := strings.ReplaceAll(route.Name, " ", "")
-	filePath := filepath.Join(g.OutputDir, fileName+".bru")
+	content, err := g.buildRequestFile(route)
+	if err != nil {
+		return err
+	}
 
+	// Name the shared prefix folder(s) before anything is written into them.
+	requestsDir := g.OutputDir
+	for _, segment := range strings.Split(g.Config.PrefixFolder, "/") {
+		if segment == "" {
+			continue
+		}
+		requestsDir = filepath.Join(requestsDir, segment)
+		if err := g.GenerateFolderFile(requestsDir, segment); err != nil {
+			return newGenerateError(route, "folder", err)
+		}
+	}
+
+	// Routes without tags live at the root of the collection, or in their path's folders.
+	if len(route.Tags) == 0 {
+		for _, segment := range g.pathFolders(route) {
+			requestsDir = filepath.Join(requestsDir, segment)
+			if err := g.GenerateFolderFile(requestsDir, segment); err != nil {
+				return newGenerateError(route, "folder", err)
+			}
+		}
+		if err := g.writeBruFile(requestsDir, fileName, route, content); err != nil {
+			return newGenerateError(route, "request file", err)
+		}
+		return nil
+	}
+
+	for _, tag := range route.Tags {
+		if err := g.GenerateFolderFile(filepath.Join(requestsDir, tagFolder(tag)), tag); err != nil {
+			return newGenerateError(route, "folder", err)
+		}
+	}
+
+	// The first tag is the primary folder, the rest get a duplicate or an alias.
+	primaryDir := filepath.Join(requestsDir, tagFolder(route.Tags[0]))
+	if err := g.writeBruFile(primaryDir, fileName, route, content); err != nil {
+		return newGenerateError(route, "request file", err)
+	}
+
+	for _, tag := range route.Tags[1:] {
+		tagContent := content
+		if g.Config.TagMode != TagModeDuplicate {
+			tagContent, err = g.generateAliasFile(route, g.requestFilePath(route))
+			if err != nil {
+				return newGenerateError(route, "alias", err)
+			}
+		}
+
+		if err := g.writeBruFile(filepath.Join(requestsDir, tagFolder(tag)), fileName, route, tagContent); err != nil {
+			return newGenerateError(route, "request file", err)
+		}
+	}
+
+	return nil
+}
+
+// buildRequestFile assembles the content of a route's request file
+func (g *BrunoGenerator) buildRequestFile(route *Route) (string, error) {
 	metaDataSectionString, err := g.generateBrunoMetaDataSection(route)
 	if err != nil {
-		return err
+		return "", newGenerateError(route, "meta", err)
 	}
 
 	requestSectionString, err := g.generateBrunoRequestSection(route)
 	if err != nil {
-		return err
+		return "", newGenerateError(route, "request", err)
 	}
 
 	var bodyJSONString string
-	if route.RequestBody != nil {
-		bodyJSONString, err = g.generateRequestJSONBodySection(route.RequestBody)
+	if route.RequestBody != nil || route.ContentType == GraphQLContentType {
+		bodyJSONString, err = g.generateRequestBodySection(route)
 		if err != nil {
-			return err
+			return "", newGenerateError(route, "body", err)
 		}
 	}
 
+	// gRPC requests have their own request and message blocks
+	if route.GRPC != nil {
+		requestSectionString = g.generateGRPCRequestSection(route)
+		if route.RequestBody != nil {
+			bodyJSONString, err = g.generateGRPCBodySection(route)
+			if err != nil {
+				return "", newGenerateError(route, "grpc body", err)
+			}
+		}
+	}
+
+	if g.Config.DetectDuplicateBodies && bodyJSONString != "" {
+		g.bodies[bodyJSONString] = append(g.bodies[bodyJSONString], g.requestFilePath(route))
+	}
+
+	authSectionString := g.generateAuthSection(route)
+	assertSectionString := g.GenerateAssertSection(route)
+	scriptSectionString := g.generatePostResponseScriptSection(route)
+	settingsSectionString := g.generateSettingsSection(route)
+	queryParamsSectionString := g.generateQueryParamsSection(route)
+	pathParamsSectionString := g.generatePathParamsSection(route)
+	headersSectionString := g.generateHeadersSection(route)
+
 	docsSectionString, err := g.generateDocsSection(route)
 	if err != nil {
+		return "", newGenerateError(route, "docs", err)
+	}
+
+	sections := []string{
+		metaDataSectionString,
+		requestSectionString,
+		queryParamsSectionString,
+		pathParamsSectionString,
+		headersSectionString,
+		authSectionString,
+		bodyJSONString,
+		assertSectionString,
+		scriptSectionString,
+		settingsSectionString,
+		docsSectionString,
+	}
+
+	return joinSections(sections), nil
+}
+
+// GenerateFolderFile writes the folder.bru naming a collection folder. Hand edits made to
+// the folder in Bruno (docs, headers, settings...) are preserved across regenerations.
+func (g *BrunoGenerator) GenerateFolderFile(dir string, name string) error {
+	if g.folders[dir] {
+		return nil
+	}
+	g.folders[dir] = true
+
+	content := bruDictBlock("meta", []bruPair{{Key: "name", Value: name}}) + "\n"
+	return g.writeMergedFile(dir, "folder.bru", content)
+}
+
+// requestFileName returns the file name (without extension) of a route's request file,
+// including any suffix added to resolve a collision
+func (g *BrunoGenerator) requestFileName(route *Route) string {
+	if fileName, ok := g.fileNames[route]; ok {
+		return fileName
+	}
+	return requestFileName(route)
+}
+
+// requestFileName returns the file name (without extension) a route's request file is named after
+func requestFileName(route *Route) string {
+	switch {
+	case route.FileName != "":
+		return route.FileName
+	case route.Name != "":
+		return slugify(route.Name)
+	}
+	return methodPathFileName(route.Method, route.Path)
+}
+
+// slugify lowercases a name for use as a file name, turning spaces and slashes into
+// hyphens and dropping anything else that isn't a letter, digit, hyphen or underscore
+func slugify(name string) string {
+	var slug strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case r == ' ' || r == '/':
+			if !strings.HasSuffix(slug.String(), "-") {
+				slug.WriteRune('-')
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			slug.WriteRune(r)
+		}
+	}
+	return strings.Trim(slug.String(), "-")
+}
+
+// tagFolder returns the folder a tag's requests are written to. Tags come from source comments,
+// so path separators are replaced and leading dots dropped to keep a tag such as ../../x
+// inside the collection.
+func tagFolder(tag string) string {
+	folder := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(tag))
+
+	folder = strings.TrimLeft(folder, ".-")
+	if folder == "" {
+		return "untagged"
+	}
+	return folder
+}
+
+// methodPathReplacer turns path separators into underscores and drops parameter markers
+var methodPathReplacer = strings.NewReplacer("/", "_", ":", "", "{", "", "}", "", "*", "", " ", "")
+
+// methodPathFileName names a request after its method and path, e.g. get_users_id, for
+// routes without a @name
+func methodPathFileName(method, path string) string {
+	return strings.ToLower(method) + "_" + strings.Trim(methodPathReplacer.Replace(path), "_")
+}
+
+// requestFilePath returns the path of a route's primary request file relative to the collection
+func (g *BrunoGenerator) requestFilePath(route *Route) string {
+	fileName := g.requestFileName(route) + ".bru"
+	if len(route.Tags) > 0 {
+		fileName = filepath.Join(tagFolder(route.Tags[0]), fileName)
+	} else {
+		fileName = filepath.Join(append(g.pathFolders(route), fileName)...)
+	}
+	return filepath.ToSlash(filepath.Join(g.Config.PrefixFolder, fileName))
+}
+
+// pathFolders returns the folders an untagged request is nested in when PathFolders is set:
+// the static segments of its path, leaving out path parameters and the prefix folder.
+// GET /users/:id/posts lands in users/posts.
+func (g *BrunoGenerator) pathFolders(route *Route) []string {
+	if !g.Config.PathFolders || route.GRPC != nil {
+		return nil
+	}
+
+	segments := strings.Split(strings.Trim(route.Path, "/"), "/")
+	if prefix := g.Config.PrefixFolder; prefix != "" {
+		prefixSegments := strings.Split(prefix, "/")
+		if len(segments) >= len(prefixSegments) && strings.Join(segments[:len(prefixSegments)], "/") == prefix {
+			segments = segments[len(prefixSegments):]
+		}
+	}
+
+	folders := []string{}
+	for _, segment := range segments {
+		if _, _, isParam := splitPathParam(segment); isParam || segment == "" || strings.HasPrefix(segment, "*") {
+			continue
+		}
+		folders = append(folders, segment)
+	}
+	return folders
+}
+
+// DetectPrefixFolder groups every request under a top-level folder named after the
+// path prefix all routes share, e.g. /api/v1
+func (g *BrunoGenerator) DetectPrefixFolder(routes []*Route) {
+	g.Config.PrefixFolder = strings.Trim(commonPathPrefix(routes), "/")
+}
+
+// commonPathPrefix returns the leading static path segments shared by every HTTP route
+func commonPathPrefix(routes []*Route) string {
+	var common []string
+	first := true
+
+	for _, route := range routes {
+		if route.GRPC != nil {
+			continue
+		}
+
+		// Path parameters and the final segment never count towards a folder
+		segments := strings.Split(strings.Trim(route.Path, "/"), "/")
+		segments = segments[:len(segments)-1]
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "{") {
+				segments = segments[:i]
+				break
+			}
+		}
+
+		if first {
+			common = segments
+			first = false
+			continue
+		}
+
+		n := 0
+		for n < len(common) && n < len(segments) && common[n] == segments[n] {
+			n++
+		}
+		common = common[:n]
+	}
+
+	if len(common) == 0 {
+		return ""
+	}
+	return "/" + strings.Join(common, "/")
+}
+
+// writeBruFile writes the content of a .bru file into the given directory, under the generated
+// header of the route it documents (nil for files that aren't requests)
+func (g *BrunoGenerator) writeBruFile(dir string, fileName string, route *Route, content string) error {
+	path := filepath.Join(dir, fileName+".bru")
+	if existing, err := os.ReadFile(path); err == nil {
+		switch {
+		case g.Config.Merge:
+			content = mergeRequestFile(string(existing), content)
+		case !g.Config.Overwrite:
+			getLogger().Info(fmt.Sprintf("Skipping %s, it already exists", path))
+			return nil
+		}
+	} else if !os.IsNotExist(err) {
 		return err
 	}
+	content = generatedHeader(route, g.keptBlocks(route)) + content
+
+	if g.Config.DryRun {
+		g.printDryRunFile(path, content)
+		return nil
+	}
 
 	// Make sure the output directory exists.
-	if err := os.MkdirAll(g.OutputDir, 0755); err != nil {
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
 
 	// Generate the unique file path.
-	file, err := os.Create(filePath)
+	file, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
+	_, err = file.WriteString(content)
+	return err
+}
+
+// keptBlocks returns the blocks a re-run keeps as edited in a file written by writeBruFile, for
+// its header: nil without -merge, the assert, script and tests blocks of merged requests
+func (g *BrunoGenerator) keptBlocks(route *Route) []string {
+	switch {
+	case !g.Config.Merge:
+		return nil
+	case route != nil:
+		return keptRequestBlocks()
+	default:
+		return []string{}
+	}
+}
+
+// writeMergedFile writes a folder.bru or collection.bru into dir, keeping the blocks edited
+// in Bruno, or prints it on a dry run
+func (g *BrunoGenerator) writeMergedFile(dir string, fileName string, content string) error {
+	path := filepath.Join(dir, fileName)
+	content, err := mergedFileContent(path, content)
+	if err != nil {
+		return err
+	}
+
+	if g.Config.DryRun {
+		g.printDryRunFile(path, content)
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// printDryRunFile prints a file's path and content in place of writing it
+func (g *BrunoGenerator) printDryRunFile(path string, content string) {
+	g.dryRunFiles++
+	fmt.Fprintf(g.dryRunOut, "--- %s\n%s\n", path, strings.TrimRight(content, "\n"))
+}
+
+// DryRunFileCount returns how many files a dry run would have written
+func (g *BrunoGenerator) DryRunFileCount() int {
+	return g.dryRunFiles
+}
+
+// generateAliasFile creates a lightweight request file pointing back at the primary copy
+func (g *BrunoGenerator) generateAliasFile(route *Route, primaryPath string) (string, error) {
+	metaDataSectionString, err := g.generateBrunoMetaDataSection(route)
+	if err != nil {
+		return "", err
+	}
+
+	requestSectionString, err := g.generateBrunoRequestSection(route)
+	if err != nil {
+		return "", err
+	}
+
+	docsSectionString := fmt.Sprintf("docs {\n%s\n}", indentBlock(
+		fmt.Sprintf("Alias of `%s`, edit the primary request instead.", filepath.ToSlash(primaryPath))))
+
 	sections := []string{
 		metaDataSectionString,
 		requestSectionString,
-		bodyJSONString,
 		docsSectionString,
 	}
 
-	content := strings.Join(sections, "\n\n")
-
-	_, err = file.WriteString(content)
-	return err
+	return strings.Join(sections, "\n\n"), nil
 }
 
 // generateBrunoMetaDataSection creates the metadata section for a Bruno request file
 func (g *BrunoGenerator) generateBrunoMetaDataSection(route *Route) (string, error) {
-	meta := BrunoMetadata{
-		Name: route.Name,
-		Type: "http",
+	requestType := "http"
+	if route.GRPC != nil {
+		requestType = "grpc"
 	}
-	jsonBytes, err := json.MarshalIndent(meta, "", JSONOutputIndent)
-	if err != nil {
-		return "", err
+
+	name := route.Name
+	if route.Deprecated {
+		name = "[DEPRECATED] " + name
+	}
+
+	pairs := []bruPair{
+		{Key: "name", Value: name},
+		{Key: "type", Value: requestType},
+	}
+	// Requests generated outside GenerateCollection take the next free seq
+	seq, ok := g.sequences[route]
+	if !ok {
+		seq = len(g.sequences) + 1
+		g.sequences[route] = seq
+	}
+	pairs = append(pairs, bruPair{Key: "seq", Value: strconv.Itoa(seq)})
+	if tags := requestTags(route); len(tags) > 0 {
+		pairs = append(pairs, bruPair{Key: "tags", List: tags})
+	}
+
+	return bruDictBlock("meta", pairs), nil
+}
+
+// requestTags lists a route's tags for Bruno, its folder tags followed by its labels as key:value
+func requestTags(route *Route) []string {
+	tags := append([]string{}, route.Tags...)
+
+	keys := make([]string, 0, len(route.Labels))
+	for key := range route.Labels {
+		keys = append(keys, key)
 	}
-	jsonString := jsonBytesToBruString(jsonBytes)
-	return fmt.Sprintf("meta %s", jsonString), nil
+	sort.Strings(keys)
+	for _, key := range keys {
+		tags = append(tags, key+":"+route.Labels[key])
+	}
+
+	return tags
 }
 
 // generateBrunoRequestSection creates the request section for a Bruno request file
 func (g *BrunoGenerator) generateBrunoRequestSection(route *Route) (string, error) {
-	requestData := BrunoRequestData{
-		URL:  g.Config.BaseURL + route.Path,
-		Auth: "none",
+	auth := "none"
+	if route.Auth != "" {
+		auth = route.Auth
 	}
 
-	if route.RequestBody != nil {
-		requestData.BodyType = "json"
+	// Query api keys travel in the URL, and custom bearer headers in the headers block,
+	// rather than through Bruno's auth settings.
+	if isQueryAPIKey(route) || isCustomBearer(route) {
+		auth = "none"
 	}
 
-	jsonBytes, err := json.MarshalIndent(requestData, "", JSONOutputIndent)
-	if err != nil {
-		return "", err
+	bodyMode := "none"
+	if route.RequestBody != nil || route.ContentType == GraphQLContentType {
+		bodyMode = bodyRendererFor(route).BodyMode()
 	}
 
-	jsonString := jsonBytesToBruString(jsonBytes)
-	methodPrefix := strings.ToLower(route.Method)
+	return bruDictBlock(strings.ToLower(route.Method), []bruPair{
+		{Key: "url", Value: g.requestURL(route)},
+		{Key: "body", Value: bodyMode},
+		{Key: "auth", Value: auth},
+	}), nil
+}
+
+// requestURL builds the full URL a request is sent to
+func (g *BrunoGenerator) requestURL(route *Route) string {
+	url := g.hostURL() + brunoPath(route.Path)
 
-	return fmt.Sprintf("%s %s", methodPrefix, jsonString), nil
+	// Bruno keeps the enabled query params in the URL as well
+	query := []string{}
+	for _, param := range route.QueryParams {
+		if param.Required {
+			query = append(query, fmt.Sprintf("%s=%s", param.Name, queryParamExample(param)))
+		}
+	}
+	if isQueryAPIKey(route) {
+		query = append(query, fmt.Sprintf("%s={{%s}}", apiKeyName(route), APIKeyVariable))
+	}
+	if len(query) > 0 {
+		url += "?" + strings.Join(query, "&")
+	}
+	return url
 }
 
-// generateRequestJSONBodySection creates the JSON body section for a Bruno request file
-func (g *BrunoGenerator) generateRequestJSONBodySection(requestBody *RequestBody) (string, error) {
-	body := make(map[string]interface{})
-	for _, field := range requestBody.Fields {
-		var defaultValue interface{}
-		// Generate default values based on field type
-		switch strings.ToLower(field.Type) {
-		case "string":
-			defaultValue = ""
-		case "int", "int64", "int32", "float64", "float32":
-			defaultValue = 0
-		case "bool":
-			defaultValue = false
-		case "array", "slice":
-			defaultValue = []interface{}{}
-		case "map":
-			defaultValue = map[string]interface{}{}
-		default:
-			defaultValue = nil
-		}
-		body[field.JSONName] = defaultValue
-	}
-
-	// Convert to JSON
-	jsonBytes, err := json.MarshalIndent(body, "", JSONOutputIndent)
+// hostURL returns what request URLs are prefixed with: nothing for relative requests,
+// otherwise the environment variable holding the base URL
+func (g *BrunoGenerator) hostURL() string {
+	if g.Config.NoBaseURL || len(g.environments()) == 0 {
+		return ""
+	}
+	return "{{" + BaseURLVariable + "}}"
+}
+
+// environments returns the configured environments. Without any, a local environment
+// pointing at the base URL is used, unless requests are relative.
+func (g *BrunoGenerator) environments() []BrunoEnvironment {
+	if len(g.Config.Environments) > 0 {
+		return g.Config.Environments
+	}
+	if g.Config.NoBaseURL || g.Config.BaseURL == "" {
+		return nil
+	}
+	return []BrunoEnvironment{{Name: DefaultEnvironmentName, BaseURL: g.Config.BaseURL}}
+}
+
+// generatePathParamsSection creates the path params block with an example value for each parameter
+func (g *BrunoGenerator) generatePathParamsSection(route *Route) string {
+	if route.GRPC != nil {
+		return ""
+	}
+
+	pairs := []bruPair{}
+	for _, param := range pathParams(route) {
+		pairs = append(pairs, bruPair{Key: param.Name, Value: pathParamExample(param.Type)})
+	}
+
+	return bruDictBlock("params:path", pairs)
+}
+
+// generateOAuth2Section creates the oauth2 block, with the client credentials kept in secrets
+func (g *BrunoGenerator) generateOAuth2Section(route *Route) string {
+	g.secretVars[OAuth2ClientIDVariable] = true
+	g.secretVars[OAuth2ClientSecretVariable] = true
+
+	options := route.AuthOptions
+	grant := oauth2Grant(route)
+
+	pairs := []bruPair{{Key: "grant_type", Value: grant}}
+	if grant == OAuth2AuthorizationCode {
+		pairs = append(pairs,
+			bruPair{Key: "callback_url", Value: options.CallbackURL},
+			bruPair{Key: "authorization_url", Value: options.AuthURL},
+		)
+	}
+	pairs = append(pairs,
+		bruPair{Key: "access_token_url", Value: options.TokenURL},
+		bruPair{Key: "client_id", Value: "{{" + OAuth2ClientIDVariable + "}}"},
+		bruPair{Key: "client_secret", Value: "{{" + OAuth2ClientSecretVariable + "}}"},
+		bruPair{Key: "scope", Value: strings.Join(route.Scopes, " ")},
+	)
+
+	return bruDictBlock("auth:oauth2", pairs)
+}
+
+// oauth2Grant returns the grant type of an oauth2 route, defaulting to the authorization code grant
+func oauth2Grant(route *Route) string {
+	switch route.AuthOptions.Grant {
+	case OAuth2ClientCredentials:
+		return OAuth2ClientCredentials
+	case "", OAuth2AuthorizationCode:
+		return OAuth2AuthorizationCode
+	}
+
+	getLogger().Warn(fmt.Sprintf("Unsupported oauth2 grant %q on handler %s, using %s", route.AuthOptions.Grant, route.Handler, OAuth2AuthorizationCode))
+	return OAuth2AuthorizationCode
+}
+
+// generateHeadersSection creates the headers block from the @header annotations, plus bearer
+// tokens sent in a custom header or with a custom prefix, which Bruno's bearer auth can't express
+func (g *BrunoGenerator) generateHeadersSection(route *Route) string {
+	pairs := []bruPair{}
+	for _, header := range route.Headers {
+		pairs = append(pairs, bruPair{Key: header.Name, Value: header.Value})
+	}
+
+	if isCustomBearer(route) {
+		g.secretVars[BearerTokenVariable] = true
+		pairs = append(pairs, bruPair{Key: bearerHeader(route), Value: fmt.Sprintf("%s {{%s}}", bearerPrefix(route), BearerTokenVariable)})
+	}
+
+	return bruDictBlock("headers", pairs)
+}
+
+// isCustomBearer reports whether a bearer route changes the header or prefix of the token
+func isCustomBearer(route *Route) bool {
+	return route.Auth == "bearer" && (bearerHeader(route) != DefaultBearerHeader || bearerPrefix(route) != DefaultBearerPrefix)
+}
+
+// bearerHeader returns the header carrying a bearer token
+func bearerHeader(route *Route) string {
+	if route.AuthOptions.Header != "" {
+		return route.AuthOptions.Header
+	}
+	return DefaultBearerHeader
+}
+
+// bearerPrefix returns the scheme written before a bearer token
+func bearerPrefix(route *Route) string {
+	if route.AuthOptions.Prefix != "" {
+		return route.AuthOptions.Prefix
+	}
+	return DefaultBearerPrefix
+}
+
+// generateAuthSection creates the auth block for modes that need extra settings
+func (g *BrunoGenerator) generateAuthSection(route *Route) string {
+	if route.Auth == "bearer" && !isCustomBearer(route) {
+		g.secretVars[BearerTokenVariable] = true
+		return bruDictBlock("auth:bearer", []bruPair{{Key: "token", Value: "{{" + BearerTokenVariable + "}}"}})
+	}
+
+	if route.Auth == "oauth2" {
+		return g.generateOAuth2Section(route)
+	}
+
+	if route.Auth == "basic" {
+		g.secretVars[BasicUsernameVariable] = true
+		g.secretVars[BasicPasswordVariable] = true
+		return bruDictBlock("auth:basic", []bruPair{
+			{Key: "username", Value: "{{" + BasicUsernameVariable + "}}"},
+			{Key: "password", Value: "{{" + BasicPasswordVariable + "}}"},
+		})
+	}
+
+	if route.Auth != "apikey" || isQueryAPIKey(route) {
+		return ""
+	}
+
+	g.secretVars[APIKeyVariable] = true
+
+	return bruDictBlock("auth:apikey", []bruPair{
+		{Key: "key", Value: apiKeyName(route)},
+		{Key: "value", Value: "{{" + APIKeyVariable + "}}"},
+		{Key: "placement", Value: "header"},
+	})
+}
+
+// GenerateAssertSection creates an assert block checking the status of the route's primary
+// response, along with the presence of the required fields of its body
+func (g *BrunoGenerator) GenerateAssertSection(route *Route) string {
+	if route.GRPC != nil {
+		return ""
+	}
+
+	response, ok := primaryResponse(route.Responses)
+	if !ok {
+		return ""
+	}
+
+	pairs := []bruPair{{Key: "res.status", Value: fmt.Sprintf("eq %d", response.StatusCode)}}
+	if response.Body != nil {
+		for _, field := range response.Body.Fields {
+			if field.Required {
+				pairs = append(pairs, bruPair{Key: "res.body." + field.JSONName, Value: "isDefined"})
+			}
+		}
+	}
+
+	return bruDictBlock("assert", pairs)
+}
+
+// generatePostResponseScriptSection creates a post-response script stub when Scripts is set.
+// Login and auth routes save the returned token for the requests that use bearer auth.
+func (g *BrunoGenerator) generatePostResponseScriptSection(route *Route) string {
+	if !g.Config.Scripts || route.GRPC != nil {
+		return ""
+	}
+
+	script := "// Save values from the response for later requests, e.g.\n// bru.setEnvVar(\"id\", res.body.id);"
+	if isLoginRoute(route) {
+		script = fmt.Sprintf("// Keep the token for the requests that use bearer auth\nbru.setEnvVar(\"%s\", res.body.token);", BearerTokenVariable)
+	}
+
+	return fmt.Sprintf("script:post-response {\n%s\n}", indentBlock(script))
+}
+
+// isLoginRoute reports whether a route logs in, i.e. it is named after logging in or tagged auth
+func isLoginRoute(route *Route) bool {
+	if strings.Contains(strings.ToLower(route.Name+" "+route.Handler), "login") {
+		return true
+	}
+	for _, tag := range route.Tags {
+		if strings.EqualFold(tag, "auth") {
+			return true
+		}
+	}
+	return false
+}
+
+// primaryResponse picks the documented response a request is expected to get: the first
+// 2xx one, or the first one when none succeed. Inferred responses are left out.
+func primaryResponse(responses []ResponseSpec) (ResponseSpec, bool) {
+	var primary ResponseSpec
+	found := false
+	for _, response := range responses {
+		if response.Inferred {
+			continue
+		}
+		if response.StatusCode >= 200 && response.StatusCode < 300 {
+			return response, true
+		}
+		if !found {
+			primary, found = response, true
+		}
+	}
+	return primary, found
+}
+
+// generateSettingsSection creates the settings block for a Bruno request file
+func (g *BrunoGenerator) generateSettingsSection(route *Route) string {
+	keys := make([]string, 0, len(route.Settings))
+	for key := range route.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]bruPair, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, bruPair{Key: key, Value: route.Settings[key]})
+	}
+
+	return bruDictBlock("settings", pairs)
+}
+
+// generateQueryParamsSection creates the query params block for a Bruno request file.
+// Optional @query parameters are written disabled (~name) so they can be switched on in Bruno.
+func (g *BrunoGenerator) generateQueryParamsSection(route *Route) string {
+	pairs := []bruPair{}
+	for _, param := range route.QueryParams {
+		pairs = append(pairs, bruPair{Key: param.Name, Value: queryParamExample(param), Disabled: !param.Required})
+	}
+
+	if isQueryAPIKey(route) {
+		g.secretVars[APIKeyVariable] = true
+		pairs = append(pairs, bruPair{Key: apiKeyName(route), Value: "{{" + APIKeyVariable + "}}"})
+	}
+
+	return bruDictBlock("params:query", pairs)
+}
+
+// queryParamExample returns the example value of a query parameter, using the same
+// defaults as body fields
+func queryParamExample(param QueryParam) string {
+	return formatExampleValue(defaultValueForType(param.Type))
+}
+
+// isQueryAPIKey reports whether a route sends its api key as a query parameter
+func isQueryAPIKey(route *Route) bool {
+	return route.Auth == "apikey" && route.AuthOptions.In == "query"
+}
+
+// apiKeyName returns the header or query parameter name carrying the api key
+func apiKeyName(route *Route) string {
+	if route.AuthOptions.Key != "" {
+		return route.AuthOptions.Key
+	}
+	if route.AuthOptions.In == "query" {
+		return "apikey"
+	}
+	return DefaultAPIKeyHeader
+}
+
+// generateRequestBodySection creates the body section for a Bruno request file in the
+// route's content type. GraphQL routes get their query block ahead of the variables.
+func (g *BrunoGenerator) generateRequestBodySection(route *Route) (string, error) {
+	blocks := []string{}
+	if route.ContentType == GraphQLContentType {
+		if route.GraphQLQuery == "" {
+			getLogger().Warn(fmt.Sprintf("GraphQL handler %s has no @graphql-query", route.Handler),
+				"handler", route.Handler, "file", route.SourceFile, "line", route.SourceLine)
+		}
+		blocks = append(blocks, fmt.Sprintf("body:graphql {\n%s\n}", indentBlock(route.GraphQLQuery)))
+	}
+
+	requestBody := route.RequestBody
+	if requestBody == nil {
+		return strings.Join(blocks, "\n\n"), nil
+	}
+	renderer := bodyRendererFor(route)
+
+	opts := g.newRouteExampleOptions(route)
+	body, err := renderer.Render(requestBody, opts)
 	if err != nil {
 		return "", err
 	}
+	if opts.budget.truncated {
+		getLogger().Warn(fmt.Sprintf("Example body for %s exceeded %d bytes and was truncated", requestBody.TypeName, g.Config.MaxBodyBytes))
+	}
 
-	jsonString := strings.ReplaceAll(string(jsonBytes), "}", JSONOutputIndent+"}")
-
-	return fmt.Sprintf("body:json {\n  %s\n}", jsonString), nil
+	// Indent every line so nested objects keep their shape inside the block
+	blocks = append(blocks, fmt.Sprintf("%s {\n%s\n}", renderer.BlockName(), indentBlock(body)))
+	return strings.Join(blocks, "\n\n"), nil
 }
 
 // GenerateDocsSection generates documentation section for a Bruno request file
 func (g *BrunoGenerator) generateDocsSection(route *Route) (string, error) {
+	docs, err := g.generateDocs(route)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("docs {\n%s\n}", indentBlock(docs)), nil
+}
+
+// generateDocs assembles the markdown documentation for a request
+func (g *BrunoGenerator) generateDocs(route *Route) (string, error) {
 	docs := BrunoRequestDocs{
 		Docs: route.Description,
 	}
 
-	return fmt.Sprintf("docs {\n  %s\n}", docs.Docs), nil
+	// The deprecation note goes first so it's the first thing seen in Bruno
+	if route.Deprecated {
+		note := "> **Deprecated**"
+		if route.Deprecation != "" {
+			note += ": " + route.Deprecation
+		}
+		if description := strings.TrimSpace(docs.Docs); description != "" {
+			note += "\n\n" + description
+		}
+		docs.Docs = note + "\n"
+	}
+
+	sections := []string{generateQueryParamDocs(route), generateScopeDocs(route), generateRateLimitDocs(route)}
+	if len(route.Responses) > 0 {
+		responseDocs, err := g.generateResponseDocs(route.Responses)
+		if err != nil {
+			return "", err
+		}
+		sections = append(sections, responseDocs)
+	}
+	sections = append(sections,
+		generateRequiredFieldDocs(route),
+		generateConditionDocs(route.RequestBody),
+		generateAdditionalPropertiesDocs(route.RequestBody),
+		generateSourceDocs(route),
+	)
+
+	// Append each extra section below the description
+	for _, section := range sections {
+		if section == "" {
+			continue
+		}
+		if description := strings.TrimRight(docs.Docs, "\n"); description != "" {
+			section = description + "\n\n" + section
+		}
+		docs.Docs = section
+	}
+
+	return docs.Docs, nil
+}
+
+// generateResponseDocs renders the documented responses as markdown with example bodies
+func (g *BrunoGenerator) generateResponseDocs(responses []ResponseSpec) (string, error) {
+	var builder strings.Builder
+	builder.WriteString("### Responses\n")
+
+	for _, response := range responses {
+		builder.WriteString(fmt.Sprintf("\n**%d**", response.StatusCode))
+		if response.TypeName != "" {
+			builder.WriteString(fmt.Sprintf(" `%s`", response.TypeName))
+		}
+		builder.WriteString("\n")
+
+		if response.Body == nil {
+			continue
+		}
+
+		body := exampleBody(response.Body, g.newExampleOptions())
+		jsonBytes, err := json.MarshalIndent(body, "", JSONOutputIndent)
+		if err != nil {
+			return "", err
+		}
+		builder.WriteString(fmt.Sprintf("```json\n%s\n```\n", jsonBytes))
+	}
+
+	return builder.String(), nil
+}
+
+// generateScopeDocs lists the scopes a route requires
+func generateScopeDocs(route *Route) string {
+	if len(route.Scopes) == 0 {
+		return ""
+	}
+
+	lines := []string{}
+	for _, scope := range route.Scopes {
+		lines = append(lines, fmt.Sprintf("- `%s`", scope))
+	}
+
+	return "### Required scopes\n\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// generateRateLimitDocs documents a route's @ratelimit
+func generateRateLimitDocs(route *Route) string {
+	if route.RateLimit == "" {
+		return ""
+	}
+	return "### Rate limit\n\n" + route.RateLimit + "\n"
 }
 
-// TODO: need to generate the bruno.json file.
+// generateQueryParamDocs lists the @query parameters along with their descriptions
+func generateQueryParamDocs(route *Route) string {
+	if len(route.QueryParams) == 0 {
+		return ""
+	}
+
+	lines := []string{}
+	for _, param := range route.QueryParams {
+		line := fmt.Sprintf("- `%s` (%s", param.Name, param.Type)
+		if param.Required {
+			line += ", required"
+		}
+		line += ")"
+		if param.Description != "" {
+			line += " " + param.Description
+		}
+		lines = append(lines, line)
+	}
+
+	return "### Query parameters\n\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// generateSourceDocs shows the handler's source, when it was captured
+func generateSourceDocs(route *Route) string {
+	if route.SourceSnippet == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("### Source\n\n`%s:%d`\n\n```go\n%s\n```\n", route.SourceFile, route.SourceLine, route.SourceSnippet)
+}
+
+// generateRequiredFieldDocs lists the request body fields that must be sent
+func generateRequiredFieldDocs(route *Route) string {
+	if route.RequestBody == nil {
+		return ""
+	}
+
+	lines := []string{}
+	for _, field := range route.RequestBody.Fields {
+		if !field.Required {
+			continue
+		}
+		line := fmt.Sprintf("- `%s`", wireName(field, route.ContentType))
+		if field.Description != "" {
+			line += " " + field.Description
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return "### Required fields\n\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// generateConditionDocs lists the body fields that only apply for certain values of other fields
+func generateConditionDocs(requestBody *RequestBody) string {
+	if requestBody == nil {
+		return ""
+	}
+
+	lines := []string{}
+	for _, field := range requestBody.Fields {
+		if field.When == nil {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("- `%s` only applies when `%s` is `%s`", field.JSONName, field.When.Field, field.When.Value))
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return "### Conditional fields\n\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// generateAdditionalPropertiesDocs explains inline maps whose entries sit next to the declared fields
+func generateAdditionalPropertiesDocs(requestBody *RequestBody) string {
+	if requestBody == nil {
+		return ""
+	}
+
+	for _, field := range requestBody.Fields {
+		if isAdditionalProperties(field) {
+			return fmt.Sprintf("### Additional properties\n\nAny extra keys are accepted at the top level of the body and collected into `%s`.\n", field.Name)
+		}
+	}
+
+	return ""
+}
+
+// isAdditionalProperties reports whether a field is an inline map of extra keys
+func isAdditionalProperties(field RequestBodyField) bool {
+	return field.Inline && field.Type == "map"
+}
+
+// indentBlock indents every non-empty line of text so it nests inside a .bru block
+func indentBlock(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = JSONOutputIndent + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
 
-// GenerateCollection generates a complete Bruno collection
+// GenerateEnvironments writes an environment file for every configured environment, or the
+// local one pointing at the base URL
+func (g *BrunoGenerator) GenerateEnvironments() error {
+	for _, env := range g.environments() {
+		vars := map[string]string{}
+		for name, value := range g.Config.EnvironmentVariables {
+			vars[name] = value
+		}
+		vars[BaseURLVariable] = env.BaseURL + env.BasePath
+
+		if err := g.GenerateEnvironment(env.Name, vars); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenerateEnvironment writes environments/<name>.bru with the given variables
+func (g *BrunoGenerator) GenerateEnvironment(name string, vars map[string]string) error {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]bruPair, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, bruPair{Key: key, Value: vars[key]})
+	}
+
+	content := bruDictBlock("vars", pairs) + "\n"
+
+	// Secrets are declared without values so they never end up committed.
+	if len(g.secretVars) > 0 {
+		secrets := make([]string, 0, len(g.secretVars))
+		for secret := range g.secretVars {
+			secrets = append(secrets, secret)
+		}
+		sort.Strings(secrets)
+
+		content += fmt.Sprintf("\nvars:secret [\n%s\n]\n", indentBlock(strings.Join(secrets, ",\n")))
+	}
+
+	return g.writeBruFile(filepath.Join(g.OutputDir, "environments"), name, nil, content)
+}
+
+// BrunoManifest is the bruno.json file that marks a directory as a Bruno collection
+type BrunoManifest struct {
+	Version string   `json:"version"`
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Ignore  []string `json:"ignore"`
+}
+
+// GenerateCollectionManifest writes bruno.json, without which Bruno won't open the
+// output directory as a collection. An existing manifest is left alone since it may
+// hold settings configured in Bruno.
+func (g *BrunoGenerator) GenerateCollectionManifest() error {
+	path := filepath.Join(g.OutputDir, "bruno.json")
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	name := g.Config.CollectionName
+	if name == "" {
+		absDir, err := filepath.Abs(g.OutputDir)
+		if err != nil {
+			return err
+		}
+		name = filepath.Base(absDir)
+	}
+
+	manifest := BrunoManifest{
+		Version: "1",
+		Name:    name,
+		Type:    "collection",
+		Ignore:  []string{"node_modules", ".git"},
+	}
+
+	jsonBytes, err := json.MarshalIndent(manifest, "", JSONOutputIndent)
+	if err != nil {
+		return err
+	}
+
+	if g.Config.DryRun {
+		g.printDryRunFile(path, string(jsonBytes)+"\n")
+		return nil
+	}
+
+	if err := os.MkdirAll(g.OutputDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(jsonBytes, '\n'), 0644)
+}
+
+// GenerateCollectionFile writes collection.bru with the collection variables and the route
+// index, if any. Secret variables are left out and declared in the environments'
+// vars:secret instead.
+func (g *BrunoGenerator) GenerateCollectionFile() error {
+	pairs := []bruPair{}
+	for _, variable := range g.Config.Variables {
+		if variable.Secret {
+			g.secretVars[variable.Name] = true
+			continue
+		}
+		pairs = append(pairs, bruPair{Key: variable.Name, Value: variable.Value})
+	}
+
+	if len(pairs) == 0 && g.indexDocs == "" {
+		return nil
+	}
+
+	blocks := []string{}
+	if len(pairs) > 0 {
+		blocks = append(blocks, bruDictBlock("vars:pre-request", pairs))
+	}
+	if g.indexDocs != "" {
+		blocks = append(blocks, fmt.Sprintf("docs {\n%s\n}", indentBlock(g.indexDocs)))
+	}
+
+	content := strings.Join(blocks, "\n\n") + "\n"
+	return g.writeMergedFile(g.OutputDir, "collection.bru", content)
+}
+
+// secretVariableDocs explains the secret variables requests reference, for the collection docs
+var secretVariableDocs = map[string]string{
+	BearerTokenVariable:        "token sent by `@auth bearer` requests",
+	BasicUsernameVariable:      "username of `@auth basic` requests",
+	BasicPasswordVariable:      "password of `@auth basic` requests",
+	APIKeyVariable:             "key sent by `@auth apikey` requests",
+	OAuth2ClientIDVariable:     "client id of `@auth oauth2` requests",
+	OAuth2ClientSecretVariable: "client secret of `@auth oauth2` requests",
+}
+
+// GenerateCollectionDocs adds an index of every route, grouped by tag, to the docs of
+// collection.bru. It is written along with the collection variables by GenerateCollectionFile,
+// and lists the secrets the requests generated so far reference.
+func (g *BrunoGenerator) GenerateCollectionDocs(routes []*Route) {
+	secrets := make(map[string]bool)
+	for name := range g.secretVars {
+		secrets[name] = true
+	}
+	for _, variable := range g.Config.Variables {
+		if variable.Secret {
+			secrets[variable.Name] = true
+		}
+	}
+
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	g.indexDocs = collectionIndex(g.orderRoutes(routes), g.Config.BaseURL, names, time.Now().UTC())
+}
+
+// collectionIndex renders the Markdown route index of a collection, after the secret variables
+// to fill in. Routes are listed under each of their tags, untagged ones last under "Other".
+func collectionIndex(routes []*Route, baseURL string, secrets []string, generatedAt time.Time) string {
+	groups := make(map[string][]*Route)
+	for _, route := range routes {
+		if len(route.Tags) == 0 {
+			groups[""] = append(groups[""], route)
+		}
+		for _, tag := range route.Tags {
+			groups[tag] = append(groups[tag], route)
+		}
+	}
+
+	tags := make([]string, 0, len(groups))
+	for tag := range groups {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	if _, ok := groups[""]; ok {
+		tags = append(tags, "")
+	}
+
+	var builder strings.Builder
+	builder.WriteString("# API index\n\n")
+	if baseURL != "" {
+		builder.WriteString(fmt.Sprintf("Base URL: %s\n", baseURL))
+	}
+	builder.WriteString(fmt.Sprintf("Generated: %s\n", generatedAt.Format(time.RFC3339)))
+
+	if len(secrets) > 0 {
+		builder.WriteString("\n## Secrets\n\nFill these in as secret variables of each environment:\n\n")
+		for _, name := range secrets {
+			description, ok := secretVariableDocs[name]
+			if !ok {
+				description = "declared with `@var secret`"
+			}
+			builder.WriteString(fmt.Sprintf("- `%s`: %s\n", name, description))
+		}
+	}
+
+	for _, tag := range tags {
+		heading := tag
+		if heading == "" {
+			heading = "Other"
+		}
+		builder.WriteString(fmt.Sprintf("\n## %s\n\n", heading))
+
+		for _, route := range groups[tag] {
+			method := route.Method
+			if route.GRPC != nil {
+				method = "GRPC"
+			}
+			summary, _, _ := strings.Cut(strings.TrimSpace(route.Description), "\n")
+			if summary == "" {
+				summary = route.Name
+			}
+			if summary == "" {
+				summary = route.Handler
+			}
+			builder.WriteString(fmt.Sprintf("- `%s %s` %s\n", method, route.Path, summary))
+		}
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+// GenerateCollection writes a request file for every route, numbering them in the
+// configured sort order. Routes that fail to generate are logged and skipped, unless
+// Strict is set.
 func (g *BrunoGenerator) GenerateCollection(routes []*Route) error {
+	logger := getLogger()
+
 	// Create collection directory if it doesn't exist
-	if err := os.MkdirAll(g.OutputDir, 0755); err != nil {
+	if !g.Config.DryRun {
+		if err := os.MkdirAll(g.OutputDir, 0755); err != nil {
+			return err
+		}
+	}
+
+	if err := g.GenerateCollectionManifest(); err != nil {
 		return err
 	}
 
+	ordered := g.orderRoutes(routes)
+	g.assignFileNames(ordered)
+
+	// A fresh collection numbers its requests from 1 and compares only its own bodies
+	g.sequences = make(map[*Route]int)
+	g.bodies = make(map[string][]string)
+	for i, route := range ordered {
+		g.sequences[route] = i + 1
+	}
+
 	// Generate each request file
-	for _, route := range routes {
+	for _, route := range ordered {
+		logger.Info(fmt.Sprintf("Processing handler: %s %s", route.Method, route.Path))
 		if err := g.GenerateRequestFile(route); err != nil {
-			return err
+			if g.Config.Strict {
+				return err
+			}
+			var generateErr *GenerateError
+			if errors.As(err, &generateErr) {
+				logger.Error(fmt.Sprintf("Error generating Bruno file: %v", err), "category", "generate",
+					"handler", generateErr.Handler, "file", generateErr.SourceFile, "line", generateErr.SourceLine)
+				continue
+			}
+			logger.Error(fmt.Sprintf("Error generating Bruno file: %v", err))
+			continue
 		}
 	}
 
 	return nil
 }
 
-func jsonBytesToBruString(jsonBytes []byte) string {
-	return strings.ReplaceAll(strings.ReplaceAll(string(jsonBytes), `"`, ""), ",", "")
+// orderRoutes returns the routes in the configured sort order
+func (g *BrunoGenerator) orderRoutes(routes []*Route) []*Route {
+	ordered := append([]*Route{}, routes...)
+	switch g.Config.SortMode {
+	case SortPath:
+		sortRoutesByPath(ordered)
+	case SortMethod:
+		sortRoutesByMethod(ordered)
+	}
+	return ordered
+}
+
+// assignFileNames gives every route a request file of its own. When two routes would write
+// the same file, the later one gets a numeric suffix and a warning is logged. The check
+// ignores case, since some file systems do.
+func (g *BrunoGenerator) assignFileNames(routes []*Route) {
+	g.fileNames = make(map[*Route]string)
+	used := make(map[string]*Route)
+
+	for _, route := range routes {
+		path := strings.ToLower(g.requestFilePath(route))
+		first, collides := used[path]
+		if !collides {
+			used[path] = route
+			continue
+		}
+
+		base := requestFileName(route)
+		for n := 2; collides; n++ {
+			g.fileNames[route] = fmt.Sprintf("%s_%d", base, n)
+			path = strings.ToLower(g.requestFilePath(route))
+			_, collides = used[path]
+		}
+		used[path] = route
+
+		getLogger().Warn(fmt.Sprintf("Request file of handler %s collides with handler %s, writing it to %s instead; set distinct @name values to avoid this",
+			route.Handler, first.Handler, g.requestFilePath(route)), "category", "file-collision",
+			"handler", route.Handler, "file", route.SourceFile, "line", route.SourceLine)
+	}
+}
+
+// DuplicateBodies returns the groups of requests that render identical bodies, each group
+// listing the request files in generation order
+func (g *BrunoGenerator) DuplicateBodies() [][]string {
+	groups := [][]string{}
+	for _, requests := range g.bodies {
+		if len(requests) > 1 {
+			groups = append(groups, requests)
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i][0] < groups[j][0]
+	})
+	return groups
+}
+
+// sortRoutesByPath orders routes by path and then by conventional method order
+func sortRoutesByPath(routes []*Route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return methodRank(routes[i].Method) < methodRank(routes[j].Method)
+	})
+}
+
+// sortRoutesByMethod orders routes by conventional method order and then by path
+func sortRoutesByMethod(routes []*Route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		if rank, other := methodRank(routes[i].Method), methodRank(routes[j].Method); rank != other {
+			return rank < other
+		}
+		return routes[i].Path < routes[j].Path
+	})
+}
+
+// methodRank returns a method's position in methodOrder, unknown methods rank last
+func methodRank(method string) int {
+	if rank, ok := methodOrder[method]; ok {
+		return rank
+	}
+	return len(methodOrder)
+}
+
+// joinSections joins the non-empty sections of a .bru file
+func joinSections(sections []string) string {
+	nonEmpty := []string{}
+	for _, section := range sections {
+		if section != "" {
+			nonEmpty = append(nonEmpty, section)
+		}
+	}
+	return strings.Join(nonEmpty, "\n\n")
 }
@@ -1,7 +1,750 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestGenerateBrunoMetaDataSection(t *testing.T) {
 
 }
+
+func TestGenerateFolderFileWritesProvenance(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "users")
+	g := NewBrunoGenerator(filepath.Dir(dir), "api.example.com")
+
+	if err := g.GenerateFolderFile(dir, "users"); err != nil {
+		t.Fatalf("GenerateFolderFile() error = %v", err)
+	}
+
+	content := readFile(t, filepath.Join(dir, "folder.bru"))
+	if !strings.HasPrefix(content, ProvenanceMarker) {
+		t.Errorf("folder.bru missing provenance marker:\n%s", content)
+	}
+	if !isUntouched(content) {
+		t.Errorf("freshly generated folder.bru should be untouched")
+	}
+	if _, rest, _ := strings.Cut(content, "\n"); !strings.HasPrefix(rest, generatedHeader(nil, []string{})) {
+		t.Errorf("folder.bru missing generated header:\n%s", content)
+	}
+	// Edited folder files are merged, so the header must not claim edits are lost
+	if notice := "# Edits to blocks added in Bruno are kept when brungo runs again, the rest is regenerated\n"; !strings.Contains(content, notice) {
+		t.Errorf("folder.bru header should say added blocks are kept:\n%s", content)
+	}
+}
+
+func TestGenerateCollectionManifest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my-api")
+	g := NewBrunoGenerator(dir, "api.example.com")
+
+	if err := g.GenerateCollection(nil); err != nil {
+		t.Fatalf("GenerateCollection() error = %v", err)
+	}
+
+	var manifest BrunoManifest
+	if err := json.Unmarshal([]byte(readFile(t, filepath.Join(dir, "bruno.json"))), &manifest); err != nil {
+		t.Fatalf("bruno.json is not valid JSON: %v", err)
+	}
+	if manifest.Name != "my-api" || manifest.Type != "collection" || manifest.Version != "1" {
+		t.Errorf("manifest = %+v, want collection my-api at version 1", manifest)
+	}
+
+	g.Config.CollectionName = "Renamed"
+	os.Remove(filepath.Join(dir, "bruno.json"))
+	if err := g.GenerateCollectionManifest(); err != nil {
+		t.Fatalf("GenerateCollectionManifest() error = %v", err)
+	}
+	if content := readFile(t, filepath.Join(dir, "bruno.json")); !strings.Contains(content, `"name": "Renamed"`) {
+		t.Errorf("bruno.json ignores CollectionName:\n%s", content)
+	}
+}
+
+func TestGenerateCollectionNumbersRequests(t *testing.T) {
+	routes := []*Route{
+		{Name: "Delete User", Method: "DELETE", Path: "/users/:id"},
+		{Name: "List Users", Method: "GET", Path: "/users"},
+		{Name: "Create User", Method: "POST", Path: "/users"},
+	}
+	g := NewBrunoGenerator(t.TempDir(), "api.example.com")
+	g.Config.SortMode = SortMethod
+
+	// Generating twice must not keep counting from the first run
+	for i := 0; i < 2; i++ {
+		if err := g.GenerateCollection(routes); err != nil {
+			t.Fatalf("GenerateCollection() error = %v", err)
+		}
+	}
+
+	expected := map[string]string{"list-users": "seq: 1", "create-user": "seq: 2", "delete-user": "seq: 3"}
+	for file, seq := range expected {
+		if content := readFile(t, filepath.Join(g.OutputDir, file+".bru")); !strings.Contains(content, seq) {
+			t.Errorf("%s.bru missing %q:\n%s", file, seq, content)
+		}
+	}
+}
+
+func TestGenerateCollectionSortsByPath(t *testing.T) {
+	routes := []*Route{
+		{Name: "Delete User", Method: "DELETE", Path: "/users/:id"},
+		{Name: "Create User", Method: "POST", Path: "/users"},
+		{Name: "Ping", Method: "OPTIONS", Path: "/users"},
+		{Name: "List Users", Method: "GET", Path: "/users"},
+		{Name: "Health", Method: "GET", Path: "/health"},
+	}
+
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{SortSource, []string{"delete-user", "create-user", "ping", "list-users", "health"}},
+		{SortPath, []string{"health", "list-users", "create-user", "ping", "delete-user"}},
+	}
+
+	for _, tt := range tests {
+		g := NewBrunoGenerator(t.TempDir(), "api.example.com")
+		g.Config.SortMode = tt.mode
+		if err := g.GenerateCollection(routes); err != nil {
+			t.Fatalf("%s: GenerateCollection() error = %v", tt.mode, err)
+		}
+
+		for i, file := range tt.want {
+			seq := fmt.Sprintf("seq: %d\n", i+1)
+			if content := readFile(t, filepath.Join(g.OutputDir, file+".bru")); !strings.Contains(content, seq) {
+				t.Errorf("%s: %s.bru missing %q:\n%s", tt.mode, file, seq, content)
+			}
+		}
+	}
+}
+
+func TestGenerateCollectionDetectsDuplicateBodies(t *testing.T) {
+	user := &RequestBody{TypeName: "UserRequest", Fields: []RequestBodyField{{Name: "Name", Type: "string", JSONName: "name"}}}
+	routes := []*Route{
+		{Name: "Create User", Method: "POST", Path: "/users", RequestBody: user},
+		{Name: "Create Order", Method: "POST", Path: "/orders", RequestBody: &RequestBody{TypeName: "OrderRequest", Fields: []RequestBodyField{{Name: "Total", Type: "int", JSONName: "total"}}}},
+		{Name: "Replace User", Method: "PUT", Path: "/users/:id", RequestBody: user},
+		{Name: "List Users", Method: "GET", Path: "/users"},
+	}
+
+	g := NewBrunoGenerator(t.TempDir(), "api.example.com")
+	if err := g.GenerateCollection(routes); err != nil {
+		t.Fatalf("GenerateCollection() error = %v", err)
+	}
+	if groups := g.DuplicateBodies(); len(groups) != 0 {
+		t.Errorf("DuplicateBodies() without the option = %v, want none", groups)
+	}
+
+	g = NewBrunoGenerator(t.TempDir(), "api.example.com")
+	g.Config.DetectDuplicateBodies = true
+	// Generating twice, as -watch does, must not count the first run's bodies again
+	for i := 0; i < 2; i++ {
+		if err := g.GenerateCollection(routes); err != nil {
+			t.Fatalf("GenerateCollection() error = %v", err)
+		}
+	}
+	want := [][]string{{"create-user.bru", "replace-user.bru"}}
+	if groups := g.DuplicateBodies(); !reflect.DeepEqual(groups, want) {
+		t.Errorf("DuplicateBodies() = %v, want %v", groups, want)
+	}
+}
+
+func TestGenerateCollectionDryRun(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my-api")
+	g := NewBrunoGenerator(dir, "api.example.com")
+	g.Config.DryRun = true
+	var out bytes.Buffer
+	g.dryRunOut = &out
+
+	routes := []*Route{{Name: "List Users", Method: "GET", Path: "/users", Handler: "ListUsers", Tags: []string{"users"}}}
+	if err := g.GenerateCollection(routes); err != nil {
+		t.Fatalf("GenerateCollection() error = %v", err)
+	}
+
+	// The printed files are the ones that would be written, headers included
+	for _, want := range []string{"users/folder.bru\n" + ProvenanceMarker, "users/list-users.bru\n" + generatedHeader(routes[0], nil)} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("dry run output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("dry run created %s", dir)
+	}
+	// bruno.json, users/folder.bru and users/list-users.bru
+	if got := g.DryRunFileCount(); got != 3 {
+		t.Errorf("DryRunFileCount() = %d, want 3", got)
+	}
+}
+
+func TestGenerateAssertSection(t *testing.T) {
+	g := NewBrunoGenerator(t.TempDir(), "api.example.com")
+	route := &Route{
+		Method: "POST",
+		Path:   "/users",
+		Responses: []ResponseSpec{
+			{StatusCode: 400},
+			{StatusCode: 201, Body: &RequestBody{Fields: []RequestBodyField{
+				{Name: "ID", JSONName: "id", Required: true},
+				{Name: "Name", JSONName: "name"},
+			}}},
+		},
+	}
+
+	want := "assert {\n  res.status: eq 201\n  res.body.id: isDefined\n}"
+	if got := g.GenerateAssertSection(route); got != want {
+		t.Errorf("GenerateAssertSection() = %q, want %q", got, want)
+	}
+
+	route.Responses = []ResponseSpec{{StatusCode: 200, Inferred: true}}
+	if got := g.GenerateAssertSection(route); got != "" {
+		t.Errorf("GenerateAssertSection() with only inferred responses = %q, want none", got)
+	}
+}
+
+func TestGeneratePostResponseScriptSection(t *testing.T) {
+	g := NewBrunoGenerator(t.TempDir(), "api.example.com")
+	login := &Route{Name: "Login", Method: "POST", Path: "/sessions"}
+
+	if got := g.generatePostResponseScriptSection(login); got != "" {
+		t.Errorf("script without -scripts = %q, want none", got)
+	}
+
+	g.Config.Scripts = true
+	if got := g.generatePostResponseScriptSection(login); !strings.Contains(got, `bru.setEnvVar("authToken", res.body.token);`) {
+		t.Errorf("login script doesn't save the token:\n%s", got)
+	}
+	if got := g.generatePostResponseScriptSection(&Route{Name: "List Users", Method: "GET", Path: "/users"}); strings.Contains(got, "\nbru.") {
+		t.Errorf("script of other routes should only hold comments:\n%s", got)
+	}
+}
+
+func TestRequestFileName(t *testing.T) {
+	tests := []struct {
+		route    *Route
+		expected string
+	}{
+		{&Route{Name: "Create User"}, "create-user"},
+		{&Route{Name: "Import / Export: CSV"}, "import-export-csv"},
+		{&Route{Method: "GET", Path: "/users/:id/posts/{postId}"}, "get_users_id_posts_postId"},
+	}
+
+	for _, tt := range tests {
+		if got := requestFileName(tt.route); got != tt.expected {
+			t.Errorf("requestFileName(%+v) = %q, want %q", tt.route, got, tt.expected)
+		}
+	}
+}
+
+func TestGenerateCollectionResolvesFileNameCollisions(t *testing.T) {
+	routes := []*Route{
+		{Name: "Get User", Handler: "GetUser", Method: "GET", Path: "/users/:id"},
+		{Name: "Get user", Handler: "GetUserByEmail", Method: "GET", Path: "/users/by-email"},
+		{Name: "Get User", Handler: "GetLegacyUser", Method: "GET", Path: "/v1/users/:id"},
+	}
+	g := NewBrunoGenerator(t.TempDir(), "api.example.com")
+
+	if err := g.GenerateCollection(routes); err != nil {
+		t.Fatalf("GenerateCollection() error = %v", err)
+	}
+
+	expected := map[string]string{"get-user": "/users/:id", "get-user_2": "/users/by-email", "get-user_3": "/v1/users/:id"}
+	for file, path := range expected {
+		if content := readFile(t, filepath.Join(g.OutputDir, file+".bru")); !strings.Contains(content, path) {
+			t.Errorf("%s.bru should hold the request for %s:\n%s", file, path, content)
+		}
+	}
+}
+
+func TestGenerateRequestFileTagModes(t *testing.T) {
+	route := &Route{Name: "Place Order", Method: "POST", Path: "/orders", Tags: []string{"users", "orders"}}
+
+	for _, mode := range []string{TagModeAlias, TagModeDuplicate} {
+		g := NewBrunoGenerator(t.TempDir(), "api.example.com")
+		g.Config.TagMode = mode
+		if err := g.GenerateRequestFile(route); err != nil {
+			t.Fatalf("%s: GenerateRequestFile() error = %v", mode, err)
+		}
+
+		primary := readFile(t, filepath.Join(g.OutputDir, "users", "place-order.bru"))
+		secondary := readFile(t, filepath.Join(g.OutputDir, "orders", "place-order.bru"))
+		isAlias := strings.Contains(secondary, "Alias of `users/place-order.bru`")
+		if mode == TagModeAlias && !isAlias {
+			t.Errorf("alias mode wrote a copy to orders/:\n%s", secondary)
+		}
+		if mode == TagModeDuplicate && secondary != primary {
+			t.Errorf("duplicate mode wrote\n%s\nwant the primary request\n%s", secondary, primary)
+		}
+	}
+}
+
+func TestGenerateRequestFileKeepsTagsInsideCollection(t *testing.T) {
+	g := NewBrunoGenerator(filepath.Join(t.TempDir(), "collection"), "api.example.com")
+	route := &Route{Name: "Escape", Method: "GET", Path: "/escape", Tags: []string{"../../x", `admin\users`}}
+	if err := g.GenerateRequestFile(route); err != nil {
+		t.Fatalf("GenerateRequestFile() error = %v", err)
+	}
+
+	for _, file := range []string{"x/escape.bru", "admin-users/escape.bru"} {
+		if _, err := os.Stat(filepath.Join(g.OutputDir, file)); err != nil {
+			t.Errorf("%s not written inside the collection: %v", file, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(g.OutputDir, "..", "..", "x")); !os.IsNotExist(err) {
+		t.Errorf("tag ../../x wrote outside the collection")
+	}
+	if path := g.requestFilePath(route); path != "x/escape.bru" {
+		t.Errorf("requestFilePath() = %q, want x/escape.bru", path)
+	}
+}
+
+func TestGenerateRequestFileKeepsEditedFiles(t *testing.T) {
+	route := &Route{Name: "Get User", Method: "GET", Path: "/users/:id", Handler: "GetUser", SourceFile: "handlers/users.go", Responses: []ResponseSpec{{StatusCode: 200}}}
+	g := NewBrunoGenerator(t.TempDir(), "api.example.com")
+	path := filepath.Join(g.OutputDir, "get-user.bru")
+
+	edited := "meta {\n  name: Old\n}\n\nassert {\n  res.status: eq 204\n}\n\ntests {\n  test(\"ok\", function() {});\n}\n"
+	if err := os.WriteFile(path, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	g.Config.Overwrite = false
+	if err := g.GenerateRequestFile(route); err != nil {
+		t.Fatalf("GenerateRequestFile() error = %v", err)
+	}
+	if content := readFile(t, path); content != edited {
+		t.Errorf("-overwrite=false changed the file:\n%s", content)
+	}
+
+	g.Config.Merge = true
+	if err := g.GenerateRequestFile(route); err != nil {
+		t.Fatalf("GenerateRequestFile() error = %v", err)
+	}
+	content := readFile(t, path)
+	if !strings.HasPrefix(content, generatedHeader(route, keptRequestBlocks())) || !strings.Contains(content, "# Handler: GetUser in handlers/users.go\n") {
+		t.Errorf("merged file missing the generated header:\n%s", content)
+	}
+	// Merged files must not claim every edit is overwritten
+	notice := "# Edits to the assert, script:post-response, script:pre-request and tests blocks and to blocks added in Bruno are kept when brungo runs again, the rest is regenerated\n"
+	if !strings.Contains(content, notice) || strings.Contains(content, "Do not edit") {
+		t.Errorf("merged file should say which blocks are kept:\n%s", content)
+	}
+	for _, want := range []string{"name: Get User", "res.status: eq 204", "test(\"ok\"", "get {"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("merged file missing %q:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "eq 200") {
+		t.Errorf("merged file replaced the edited assert block:\n%s", content)
+	}
+}
+
+func TestGenerateCollectionPathFolders(t *testing.T) {
+	routes := []*Route{
+		{Name: "List Posts", Method: "GET", Path: "/api/users/:id/posts"},
+		{Name: "List Users", Method: "GET", Path: "/api/users"},
+		{Name: "Health", Method: "GET", Path: "/api/health", Tags: []string{"ops"}},
+	}
+	g := NewBrunoGenerator(t.TempDir(), "api.example.com")
+	g.Config.PathFolders = true
+	g.DetectPrefixFolder(routes)
+
+	if err := g.GenerateCollection(routes); err != nil {
+		t.Fatalf("GenerateCollection() error = %v", err)
+	}
+
+	for _, file := range []string{
+		"api/users/posts/list-posts.bru",
+		"api/users/list-users.bru",
+		"api/ops/health.bru",
+		"api/users/folder.bru",
+		"api/users/posts/folder.bru",
+	} {
+		if _, err := os.Stat(filepath.Join(g.OutputDir, file)); err != nil {
+			t.Errorf("expected %s to be written: %v", file, err)
+		}
+	}
+
+	if content := readFile(t, filepath.Join(g.OutputDir, "api/users/posts/folder.bru")); !strings.Contains(content, "name: posts") {
+		t.Errorf("folder.bru should name the folder:\n%s", content)
+	}
+}
+
+func TestGenerateFolderFileMergesEditedFolder(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "users")
+	if err := NewBrunoGenerator(filepath.Dir(dir), "").GenerateFolderFile(dir, "people"); err != nil {
+		t.Fatalf("GenerateFolderFile() error = %v", err)
+	}
+
+	// Simulate the user documenting the folder in Bruno.
+	path := filepath.Join(dir, "folder.bru")
+	edited := readFile(t, path) + "\ndocs {\n  Everything about users.\n}\n"
+	if err := os.WriteFile(path, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewBrunoGenerator(filepath.Dir(dir), "").GenerateFolderFile(dir, "users"); err != nil {
+		t.Fatalf("GenerateFolderFile() error = %v", err)
+	}
+
+	content := readFile(t, path)
+	if !strings.Contains(content, "name: users") || strings.Contains(content, "name: people") {
+		t.Errorf("generated meta block was not refreshed:\n%s", content)
+	}
+	if !strings.Contains(content, "Everything about users.") {
+		t.Errorf("hand-written docs block was lost:\n%s", content)
+	}
+	if isUntouched(content) {
+		t.Errorf("merged folder.bru should stay marked as edited")
+	}
+}
+
+func TestGenerateFolderFileOverwritesUntouchedFolder(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "users")
+	if err := NewBrunoGenerator(filepath.Dir(dir), "").GenerateFolderFile(dir, "people"); err != nil {
+		t.Fatalf("GenerateFolderFile() error = %v", err)
+	}
+	if err := NewBrunoGenerator(filepath.Dir(dir), "").GenerateFolderFile(dir, "users"); err != nil {
+		t.Fatalf("GenerateFolderFile() error = %v", err)
+	}
+
+	content := readFile(t, filepath.Join(dir, "folder.bru"))
+	if !isUntouched(content) || !strings.Contains(content, "name: users") {
+		t.Errorf("untouched folder.bru should be regenerated in full:\n%s", content)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+func TestCommonPathPrefix(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		want  string
+	}{
+		{name: "shared prefix", paths: []string{"/api/v1/users", "/api/v1/orders/:id"}, want: "/api/v1"},
+		{name: "stops at path params", paths: []string{"/api/:tenant/users", "/api/:tenant/orders"}, want: "/api"},
+		{name: "nothing shared", paths: []string{"/users", "/orders"}, want: ""},
+		{name: "single route keeps its parent", paths: []string{"/api/v1/users"}, want: "/api/v1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes := []*Route{}
+			for _, path := range tt.paths {
+				routes = append(routes, &Route{Path: path})
+			}
+
+			if got := commonPathPrefix(routes); got != tt.want {
+				t.Errorf("commonPathPrefix() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateRequestFileErrorCarriesRouteContext(t *testing.T) {
+	// A file where the tag folder should go makes writing the request fail
+	outputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outputDir, "users"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	route := &Route{
+		Name:       "List Users",
+		Method:     "GET",
+		Path:       "/users",
+		Handler:    "ListUsers",
+		SourceFile: "handlers/users.go",
+		SourceLine: 42,
+		Tags:       []string{"users"},
+	}
+
+	err := NewBrunoGenerator(outputDir, "api.example.com").GenerateRequestFile(route)
+
+	var generateErr *GenerateError
+	if !errors.As(err, &generateErr) {
+		t.Fatalf("GenerateRequestFile() error = %v, want a *GenerateError", err)
+	}
+	if generateErr.Handler != "ListUsers" || generateErr.Route != "List Users" || generateErr.Section != "folder" {
+		t.Errorf("error context = %+v", generateErr)
+	}
+	if !strings.HasPrefix(err.Error(), "handlers/users.go:42: generating folder for handler ListUsers") {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	if generateErr.Unwrap() == nil {
+		t.Errorf("GenerateError should wrap the underlying error")
+	}
+}
+
+func TestBearerAuthCollectionWiresTokenVariable(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+// @name List Users
+// @route GET /users
+// @auth bearer
+func ListUsers() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	outputDir := t.TempDir()
+	g := NewBrunoGenerator(outputDir, "")
+	g.Config.Environments = []BrunoEnvironment{{Name: "local", BaseURL: "http://localhost:8080"}}
+	if err := g.GenerateCollection(routes); err != nil {
+		t.Fatalf("GenerateCollection() error = %v", err)
+	}
+	if err := g.GenerateEnvironments(); err != nil {
+		t.Fatalf("GenerateEnvironments() error = %v", err)
+	}
+
+	request := readFile(t, filepath.Join(outputDir, "list-users.bru"))
+	for _, want := range []string{"auth: bearer", "auth:bearer {", "token: {{" + BearerTokenVariable + "}}"} {
+		if !strings.Contains(request, want) {
+			t.Errorf("request missing %q:\n%s", want, request)
+		}
+	}
+
+	environment := readFile(t, filepath.Join(outputDir, "environments", "local.bru"))
+	if !strings.Contains(environment, "vars:secret [\n  "+BearerTokenVariable+"\n]") {
+		t.Errorf("environment does not declare the %s secret:\n%s", BearerTokenVariable, environment)
+	}
+}
+
+func TestCollectionVariablesFromPackageComments(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"api.go": `// Package api serves the public API.
+// @var apiVersion=v2
+// @var secret tenantToken
+package api
+`,
+		"users.go": `// @var apiVersion=v3
+package api
+
+// @route GET /{{apiVersion}}/users
+func ListUsers() {}
+`,
+	})
+	captured := captureReport(t)
+
+	parser := NewParser()
+	if _, err := parser.ParseDirectory(dir); err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	want := []CollectionVariable{{Name: "apiVersion", Value: "v2"}, {Name: "tenantToken", Secret: true}}
+	if got := parser.Variables(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Variables() = %+v, want %+v", got, want)
+	}
+	if len(captured.findings) != 1 || !strings.Contains(captured.findings[0].Message, "Variable apiVersion") {
+		t.Errorf("findings = %+v, want a warning about the conflicting apiVersion", captured.findings)
+	}
+
+	g := NewBrunoGenerator(t.TempDir(), "")
+	g.Config.Variables = parser.Variables()
+	g.Config.Environments = []BrunoEnvironment{{Name: "local", BaseURL: "http://localhost:8080"}}
+	if err := g.GenerateCollectionFile(); err != nil {
+		t.Fatalf("GenerateCollectionFile() error = %v", err)
+	}
+	if err := g.GenerateEnvironments(); err != nil {
+		t.Fatalf("GenerateEnvironments() error = %v", err)
+	}
+
+	collection := readFile(t, filepath.Join(g.OutputDir, "collection.bru"))
+	if !strings.Contains(collection, "vars:pre-request {\n  apiVersion: v2\n}\n") || strings.Contains(collection, "tenantToken") {
+		t.Errorf("collection.bru should hold apiVersion only:\n%s", collection)
+	}
+	environment := readFile(t, filepath.Join(g.OutputDir, "environments", "local.bru"))
+	if !strings.Contains(environment, "vars:secret [\n  tenantToken\n]") {
+		t.Errorf("environment does not declare the tenantToken secret:\n%s", environment)
+	}
+}
+
+func TestQueryAPIKeyCollectionWiresKeyVariable(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"reports.go": `package api
+
+// @name Get Report
+// @route GET /reports
+// @auth apikey query api_key
+func GetReport() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	outputDir := t.TempDir()
+	g := NewBrunoGenerator(outputDir, "http://localhost:8080")
+	if err := g.GenerateCollection(routes); err != nil {
+		t.Fatalf("GenerateCollection() error = %v", err)
+	}
+	if err := g.GenerateEnvironments(); err != nil {
+		t.Fatalf("GenerateEnvironments() error = %v", err)
+	}
+
+	request := readFile(t, filepath.Join(outputDir, "get-report.bru"))
+	if want := "url: {{baseUrl}}/reports?api_key={{" + APIKeyVariable + "}}"; !strings.Contains(request, want) {
+		t.Errorf("request missing %q:\n%s", want, request)
+	}
+	if strings.Contains(request, "auth:apikey") {
+		t.Errorf("a query api key should not also be sent as a header:\n%s", request)
+	}
+
+	environment := readFile(t, filepath.Join(outputDir, "environments", DefaultEnvironmentName+".bru"))
+	if !strings.Contains(environment, "vars:secret [\n  "+APIKeyVariable+"\n]") {
+		t.Errorf("environment does not declare the %s secret:\n%s", APIKeyVariable, environment)
+	}
+}
+
+func TestGenerateEnvironmentsAppendsPathPrefix(t *testing.T) {
+	g := NewBrunoGenerator(t.TempDir(), "https://api.example.com")
+	g.Config.Environments = []BrunoEnvironment{
+		{Name: "prod", BaseURL: "https://api.example.com"},
+		{Name: "staging", BaseURL: "https://staging.example.com", BasePath: "/staging"},
+	}
+	if err := g.GenerateEnvironments(); err != nil {
+		t.Fatalf("GenerateEnvironments() error = %v", err)
+	}
+
+	want := map[string]string{"prod": "baseUrl: https://api.example.com\n", "staging": "baseUrl: https://staging.example.com/staging\n"}
+	for name, line := range want {
+		if environment := readFile(t, filepath.Join(g.OutputDir, "environments", name+".bru")); !strings.Contains(environment, line) {
+			t.Errorf("%s environment missing %q:\n%s", name, line, environment)
+		}
+	}
+}
+
+func TestGenerateEnvironmentsDefaultsToBaseURL(t *testing.T) {
+	g := NewBrunoGenerator(t.TempDir(), "https://api.example.com")
+	g.Config.EnvironmentVariables = map[string]string{"tenant": "acme"}
+
+	route := &Route{Name: "List Users", Method: "GET", Path: "/users"}
+	if url := g.requestURL(route); url != "{{baseUrl}}/users" {
+		t.Errorf("requestURL() = %q, want {{baseUrl}}/users", url)
+	}
+
+	if err := g.GenerateEnvironments(); err != nil {
+		t.Fatalf("GenerateEnvironments() error = %v", err)
+	}
+	want := generatedHeader(nil, nil) + "vars {\n  baseUrl: https://api.example.com\n  tenant: acme\n}\n"
+	if environment := readFile(t, filepath.Join(g.OutputDir, "environments", DefaultEnvironmentName+".bru")); environment != want {
+		t.Errorf("environment =\n%s\nwant\n%s", environment, want)
+	}
+}
+
+func TestNoBaseURLWritesRelativeRequests(t *testing.T) {
+	g := NewBrunoGenerator(t.TempDir(), "https://api.example.com")
+	g.Config.NoBaseURL = true
+	g.Config.Environments = []BrunoEnvironment{{Name: "prod", BaseURL: "https://api.example.com"}}
+
+	route := &Route{Name: "Get User", Method: "GET", Path: "/users/:id", Auth: "apikey", AuthOptions: AuthOptions{In: "query", Key: "key"}}
+	if err := g.GenerateRequestFile(route); err != nil {
+		t.Fatalf("GenerateRequestFile() error = %v", err)
+	}
+
+	request := readFile(t, filepath.Join(g.OutputDir, "get-user.bru"))
+	for _, want := range []string{
+		"  url: /users/:id?key={{" + APIKeyVariable + "}}\n",
+		"params:path {\n  id: example\n}\n",
+		"params:query {\n  key: {{" + APIKeyVariable + "}}\n}\n",
+	} {
+		if !strings.Contains(request, want) {
+			t.Errorf("request file missing %q:\n%s", want, request)
+		}
+	}
+	if strings.Contains(request, "{{"+BaseURLVariable+"}}") {
+		t.Errorf("relative request still references the base URL:\n%s", request)
+	}
+}
+
+func TestGenerateOAuth2Section(t *testing.T) {
+	route := &Route{
+		Handler: "GetReport",
+		Auth:    "oauth2",
+		AuthOptions: AuthOptions{
+			TokenURL: "https://auth.example.com/token",
+			AuthURL:  "https://auth.example.com/authorize",
+		},
+		Scopes: []string{"reports:read", "reports:write"},
+	}
+
+	g := NewBrunoGenerator(t.TempDir(), "")
+	got := g.generateAuthSection(route)
+	want := `auth:oauth2 {
+  grant_type: authorization_code
+  callback_url:
+  authorization_url: https://auth.example.com/authorize
+  access_token_url: https://auth.example.com/token
+  client_id: {{clientId}}
+  client_secret: {{clientSecret}}
+  scope: reports:read reports:write
+}`
+	if got != want {
+		t.Errorf("generateAuthSection() =\n%s\nwant\n%s", got, want)
+	}
+	if !g.secretVars[OAuth2ClientIDVariable] || !g.secretVars[OAuth2ClientSecretVariable] {
+		t.Errorf("client credentials should be declared as secrets, got %v", g.secretVars)
+	}
+}
+
+func TestGenerateBasicAuthSection(t *testing.T) {
+	g := NewBrunoGenerator(t.TempDir(), "")
+	got := g.generateAuthSection(&Route{Handler: "Login", Auth: "basic"})
+	want := `auth:basic {
+  username: {{username}}
+  password: {{password}}
+}`
+	if got != want {
+		t.Errorf("generateAuthSection() =\n%s\nwant\n%s", got, want)
+	}
+	if !g.secretVars[BasicUsernameVariable] || !g.secretVars[BasicPasswordVariable] {
+		t.Errorf("basic credentials should be declared as secrets, got %v", g.secretVars)
+	}
+}
+
+func TestCollectionIndexGroupsRoutesByTag(t *testing.T) {
+	routes := []*Route{
+		{Method: "GET", Path: "/users", Tags: []string{"users"}, Description: "Lists users\nPaginated by cursor"},
+		{Method: "GET", Path: "/health", Name: "Health"},
+		{Method: "POST", Path: "/users/:id/orders", Tags: []string{"users", "orders"}, Description: "Places an order"},
+	}
+
+	got := collectionIndex(routes, "https://api.example.com", []string{BearerTokenVariable, "webhookSecret"}, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	want := "# API index\n\n" +
+		"Base URL: https://api.example.com\n" +
+		"Generated: 2024-05-01T12:00:00Z\n\n" +
+		"## Secrets\n\n" +
+		"Fill these in as secret variables of each environment:\n\n" +
+		"- `authToken`: token sent by `@auth bearer` requests\n" +
+		"- `webhookSecret`: declared with `@var secret`\n\n" +
+		"## orders\n\n" +
+		"- `POST /users/:id/orders` Places an order\n\n" +
+		"## users\n\n" +
+		"- `GET /users` Lists users\n" +
+		"- `POST /users/:id/orders` Places an order\n\n" +
+		"## Other\n\n" +
+		"- `GET /health` Health"
+	if got != want {
+		t.Errorf("collectionIndex() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// configFileNames are the config files searched for, in order of preference
+var configFileNames = []string{"brungo.yaml", "brungo.yml", ".brungo.json"}
+
+// loadConfig fills in the flags that weren't given on the command line from a config file,
+// either the given path or the first one found walking up from the current directory.
+// Returns the path of the file used, empty when there was none.
+func loadConfig(flags *flag.FlagSet, path string) (string, error) {
+	if path == "" {
+		dir, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		if path, err = findConfigFile(dir); err != nil || path == "" {
+			return "", err
+		}
+	}
+
+	values, err := readConfigFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading config file %s: %w", path, err)
+	}
+	if err := applyConfig(flags, values); err != nil {
+		return "", fmt.Errorf("config file %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// findConfigFile looks for a config file in dir and its parents, stopping at the
+// repository root (the first directory holding .git)
+func findConfigFile(dir string) (string, error) {
+	for {
+		for _, name := range configFileNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return "", err
+			}
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// readConfigFile reads a config file into flag values keyed by flag name. JSON files hold
+// one object, YAML files one key: value pair per line.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return parseJSONConfig(data)
+	}
+	return parseYAMLConfig(data)
+}
+
+// parseJSONConfig reads a JSON object of flag values. Lists are joined with commas, the way
+// list flags such as -format take them.
+func parseJSONConfig(data []byte) (map[string]string, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for key, value := range raw {
+		switch v := value.(type) {
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		case map[string]interface{}, nil:
+			return nil, fmt.Errorf("%s must be a string, number, bool or list", key)
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return values, nil
+}
+
+// parseYAMLConfig reads flat key: value lines, skipping blank lines and # comments.
+// Values may be quoted; nested blocks aren't supported.
+func parseYAMLConfig(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	for i, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+
+		key, value, found := strings.Cut(trimmed, ":")
+		if !found || line != strings.TrimLeft(line, " \t") {
+			return nil, fmt.Errorf("line %d: expected key: value", i+1)
+		}
+
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, `"`):
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			value = unquoted
+		case strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") && len(value) > 1:
+			value = strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		default:
+			if comment := strings.Index(value, " #"); comment >= 0 {
+				value = strings.TrimSpace(value[:comment])
+			}
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return values, nil
+}
+
+// applyConfig sets each flag the command line left unset to its config value
+func applyConfig(flags *flag.FlagSet, values map[string]string) error {
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if flags.Lookup(key) == nil || key == "config" {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		if given[key] {
+			continue
+		}
+		if err := flags.Set(key, values[key]); err != nil {
+			return fmt.Errorf("invalid %s: %v", key, err)
+		}
+	}
+	return nil
+}
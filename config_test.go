@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigKeepsCommandLineFlags(t *testing.T) {
+	repo := t.TempDir()
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := `# shared settings
+input: ./api
+output: "./collections/api"
+url: 'https://api.example.com'
+log-level: debug # chatty
+`
+	if err := os.WriteFile(filepath.Join(repo, "brungo.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(repo, "services", "users")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := findConfigFile(nested)
+	if err != nil || path != filepath.Join(repo, "brungo.yaml") {
+		t.Fatalf("findConfigFile() = %q, %v, want the repository's brungo.yaml", path, err)
+	}
+
+	flags := flag.NewFlagSet("brungo", flag.ContinueOnError)
+	input := flags.String("input", ".", "")
+	output := flags.String("output", "./bruno", "")
+	url := flags.String("url", DefaultBaseURL, "")
+	logLevel := flags.String("log-level", "info", "")
+	framework := flags.String("framework", "", "")
+	if err := flags.Parse([]string{"-output", "./out"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadConfig(flags, path); err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	got := []string{*input, *output, *url, *logLevel, *framework}
+	want := []string{"./api", "./out", "https://api.example.com", "debug", ""}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("flags = %q, want %q", got, want)
+			break
+		}
+	}
+
+	if err := applyConfig(flags, map[string]string{"inputs": "."}); err == nil {
+		t.Error("applyConfig() accepted an unknown setting")
+	}
+}
+
+func TestFindConfigFileStopsAtRepositoryRoot(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, ".brungo.json"), []byte(`{"input": "."}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	repo := filepath.Join(outside, "repo")
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if path, err := findConfigFile(repo); err != nil || path != "" {
+		t.Errorf("findConfigFile() = %q, %v, want no config outside the repository", path, err)
+	}
+	if path, err := findConfigFile(outside); err != nil || path != filepath.Join(outside, ".brungo.json") {
+		t.Errorf("findConfigFile() = %q, %v, want %s", path, err, ".brungo.json")
+	}
+}
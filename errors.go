@@ -0,0 +1,45 @@
+package main
+
+import "fmt"
+
+// GenerateError reports which route and output section a generator failure belongs to
+type GenerateError struct {
+	Route      string // Route name from @name
+	Handler    string // Name of the handler function
+	SourceFile string // File the handler is declared in
+	SourceLine int    // Line of the handler declaration
+	Section    string // Section being generated, e.g. body or docs
+	Err        error
+}
+
+// newGenerateError wraps err with the context of the route and section that failed
+func newGenerateError(route *Route, section string, err error) *GenerateError {
+	return &GenerateError{
+		Route:      route.Name,
+		Handler:    route.Handler,
+		SourceFile: route.SourceFile,
+		SourceLine: route.SourceLine,
+		Section:    section,
+		Err:        err,
+	}
+}
+
+func (e *GenerateError) Error() string {
+	return fmt.Sprintf("%s:%d: generating %s for handler %s: %v", e.SourceFile, e.SourceLine, e.Section, e.Handler, e.Err)
+}
+
+func (e *GenerateError) Unwrap() error {
+	return e.Err
+}
+
+// Diagnostic is a problem found in a handler's annotations, such as a malformed @route
+type Diagnostic struct {
+	File    string
+	Line    int
+	Handler string
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %s", d.File, d.Line, d.Message)
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AdditionalPropertyKey is the example key shown for inline maps of extra properties.
+const AdditionalPropertyKey = "additionalProperty"
+
+// TruncatedBodyKey is added to example bodies that were cut short by the size guard.
+const TruncatedBodyKey = "_truncated"
+
+// exampleOptions controls how a single example body is rendered
+type exampleOptions struct {
+	budget    *bodyBudget
+	flatten   bool // Use dotted keys for nested structs instead of nested objects
+	itemCount int  // Number of example items in the body's top-level arrays
+	formNames bool // Key fields by their form tag where they have one
+	required  bool // Leave out omitempty fields that aren't required
+}
+
+// newExampleOptions creates the options for rendering one example body
+func (g *BrunoGenerator) newExampleOptions() *exampleOptions {
+	return &exampleOptions{
+		budget:    newBodyBudget(g.Config.MaxBodyBytes),
+		flatten:   g.Config.FlattenBody,
+		itemCount: 1,
+	}
+}
+
+// newRouteExampleOptions creates the options for a route's request body, honoring @bulk
+func (g *BrunoGenerator) newRouteExampleOptions(route *Route) *exampleOptions {
+	opts := g.newExampleOptions()
+	opts.required = g.Config.OmitOptional
+	if route.Bulk != nil {
+		opts.itemCount = route.Bulk.ItemCount
+	}
+	return opts
+}
+
+// exampleBody builds an example JSON object for a request or response body,
+// stopping early with a note once the budget is exhausted
+func exampleBody(requestBody *RequestBody, opts *exampleOptions) map[string]interface{} {
+	body := make(map[string]interface{})
+	if !addExampleFields(body, "", requestBody, opts) {
+		body[TruncatedBodyKey] = fmt.Sprintf("example body exceeded %d bytes, remaining fields omitted", opts.budget.limit)
+	}
+	return body
+}
+
+// addExampleFields adds the example values of a body's fields to an object, prefixing
+// keys when nested structs are being flattened. Returns false once the budget runs out.
+func addExampleFields(body map[string]interface{}, prefix string, requestBody *RequestBody, opts *exampleOptions) bool {
+	for _, field := range requestBody.Fields {
+		if opts.required && field.OmitEmpty && !field.Required {
+			continue
+		}
+
+		value := fieldExampleValue(field)
+		key := prefix + exampleKey(field, opts)
+
+		// Inline maps contribute their entries to the parent object rather than a nested key
+		if field.Inline {
+			if !isAdditionalProperties(field) {
+				continue
+			}
+			key = prefix + AdditionalPropertyKey
+			value = defaultValueForType(field.MapValueType)
+		}
+
+		if field.Type == "array" {
+			// Only the wrapper's own arrays get the bulk item count
+			itemCount := 1
+			if prefix == "" {
+				itemCount = opts.itemCount
+			}
+
+			items, ok := exampleItems(field, itemCount, opts)
+			if !ok {
+				return false
+			}
+			body[key] = items
+			continue
+		}
+
+		if field.Type == "map" && !field.Inline {
+			entries, ok := exampleMap(field, opts)
+			if !ok {
+				return false
+			}
+			body[key] = entries
+			continue
+		}
+
+		if field.Nested != nil {
+			if opts.flatten {
+				if !addExampleFields(body, key+".", field.Nested, opts) {
+					return false
+				}
+				continue
+			}
+
+			nested := make(map[string]interface{})
+			if !opts.budget.spend(key, nested) {
+				return false
+			}
+			body[key] = nested
+			if !addExampleFields(nested, "", field.Nested, opts) {
+				return false
+			}
+			continue
+		}
+
+		if !opts.budget.spend(key, value) {
+			return false
+		}
+		body[key] = value
+	}
+	return true
+}
+
+// exampleKey returns the key a field is shown under
+func exampleKey(field RequestBodyField, opts *exampleOptions) string {
+	if opts.formNames {
+		return formFieldName(field)
+	}
+	return field.JSONName
+}
+
+// formFieldName returns the name a field is sent under in form and multipart bodies
+func formFieldName(field RequestBodyField) string {
+	if field.FormName != "" {
+		return field.FormName
+	}
+	return field.JSONName
+}
+
+// exampleItems builds the example elements of an array field. Arrays whose element
+// type is unknown stay empty.
+func exampleItems(field RequestBodyField, itemCount int, opts *exampleOptions) ([]interface{}, bool) {
+	items := []interface{}{}
+	if field.Nested == nil && defaultValueForType(field.ElemType) == nil {
+		return items, opts.budget.spend(field.JSONName, items)
+	}
+
+	for i := 0; i < itemCount; i++ {
+		if field.Nested != nil {
+			item := make(map[string]interface{})
+			if !opts.budget.spend(field.JSONName, item) {
+				return items, false
+			}
+			// Array elements are always whole objects, even when flattening, and only
+			// the wrapper's own arrays get the bulk item count
+			nestedOpts := *opts
+			nestedOpts.flatten = false
+			nestedOpts.itemCount = 1
+			if !addExampleFields(item, "", field.Nested, &nestedOpts) {
+				return append(items, item), false
+			}
+			items = append(items, item)
+			continue
+		}
+
+		value := defaultValueForType(field.ElemType)
+		if !opts.budget.spend(field.JSONName, value) {
+			return items, false
+		}
+		items = append(items, value)
+	}
+
+	return items, true
+}
+
+// exampleMap builds the example of a map field, one entry keyed by an example of the map's key
+// type. Maps whose value type is unknown stay empty.
+func exampleMap(field RequestBodyField, opts *exampleOptions) (map[string]interface{}, bool) {
+	entries := make(map[string]interface{})
+	if !opts.budget.spend(field.JSONName, entries) {
+		return entries, false
+	}
+
+	key := mapExampleKey(field.MapKeyType)
+	if field.Nested != nil {
+		// Map values are always whole objects, even when flattening
+		value := make(map[string]interface{})
+		if !opts.budget.spend(key, value) {
+			return entries, false
+		}
+		entries[key] = value
+		nestedOpts := *opts
+		nestedOpts.flatten = false
+		return entries, addExampleFields(value, "", field.Nested, &nestedOpts)
+	}
+
+	value := defaultValueForType(field.MapValueType)
+	if value == nil {
+		return entries, true
+	}
+	if !opts.budget.spend(key, value) {
+		return entries, false
+	}
+	entries[key] = value
+	return entries, true
+}
+
+// mapExampleKey returns the example key of a map. encoding/json writes every key as a string,
+// so keys of other types are written as their example value's text, e.g. "0" for int keys.
+func mapExampleKey(keyType string) string {
+	switch value := defaultValueForType(keyType).(type) {
+	case string, nil:
+		return "key"
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// bodyBudget tracks how many bytes of example body are left to render
+type bodyBudget struct {
+	limit     int
+	remaining int
+	truncated bool
+}
+
+// newBodyBudget creates a budget for a single body, a limit of 0 means unlimited
+func newBodyBudget(limit int) *bodyBudget {
+	return &bodyBudget{
+		limit:     limit,
+		remaining: limit,
+	}
+}
+
+// spend reserves room for a key/value pair, returning false once the budget is exhausted
+func (b *bodyBudget) spend(key string, value interface{}) bool {
+	if b.limit <= 0 {
+		return true
+	}
+	if b.truncated {
+		return false
+	}
+
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return true
+	}
+
+	// Account for the quoted key, colon, separator and indentation.
+	size := len(key) + len(jsonBytes) + 4 + len(JSONOutputIndent)
+	if size > b.remaining {
+		b.truncated = true
+		return false
+	}
+
+	b.remaining -= size
+	return true
+}
+
+// wellKnownTypes maps common standard and third-party types onto the value they marshal to,
+// so they render sensibly instead of as their internals. They are consulted before any
+// struct lookup.
+var wellKnownTypes = map[string]interface{}{
+	"url.URL":         "https://example.com",
+	"net.IP":          "192.0.2.1",
+	"netip.Addr":      "192.0.2.1",
+	"mail.Address":    "user@example.com",
+	"uuid.UUID":       "00000000-0000-0000-0000-000000000000",
+	"big.Int":         json.Number("12345678901234567890"),
+	"big.Float":       json.Number("3.14159"),
+	"decimal.Decimal": "0",
+	"time.Time":       "2006-01-02T15:04:05Z",
+	"time.Duration":   1000000000,
+	"json.RawMessage": map[string]interface{}{},
+}
+
+// RegisterWellKnownType sets the example value used for a package-qualified type such as
+// "money.Amount", overriding any built-in mapping
+func RegisterWellKnownType(typeName string, example interface{}) {
+	wellKnownTypes[typeName] = example
+}
+
+// parseTypeExample reads the example of a -type-examples entry, a JSON value such as 0 or
+// {} when it parses as one and plain text otherwise
+func parseTypeExample(text string) interface{} {
+	var example interface{}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	if err := decoder.Decode(&example); err == nil && !decoder.More() {
+		return example
+	}
+	return text
+}
+
+// isWellKnownType reports whether a type has a registered example value
+func isWellKnownType(typeName string) bool {
+	_, ok := wellKnownTypes[typeName]
+	return ok
+}
+
+// validationFormatExamples are the example values for validator format rules
+var validationFormatExamples = map[string]string{
+	"email":    "user@example.com",
+	"url":      "https://example.com",
+	"http_url": "https://example.com",
+	"uri":      "https://example.com",
+	"uuid":     "123e4567-e89b-12d3-a456-426614174000",
+	"uuid4":    "123e4567-e89b-42d3-a456-426614174000",
+	"ip":       "192.0.2.1",
+	"ipv4":     "192.0.2.1",
+	"datetime": "2006-01-02T15:04:05Z",
+}
+
+// fieldExampleValue returns the example value of a field: its example tag when it has one,
+// otherwise one that satisfies its validator rules where they allow it: the first oneof
+// value, the format's example, or the minimum of a number
+func fieldExampleValue(field RequestBodyField) interface{} {
+	if field.Example != "" {
+		return typedExampleValue(field.Type, field.Example)
+	}
+
+	value := defaultValueForType(field.Type)
+	validation := field.Validation
+	if validation == nil {
+		return value
+	}
+
+	if len(validation.OneOf) > 0 {
+		return typedExampleValue(field.Type, validation.OneOf[0])
+	}
+	if example, ok := validationFormatExamples[validation.Format]; ok && field.Type == "string" {
+		return example
+	}
+	if _, numeric := value.(int); numeric && validation.Min != "" {
+		return typedExampleValue(field.Type, validation.Min)
+	}
+	return value
+}
+
+// typedExampleValue converts a value written in a tag to the field's type, keeping
+// the text when it doesn't parse
+func typedExampleValue(fieldType, text string) interface{} {
+	switch fieldType {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte", "rune":
+		if number, err := strconv.Atoi(text); err == nil {
+			return number
+		}
+	case "float64", "float32":
+		if number, err := strconv.ParseFloat(text, 64); err == nil {
+			return number
+		}
+	case "bool":
+		if value, err := strconv.ParseBool(text); err == nil {
+			return value
+		}
+	}
+	return text
+}
+
+// defaultValueForType generates a default example value based on field type
+func defaultValueForType(fieldType string) interface{} {
+	if example, ok := wellKnownTypes[fieldType]; ok {
+		return example
+	}
+
+	switch strings.ToLower(fieldType) {
+	case "string":
+		return ""
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte", "rune",
+		"float32", "float64":
+		return 0
+	case "bool":
+		return false
+	case "array", "slice":
+		return []interface{}{}
+	case "map":
+		return map[string]interface{}{}
+	default:
+		return nil
+	}
+}
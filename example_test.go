@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// addressBody builds a two-level nested body: Address -> Geo
+func addressBody() *RequestBody {
+	geo := &RequestBody{
+		TypeName: "Geo",
+		Fields: []RequestBodyField{
+			{Name: "Lat", Type: "float64", JSONName: "lat"},
+			{Name: "Lng", Type: "float64", JSONName: "lng"},
+		},
+	}
+	address := &RequestBody{
+		TypeName: "Address",
+		Fields: []RequestBodyField{
+			{Name: "City", Type: "string", JSONName: "city"},
+			{Name: "Geo", Type: "Geo", JSONName: "geo", Nested: geo},
+		},
+	}
+	return &RequestBody{
+		TypeName: "CreateUserRequest",
+		Fields: []RequestBodyField{
+			{Name: "Name", Type: "string", JSONName: "name"},
+			{Name: "Address", Type: "Address", JSONName: "address", Nested: address},
+		},
+	}
+}
+
+func TestExampleBodyNested(t *testing.T) {
+	opts := &exampleOptions{budget: newBodyBudget(0)}
+
+	got := exampleBody(addressBody(), opts)
+	want := map[string]interface{}{
+		"name": "",
+		"address": map[string]interface{}{
+			"city": "",
+			"geo": map[string]interface{}{
+				"lat": 0,
+				"lng": 0,
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exampleBody() = %v, want %v", got, want)
+	}
+}
+
+func TestExampleBodyFlattenMultiLevel(t *testing.T) {
+	opts := &exampleOptions{budget: newBodyBudget(0), flatten: true}
+
+	got := exampleBody(addressBody(), opts)
+	want := map[string]interface{}{
+		"name":            "",
+		"address.city":    "",
+		"address.geo.lat": 0,
+		"address.geo.lng": 0,
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exampleBody() = %v, want %v", got, want)
+	}
+}
+
+func TestExampleBodyWellKnownTypes(t *testing.T) {
+	RegisterWellKnownType("money.Amount", "9.99 EUR")
+	defer delete(wellKnownTypes, "money.Amount")
+
+	body := &RequestBody{
+		TypeName: "Payment",
+		Fields: []RequestBodyField{
+			{Name: "ID", Type: "uuid.UUID", JSONName: "id"},
+			{Name: "Callback", Type: "url.URL", JSONName: "callback"},
+			{Name: "Price", Type: "money.Amount", JSONName: "price"},
+		},
+	}
+
+	got := exampleBody(body, &exampleOptions{budget: newBodyBudget(0)})
+	want := map[string]interface{}{
+		"id":       "00000000-0000-0000-0000-000000000000",
+		"callback": "https://example.com",
+		"price":    "9.99 EUR",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exampleBody() = %v, want %v", got, want)
+	}
+}
+
+func TestExampleBodyExampleTags(t *testing.T) {
+	body := &RequestBody{
+		TypeName: "Signup",
+		Fields: []RequestBodyField{
+			{Name: "Email", Type: "string", JSONName: "email", Example: "jane@acme.com", Validation: &FieldValidation{Format: "email"}},
+			{Name: "Age", Type: "int", JSONName: "age", Example: "42"},
+			{Name: "Score", Type: "float64", JSONName: "score", Example: "9.5"},
+			{Name: "Admin", Type: "bool", JSONName: "admin", Example: "true"},
+			{Name: "Count", Type: "int", JSONName: "count", Example: "many"}, // kept as text
+		},
+	}
+
+	got := exampleBody(body, &exampleOptions{budget: newBodyBudget(0)})
+	want := map[string]interface{}{
+		"email": "jane@acme.com",
+		"age":   42,
+		"score": 9.5,
+		"admin": true,
+		"count": "many",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exampleBody() = %v, want %v", got, want)
+	}
+}
+
+func TestParseTypeExample(t *testing.T) {
+	tests := map[string]interface{}{
+		"9.99":           json.Number("9.99"),
+		`"2006-01-02"`:   "2006-01-02",
+		"{}":             map[string]interface{}{},
+		"9.99 EUR":       "9.99 EUR",
+		"https://a.test": "https://a.test",
+	}
+	for text, want := range tests {
+		if got := parseTypeExample(text); !reflect.DeepEqual(got, want) {
+			t.Errorf("parseTypeExample(%q) = %#v, want %#v", text, got, want)
+		}
+	}
+}
+
+func TestDefaultValueForType(t *testing.T) {
+	tests := map[string]interface{}{
+		"string": "",
+		"bool":   false,
+		"map":    map[string]interface{}{},
+		"Widget": nil,
+	}
+	for _, numeric := range []string{"int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte", "rune", "float32", "float64"} {
+		tests[numeric] = 0
+	}
+	for fieldType, want := range tests {
+		if got := defaultValueForType(fieldType); !reflect.DeepEqual(got, want) {
+			t.Errorf("defaultValueForType(%q) = %#v, want %#v", fieldType, got, want)
+		}
+	}
+
+	// Numeric defaults take their minimum, whatever the width of the field
+	field := RequestBodyField{Name: "Retries", Type: "uint8", Validation: &FieldValidation{Min: "3"}}
+	if got := fieldExampleValue(field); got != 3 {
+		t.Errorf("uint8 example = %#v, want 3", got)
+	}
+}
+
+func TestExampleBodyValidationRules(t *testing.T) {
+	body := &RequestBody{
+		TypeName: "Signup",
+		Fields: []RequestBodyField{
+			{Name: "Email", Type: "string", JSONName: "email", Validation: parseValidationRules("required,email", nil)},
+			{Name: "Plan", Type: "string", JSONName: "plan", Validation: parseValidationRules("oneof=free pro team", nil)},
+			{Name: "Seats", Type: "int", JSONName: "seats", Validation: parseValidationRules("min=3,max=20", nil)},
+			{Name: "Tags", Type: "array", ElemType: "string", JSONName: "tags", Validation: parseValidationRules("max=5,dive,email", nil)},
+			{Name: "Name", Type: "string", JSONName: "name", Validation: parseValidationRules("required", nil)},
+		},
+	}
+
+	got := exampleBody(body, &exampleOptions{budget: newBodyBudget(0), itemCount: 1})
+	want := map[string]interface{}{
+		"email": "user@example.com",
+		"plan":  "free",
+		"seats": 3,
+		"tags":  []interface{}{""},
+		"name":  "",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exampleBody() = %v, want %v", got, want)
+	}
+	if body.Fields[4].Validation != nil {
+		t.Errorf("a tag with only required should not record a validation")
+	}
+}
+
+func TestExampleBodyOmitsOptionalFields(t *testing.T) {
+	body := &RequestBody{Fields: []RequestBodyField{
+		{Name: "Name", Type: "string", JSONName: "name", Required: true, OmitEmpty: true},
+		{Name: "Nickname", Type: "string", JSONName: "nickname", OmitEmpty: true},
+		{Name: "Age", Type: "int", JSONName: "age"},
+	}}
+
+	got := exampleBody(body, &exampleOptions{budget: newBodyBudget(0), required: true})
+	want := map[string]interface{}{"name": "", "age": 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exampleBody() = %v, want %v", got, want)
+	}
+}
+
+func TestExampleBodyMapEntries(t *testing.T) {
+	limits := &RequestBody{TypeName: "Limit", Fields: []RequestBodyField{
+		{Name: "Max", Type: "int", JSONName: "max"},
+	}}
+	body := &RequestBody{Fields: []RequestBodyField{
+		{Name: "Counts", Type: "map", JSONName: "counts", MapKeyType: "string", MapValueType: "int"},
+		{Name: "ByID", Type: "map", JSONName: "by_id", MapKeyType: "int", MapValueType: "string"},
+		{Name: "Limits", Type: "map", JSONName: "limits", MapKeyType: "string", MapValueType: "Limit", Nested: limits},
+		{Name: "Extra", Type: "map", JSONName: "extra", MapKeyType: "string", MapValueType: "unknown"},
+	}}
+
+	got := exampleBody(body, &exampleOptions{budget: newBodyBudget(0)})
+	want := map[string]interface{}{
+		"counts": map[string]interface{}{"key": 0},
+		"by_id":  map[string]interface{}{"0": ""},
+		"limits": map[string]interface{}{"key": map[string]interface{}{"max": 0}},
+		"extra":  map[string]interface{}{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exampleBody() = %v, want %v", got, want)
+	}
+}
+
+func TestExampleBodyTruncatesAtMaxBodyBytes(t *testing.T) {
+	requestBody := &RequestBody{TypeName: "Report"}
+	for _, name := range []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot"} {
+		requestBody.Fields = append(requestBody.Fields, RequestBodyField{Name: name, Type: "string", JSONName: name})
+	}
+
+	opts := &exampleOptions{budget: newBodyBudget(40)}
+	got := exampleBody(requestBody, opts)
+	if !opts.budget.truncated || got[TruncatedBodyKey] == nil {
+		t.Fatalf("exampleBody() = %v, want it truncated with a note", got)
+	}
+	if _, ok := got["alpha"]; !ok || len(got) >= len(requestBody.Fields)+1 {
+		t.Errorf("exampleBody() = %v, want the first fields kept and the rest omitted", got)
+	}
+
+	// The default limit leaves ordinary bodies alone
+	opts = &exampleOptions{budget: newBodyBudget(DefaultMaxBodyBytes)}
+	if got := exampleBody(requestBody, opts); opts.budget.truncated || len(got) != len(requestBody.Fields) {
+		t.Errorf("exampleBody() with the default limit = %v", got)
+	}
+}
+
+func TestExampleBodyBulkItemCount(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+type UserItem struct {
+	Name  string   ` + "`json:\"name\"`" + `
+	Roles []string ` + "`json:\"roles\"`" + `
+}
+
+type BulkUsersRequest struct {
+	Items []UserItem ` + "`json:\"items\"`" + `
+}
+
+// @route POST /users/bulk
+// @body BulkUsersRequest
+// @bulk itemCount=3
+func BulkCreateUsers() {}
+
+// @route PUT /users/bulk
+// @body BulkUsersRequest
+// @bulk
+func BulkUpdateUsers() {}
+`,
+	})
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	g := NewBrunoGenerator(t.TempDir(), "api.example.com")
+	item := map[string]interface{}{"name": "", "roles": []interface{}{""}}
+	for _, route := range routes {
+		wantCount := 3
+		if route.Handler == "BulkUpdateUsers" {
+			wantCount = 1 // @bulk without a count falls back to one item
+		}
+		if route.Bulk == nil || route.Bulk.ItemCount != wantCount {
+			t.Fatalf("%s bulk = %+v, want %d items", route.Handler, route.Bulk, wantCount)
+		}
+
+		want := map[string]interface{}{"items": []interface{}{}}
+		for i := 0; i < wantCount; i++ {
+			want["items"] = append(want["items"].([]interface{}), item)
+		}
+		// Arrays nested inside each item keep a single element
+		if got := exampleBody(route.RequestBody, g.newRouteExampleOptions(route)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s exampleBody() = %v, want %v", route.Handler, got, want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import "strings"
+
+// routeFilter reports whether a route should be generated
+type routeFilter func(route *Route) bool
+
+// allFilters combines filters into one that keeps the routes every filter keeps
+func allFilters(filters ...routeFilter) routeFilter {
+	return func(route *Route) bool {
+		for _, filter := range filters {
+			if !filter(route) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// methodFilter keeps the routes using one of the given methods, or every route when none are given
+func methodFilter(methods []string) routeFilter {
+	return func(route *Route) bool {
+		if len(methods) == 0 {
+			return true
+		}
+		for _, method := range methods {
+			if strings.EqualFold(route.Method, method) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// includeTagFilter keeps the routes with at least one of the given tags, or every route when none are given
+func includeTagFilter(tags []string) routeFilter {
+	return func(route *Route) bool {
+		return len(tags) == 0 || hasAnyTag(route, tags)
+	}
+}
+
+// excludeTagFilter drops the routes with any of the given tags
+func excludeTagFilter(tags []string) routeFilter {
+	return func(route *Route) bool {
+		return !hasAnyTag(route, tags)
+	}
+}
+
+// hasAnyTag reports whether a route has one of the given tags. Labels match by their key
+// or as key:value.
+func hasAnyTag(route *Route, tags []string) bool {
+	for _, tag := range tags {
+		for _, routeTag := range requestTags(route) {
+			if strings.EqualFold(routeTag, tag) {
+				return true
+			}
+		}
+		for key := range route.Labels {
+			if strings.EqualFold(key, tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deprecationFilter drops deprecated routes when skip is set
+func deprecationFilter(skip bool) routeFilter {
+	return func(route *Route) bool {
+		return !skip || !route.Deprecated
+	}
+}
+
+// filterRoutes returns the routes the filter keeps, in their original order
+func filterRoutes(routes []*Route, filter routeFilter) []*Route {
+	kept := []*Route{}
+	for _, route := range routes {
+		if filter(route) {
+			kept = append(kept, route)
+		}
+	}
+	return kept
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(list string) []string {
+	values := []string{}
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterRoutes(t *testing.T) {
+	routes := []*Route{
+		{Handler: "ListUsers", Method: "GET", Tags: []string{"users"}},
+		{Handler: "DeleteUser", Method: "DELETE", Tags: []string{"users"}},
+		{Handler: "Reindex", Method: "POST", Labels: map[string]string{"visibility": "internal"}},
+		{Handler: "Health", Method: "GET", Tags: []string{"internal"}},
+	}
+
+	filter := allFilters(
+		methodFilter(splitList("get, post")),
+		excludeTagFilter(splitList("internal,visibility:internal")),
+	)
+
+	handlers := []string{}
+	for _, route := range filterRoutes(routes, filter) {
+		handlers = append(handlers, route.Handler)
+	}
+	if want := []string{"ListUsers"}; !reflect.DeepEqual(handlers, want) {
+		t.Errorf("filterRoutes() kept %v, want %v", handlers, want)
+	}
+
+	if kept := filterRoutes(routes, includeTagFilter(splitList("visibility"))); len(kept) != 1 || kept[0].Handler != "Reindex" {
+		t.Errorf("include by label key kept %+v", kept)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// frameworkRouter describes how a router framework registers its routes
+type frameworkRouter struct {
+	methods  map[string]bool // Calls named after the method they register, e.g. r.GET("/users", ListUsers)
+	handle   map[string]bool // Calls taking the method as their first argument, e.g. r.Handle("GET", "/users", ListUsers)
+	patterns map[string]bool // Calls taking a "METHOD /path" pattern, e.g. mux.HandleFunc("GET /users", ListUsers)
+}
+
+// frameworkRouters are the router frameworks whose registrations -framework can discover
+var frameworkRouters = map[string]frameworkRouter{
+	"gin": {
+		methods: map[string]bool{"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "HEAD": true, "OPTIONS": true},
+		handle:  map[string]bool{"Handle": true},
+	},
+	"chi": {
+		methods: map[string]bool{"Get": true, "Post": true, "Put": true, "Patch": true, "Delete": true, "Head": true, "Options": true, "Connect": true, "Trace": true},
+		handle:  map[string]bool{"Method": true, "MethodFunc": true},
+	},
+	"stdlib": {
+		patterns: map[string]bool{"Handle": true, "HandleFunc": true},
+	},
+	"echo": {
+		methods: map[string]bool{"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "HEAD": true, "OPTIONS": true, "CONNECT": true, "TRACE": true},
+		handle:  map[string]bool{"Add": true},
+	},
+}
+
+// frameworkNames lists the supported frameworks, for flag help and errors
+func frameworkNames() []string {
+	names := make([]string, 0, len(frameworkRouters))
+	for name := range frameworkRouters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateFramework checks a -framework value, an empty one disables discovery
+func validateFramework(framework string) error {
+	if _, ok := frameworkRouters[framework]; ok || framework == "" {
+		return nil
+	}
+	return fmt.Errorf("unknown framework %q (expected one of %s)", framework, strings.Join(frameworkNames(), ", "))
+}
+
+// collectRegistrations records the routes registered on the framework's router, e.g.
+// r.GET("/users/:id", GetUser), alongside the route table entries so handlers without
+// a @route still get a request
+func (p *Parser) collectRegistrations(fset *token.FileSet, node *ast.File) {
+	router, ok := frameworkRouters[p.Framework]
+	if !ok {
+		return
+	}
+
+	for _, decl := range node.Decls {
+		// Handlers are often methods, e.g. e.POST("/login", h.Login), so keep track of the
+		// types of the variables registering functions use
+		variableTypes := map[string]string{}
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+			variableTypes = receiverVariableTypes(funcDecl)
+		}
+
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				p.collectRegistration(fset, router, call, variableTypes)
+			}
+			return true
+		})
+	}
+}
+
+// collectRegistration records the route of one registration call, if it is one
+func (p *Parser) collectRegistration(fset *token.FileSet, router frameworkRouter, call *ast.CallExpr, variableTypes map[string]string) {
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+
+	var method string
+	args := call.Args
+	combined := false
+	switch {
+	case router.patterns[selector.Sel.Name]:
+		// The method is only known once the pattern's constants are resolved
+		combined = true
+	case router.methods[selector.Sel.Name]:
+		method = strings.ToUpper(selector.Sel.Name)
+	case router.handle[selector.Sel.Name] && len(args) > 0:
+		if method, ok = methodFromExpr(args[0]); !ok {
+			return
+		}
+		args = args[1:]
+	default:
+		return
+	}
+
+	// The path comes first and the handler last, anything in between is middleware
+	if len(args) < 2 {
+		return
+	}
+	handlerExpr := unwrapHandlerFunc(args[len(args)-1])
+	handler := typeNameFromTypeExpr(handlerExpr)
+	if handler == "" {
+		getLogger().Warn(fmt.Sprintf("Could not read the handler of a %s registration", p.Framework),
+			"position", fset.Position(call.Pos()).String())
+		return
+	}
+
+	receiver := ""
+	if selector, ok := handlerExpr.(*ast.SelectorExpr); ok {
+		if ident, ok := selector.X.(*ast.Ident); ok {
+			receiver = variableTypes[ident.Name]
+		}
+	}
+
+	p.routeTables = append(p.routeTables, tableRoute{
+		method:   method,
+		path:     args[0],
+		handler:  handler,
+		receiver: receiver,
+		prefixes: p.callGroups[call],
+		position: fset.Position(call.Pos()),
+		combined: combined,
+	})
+}
+
+// splitServeMuxPattern splits a Go 1.22 ServeMux pattern such as "POST /items/{id}" into its
+// method and path. The host of "GET example.com/items/" is dropped, as are the {$} that
+// anchors a path and the ... of a trailing wildcard. Patterns without a method, which match
+// every method, report false.
+func splitServeMuxPattern(pattern string) (string, string, bool) {
+	method, path, found := strings.Cut(strings.TrimSpace(pattern), " ")
+	if !found || !httpMethods[method] {
+		return "", "", false
+	}
+
+	path = strings.TrimSpace(path)
+	slash := strings.Index(path, "/")
+	if slash < 0 {
+		return "", "", false
+	}
+	path = strings.TrimSuffix(path[slash:], "{$}")
+	path = strings.ReplaceAll(path, "...}", "}")
+	return method, path, true
+}
+
+// unwrapHandlerFunc looks through conversions such as http.HandlerFunc(ListUsers) to the
+// handler they convert
+func unwrapHandlerFunc(expr ast.Expr) ast.Expr {
+	if call, ok := expr.(*ast.CallExpr); ok && len(call.Args) == 1 && typeNameFromTypeExpr(call.Fun) == "HandlerFunc" {
+		return call.Args[0]
+	}
+	return expr
+}
+
+// receiverVariableTypes returns the types of a function's parameters and local variables,
+// including ones built by a constructor, e.g. h := handlers.NewUserHandler(db) is a UserHandler
+func receiverVariableTypes(funcDecl *ast.FuncDecl) map[string]string {
+	variableTypes := map[string]string{}
+	if funcDecl.Body != nil {
+		variableTypes = localVariableTypes(funcDecl)
+	}
+
+	for _, param := range funcDecl.Type.Params.List {
+		for _, name := range param.Names {
+			variableTypes[name.Name] = typeNameFromTypeExpr(param.Type)
+		}
+	}
+
+	if funcDecl.Body != nil {
+		ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+			assign, ok := n.(*ast.AssignStmt)
+			if !ok || len(assign.Lhs) == 0 || len(assign.Rhs) != 1 {
+				return true
+			}
+			ident, ok := assign.Lhs[0].(*ast.Ident)
+			call, isCall := assign.Rhs[0].(*ast.CallExpr)
+			if !ok || !isCall || variableTypes[ident.Name] != "" {
+				return true
+			}
+			if typeName, ok := strings.CutPrefix(typeNameFromTypeExpr(call.Fun), "New"); ok && typeName != "" {
+				variableTypes[ident.Name] = typeName
+			}
+			return true
+		})
+	}
+	return variableTypes
+}
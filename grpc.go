@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// generateGRPCRequestSection creates the grpc section for a Bruno gRPC request file
+func (g *BrunoGenerator) generateGRPCRequestSection(route *Route) string {
+	host := g.hostURL()
+
+	auth := "none"
+	if route.Auth != "" {
+		auth = route.Auth
+	}
+
+	return bruDictBlock("grpc", []bruPair{
+		{Key: "url", Value: host},
+		{Key: "method", Value: route.GRPC.FullMethod()},
+		{Key: "body", Value: "grpc"},
+		{Key: "auth", Value: auth},
+		{Key: "methodType", Value: "unary"},
+	})
+}
+
+// generateGRPCBodySection creates the request message block for a Bruno gRPC request file
+func (g *BrunoGenerator) generateGRPCBodySection(route *Route) (string, error) {
+	message := exampleBody(route.RequestBody, g.newRouteExampleOptions(route))
+
+	jsonBytes, err := json.MarshalIndent(message, "", JSONOutputIndent)
+	if err != nil {
+		return "", err
+	}
+
+	lines := []string{
+		"name: message 1",
+		"content: '''",
+		indentBlock(string(jsonBytes)),
+		"'''",
+	}
+
+	return fmt.Sprintf("body:grpc {\n%s\n}", indentBlock(strings.Join(lines, "\n"))), nil
+}
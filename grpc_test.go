@@ -0,0 +1,46 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateRequestFileWritesGRPCStub(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"greeter.go": `package api
+
+type HelloRequest struct {
+	Name string ` + "`json:\"name\"`" + `
+}
+
+// @name Say Hello
+// @grpc helloworld.Greeter.SayHello
+// @body HelloRequest
+func SayHello() {}
+`,
+	})
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	if len(routes) != 1 || routes[0].Method != GRPCRouteMethod || routes[0].GRPC.FullMethod() != "/helloworld.Greeter/SayHello" {
+		t.Fatalf("routes = %+v, want one gRPC route", routes)
+	}
+
+	g := NewBrunoGenerator(t.TempDir(), "api.example.com")
+	if err := g.GenerateRequestFile(routes[0]); err != nil {
+		t.Fatalf("GenerateRequestFile() error = %v", err)
+	}
+
+	content := readFile(t, filepath.Join(g.OutputDir, "say-hello.bru"))
+	for _, want := range []string{
+		"  type: grpc\n",
+		"grpc {\n  url: {{baseUrl}}\n  method: /helloworld.Greeter/SayHello\n  body: grpc\n  auth: none\n  methodType: unary\n}\n",
+		"body:grpc {\n  name: message 1\n  content: '''\n    {\n      \"name\": \"\"\n    }\n  '''\n}\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("gRPC request file is missing %q:\n%s", want, content)
+		}
+	}
+}
@@ -0,0 +1,931 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonResponseMethods are the context helpers (gin/echo style) that write a JSON body
+var jsonResponseMethods = map[string]bool{
+	"JSON":         true,
+	"IndentedJSON": true,
+	"PureJSON":     true,
+	"SecureJSON":   true,
+	"AsciiJSON":    true,
+}
+
+// httpStatusConstants maps the net/http status constants onto their codes
+var httpStatusConstants = map[string]int{
+	"StatusOK":                  200,
+	"StatusCreated":             201,
+	"StatusAccepted":            202,
+	"StatusNoContent":           204,
+	"StatusMovedPermanently":    301,
+	"StatusFound":               302,
+	"StatusNotModified":         304,
+	"StatusBadRequest":          400,
+	"StatusUnauthorized":        401,
+	"StatusForbidden":           403,
+	"StatusNotFound":            404,
+	"StatusMethodNotAllowed":    405,
+	"StatusConflict":            409,
+	"StatusUnprocessableEntity": 422,
+	"StatusTooManyRequests":     429,
+	"StatusInternalServerError": 500,
+	"StatusNotImplemented":      501,
+	"StatusBadGateway":          502,
+	"StatusServiceUnavailable":  503,
+}
+
+// inferResponses walks a handler body looking for response writer calls and maps
+// them to response specs
+func (p *Parser) inferResponses(fset *token.FileSet, funcDecl *ast.FuncDecl) []ResponseSpec {
+	if funcDecl.Body == nil {
+		return nil
+	}
+
+	logger := getLogger()
+	handlerName := funcDecl.Name.Name
+	localTypes := localVariableTypes(funcDecl)
+
+	responses := []ResponseSpec{}
+	byStatus := make(map[int]int)
+
+	addResponse := func(pos token.Pos, statusCode int, typeName string) {
+		if i, ok := byStatus[statusCode]; ok {
+			if responses[i].TypeName != typeName {
+				logger.Warn(fmt.Sprintf("Ambiguous inferred response for status %d in handler %s (%s vs %s), keeping the first",
+					statusCode, handlerName, responses[i].TypeName, typeName), "position", fset.Position(pos).String())
+			}
+			return
+		}
+		byStatus[statusCode] = len(responses)
+		responses = append(responses, ResponseSpec{
+			StatusCode: statusCode,
+			TypeName:   typeName,
+			Inferred:   true,
+		})
+	}
+
+	// Status written via WriteHeader applies to the next encoded body
+	pendingStatus := 0
+	var pendingPos token.Pos
+
+	ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+
+		selector, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+
+		switch {
+		// c.JSON(200, x)
+		case jsonResponseMethods[selector.Sel.Name] && len(call.Args) == 2:
+			statusCode, ok := statusCodeFromExpr(call.Args[0])
+			if !ok {
+				logger.Warn(fmt.Sprintf("Could not infer response status in handler %s", handlerName),
+					"position", fset.Position(call.Pos()).String())
+				return true
+			}
+			addResponse(call.Pos(), statusCode, typeNameFromExpr(call.Args[1], localTypes))
+
+		// w.WriteHeader(201)
+		case selector.Sel.Name == "WriteHeader" && len(call.Args) == 1:
+			statusCode, ok := statusCodeFromExpr(call.Args[0])
+			if !ok {
+				logger.Warn(fmt.Sprintf("Could not infer response status in handler %s", handlerName),
+					"position", fset.Position(call.Pos()).String())
+				return true
+			}
+			if pendingStatus != 0 {
+				addResponse(pendingPos, pendingStatus, "")
+			}
+			pendingStatus = statusCode
+			pendingPos = call.Pos()
+
+		// json.NewEncoder(w).Encode(x)
+		case selector.Sel.Name == "Encode" && len(call.Args) == 1 && isNewEncoderCall(selector.X):
+			statusCode := 200
+			if pendingStatus != 0 {
+				statusCode = pendingStatus
+				pendingStatus = 0
+			}
+			addResponse(call.Pos(), statusCode, typeNameFromExpr(call.Args[0], localTypes))
+		}
+
+		return true
+	})
+
+	if pendingStatus != 0 {
+		addResponse(pendingPos, pendingStatus, "")
+	}
+
+	// Handlers that return their body instead of writing it, e.g. func GetUser(...) (*User, error)
+	if len(responses) == 0 {
+		if typeName := returnedTypeName(fset, funcDecl, localTypes); typeName != "" {
+			addResponse(funcDecl.Pos(), 200, typeName)
+		}
+	}
+
+	return responses
+}
+
+// returnedTypeName works out the struct a handler returns, from its declared result
+// or, for any/interface{} results, from the values its return statements produce
+func returnedTypeName(fset *token.FileSet, funcDecl *ast.FuncDecl, localTypes map[string]string) string {
+	if funcDecl.Type.Results == nil {
+		return ""
+	}
+
+	logger := getLogger()
+	handlerName := funcDecl.Name.Name
+
+	// Find the first non-error result, counting grouped names like (a, b T) separately
+	bodyIndex := -1
+	var bodyType ast.Expr
+	index := 0
+	for _, result := range funcDecl.Type.Results.List {
+		count := len(result.Names)
+		if count == 0 {
+			count = 1
+		}
+		if typeNameFromTypeExpr(result.Type) == "error" {
+			index += count
+			continue
+		}
+		if bodyIndex != -1 || count > 1 {
+			logger.Warn(fmt.Sprintf("Handler %s returns several values, using the first as the response body", handlerName),
+				"position", fset.Position(funcDecl.Pos()).String())
+		}
+		if bodyIndex == -1 {
+			bodyIndex = index
+			bodyType = result.Type
+		}
+		index += count
+	}
+
+	if bodyIndex == -1 {
+		return ""
+	}
+
+	if typeName := typeNameFromTypeExpr(bodyType); !isBuiltinType(typeName) {
+		return typeName
+	}
+
+	typeNames := []string{}
+	ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.FuncLit:
+			// Returns inside closures belong to the closure
+			return false
+		case *ast.ReturnStmt:
+			if len(node.Results) <= bodyIndex {
+				return true
+			}
+			typeName := typeNameFromExpr(node.Results[bodyIndex], localTypes)
+			if typeName == "" {
+				return true
+			}
+			if len(typeNames) > 0 && typeNames[0] != typeName {
+				logger.Warn(fmt.Sprintf("Ambiguous returned response type in handler %s (%s vs %s), keeping the first",
+					handlerName, typeNames[0], typeName), "position", fset.Position(node.Pos()).String())
+			}
+			typeNames = append(typeNames, typeName)
+		}
+		return true
+	})
+
+	if len(typeNames) == 0 {
+		return ""
+	}
+	return typeNames[0]
+}
+
+// mergeResponses combines explicit and inferred responses, letting the explicit
+// annotations override anything inferred for the same status code
+func mergeResponses(explicit, inferred []ResponseSpec) []ResponseSpec {
+	merged := append([]ResponseSpec{}, explicit...)
+
+	documented := make(map[int]bool)
+	for _, response := range explicit {
+		documented[response.StatusCode] = true
+	}
+
+	for _, response := range inferred {
+		if !documented[response.StatusCode] {
+			merged = append(merged, response)
+		}
+	}
+
+	return merged
+}
+
+// statusCodeFromExpr resolves an integer literal or net/http status constant
+func statusCodeFromExpr(expr ast.Expr) (int, bool) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.INT {
+			return 0, false
+		}
+		code, err := strconv.Atoi(e.Value)
+		return code, err == nil
+	case *ast.SelectorExpr:
+		code, ok := httpStatusConstants[e.Sel.Name]
+		return code, ok
+	}
+	return 0, false
+}
+
+// typeNameFromExpr works out the struct type of a value passed to a response writer
+func typeNameFromExpr(expr ast.Expr, localTypes map[string]string) string {
+	switch e := expr.(type) {
+	case *ast.CompositeLit:
+		return typeNameFromTypeExpr(e.Type)
+	case *ast.UnaryExpr:
+		return typeNameFromExpr(e.X, localTypes)
+	case *ast.Ident:
+		return localTypes[e.Name]
+	case *ast.CallExpr:
+		// new(T)
+		if ident, ok := e.Fun.(*ast.Ident); ok && ident.Name == "new" && len(e.Args) == 1 {
+			return typeNameFromTypeExpr(e.Args[0])
+		}
+	}
+	return ""
+}
+
+// typeNameFromTypeExpr returns the bare type name of a type expression
+func typeNameFromTypeExpr(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.StarExpr:
+		return typeNameFromTypeExpr(t.X)
+	}
+	return ""
+}
+
+// receiverTypeName returns the type a method is declared on, or "" for plain functions
+func receiverTypeName(funcDecl *ast.FuncDecl) string {
+	if funcDecl.Recv == nil || len(funcDecl.Recv.List) == 0 {
+		return ""
+	}
+	return typeNameFromTypeExpr(funcDecl.Recv.List[0].Type)
+}
+
+// localVariableTypes records the types of variables declared in a function so
+// response values can be traced back to their struct
+func localVariableTypes(funcDecl *ast.FuncDecl) map[string]string {
+	localTypes := make(map[string]string)
+
+	ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		// var x T
+		case *ast.ValueSpec:
+			if node.Type != nil {
+				for _, name := range node.Names {
+					localTypes[name.Name] = typeNameFromTypeExpr(node.Type)
+				}
+			}
+		// x := T{}
+		case *ast.AssignStmt:
+			if len(node.Lhs) != len(node.Rhs) {
+				return true
+			}
+			for i, lhs := range node.Lhs {
+				ident, ok := lhs.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				if typeName := typeNameFromExpr(node.Rhs[i], localTypes); typeName != "" {
+					localTypes[ident.Name] = typeName
+				}
+			}
+		}
+		return true
+	})
+
+	return localTypes
+}
+
+// uriBindingMethods are the context helpers (gin style) that bind path parameters to a struct
+var uriBindingMethods = map[string]bool{
+	"ShouldBindUri": true,
+	"BindUri":       true,
+}
+
+// uriBindingType returns the struct a handler binds its path parameters to, e.g. c.ShouldBindUri(&params)
+func uriBindingType(funcDecl *ast.FuncDecl) string {
+	if funcDecl.Body == nil {
+		return ""
+	}
+
+	localTypes := localVariableTypes(funcDecl)
+	typeName := ""
+	ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+		if typeName != "" {
+			return false
+		}
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		selector, ok := call.Fun.(*ast.SelectorExpr)
+		if ok && uriBindingMethods[selector.Sel.Name] && len(call.Args) == 1 {
+			typeName = typeNameFromExpr(call.Args[0], localTypes)
+		}
+		return true
+	})
+	return typeName
+}
+
+// isNewEncoderCall reports whether an expression is a json.NewEncoder(...) call
+func isNewEncoderCall(expr ast.Expr) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	return ok && selector.Sel.Name == "NewEncoder"
+}
+
+// routeRegistrationMethods are the router methods (gin/echo/chi style) that register a handler
+var routeRegistrationMethods = map[string]bool{
+	"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "HEAD": true, "OPTIONS": true, "Any": true,
+	"Get": true, "Post": true, "Put": true, "Patch": true, "Delete": true, "Head": true, "Options": true,
+}
+
+// defaultAuthMiddleware maps well-known framework middleware onto auth modes
+var defaultAuthMiddleware = map[string]string{
+	"gin.BasicAuth":                "basic",
+	"gin.BasicAuthForRealm":        "basic",
+	"middleware.BasicAuth":         "basic",
+	"middleware.JWT":               "bearer",
+	"middleware.JWTWithConfig":     "bearer",
+	"middleware.KeyAuth":           "apikey",
+	"middleware.KeyAuthWithConfig": "apikey",
+}
+
+// collectMiddleware records the middleware registered in front of each handler,
+// e.g. r.GET("/users", auth.Required, ListUsers) or r.With(auth.Required).Get(...)
+func (p *Parser) collectMiddleware(fset *token.FileSet, node *ast.File, filePath string) {
+	for _, decl := range node.Decls {
+		// The receiver types of method values tell handlers of the same name apart
+		variableTypes := map[string]string{}
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+			variableTypes = receiverVariableTypes(funcDecl)
+		}
+
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				p.collectCallMiddleware(fset, call, node, filePath, variableTypes)
+			}
+			return true
+		})
+	}
+}
+
+// collectCallMiddleware records the middleware of one registration call, if it is one
+func (p *Parser) collectCallMiddleware(fset *token.FileSet, call *ast.CallExpr, node *ast.File, filePath string, variableTypes map[string]string) {
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || !routeRegistrationMethods[selector.Sel.Name] || len(call.Args) < 2 {
+		return
+	}
+
+	// The path comes first and the handler last, anything in between is middleware.
+	handler, ok := newHandlerRef(call.Args[len(call.Args)-1], node, filePath, variableTypes)
+	if !ok {
+		return
+	}
+
+	middleware := []string{}
+	for _, arg := range call.Args[1 : len(call.Args)-1] {
+		if name := middlewareName(arg); name != "" {
+			middleware = append(middleware, name)
+		}
+	}
+	middleware = append(middleware, chainedMiddleware(selector.X)...)
+
+	if len(middleware) > 0 {
+		getLogger().Debug(fmt.Sprintf("Found middleware %v for handler %s", middleware, handler.name),
+			"position", fset.Position(call.Pos()).String())
+		p.middleware = append(p.middleware, handlerRegistration{handler: handler, middleware: middleware})
+	}
+}
+
+// applyMiddlewareAuth sets the auth mode of routes whose handlers are registered
+// behind a known auth middleware
+func (p *Parser) applyMiddlewareAuth() {
+	logger := getLogger()
+
+	declared := p.declaredHandlers()
+	middleware := make(map[handlerKey][]string)
+	for _, registration := range p.middleware {
+		for _, key := range p.handlerKeys(registration.handler, declared) {
+			middleware[key] = append(middleware[key], registration.middleware...)
+		}
+	}
+
+	for _, route := range p.routes {
+		for _, name := range middleware[route.handlerKey()] {
+			mode, ok := p.AuthMiddleware[name]
+			if !ok {
+				// Allow mappings to use the bare function name as well.
+				_, bareName, _ := strings.Cut(name, ".")
+				mode, ok = p.AuthMiddleware[bareName]
+			}
+
+			if !ok {
+				logger.Warn(fmt.Sprintf("Middleware %s on handler %s has no auth mapping", name, route.Handler))
+				continue
+			}
+
+			if route.Auth == "" {
+				route.Auth = mode
+			}
+		}
+	}
+}
+
+// handlerDecl is a function declaration along with where it was found
+type handlerDecl struct {
+	fset     *token.FileSet
+	funcDecl *ast.FuncDecl
+	filePath string
+	basePath string // @basepath from the file's package comment
+}
+
+// handlerKey identifies a handler across packages by its package directory, the type of its
+// receiver when it is a method, and its name
+type handlerKey struct {
+	dir      string
+	receiver string
+	name     string
+}
+
+// handlerKey returns the key of the handler a route was built from
+func (r *Route) handlerKey() handlerKey {
+	return handlerKey{dir: filepath.Dir(r.SourceFile), receiver: r.Receiver, name: r.Handler}
+}
+
+// handlerRef is a handler as a registration refers to it, e.g. ListUsers, users.List or h.Login
+type handlerRef struct {
+	fromDir  string // Directory of the registering file, where unqualified functions live
+	pkg      string // Package of a qualified function, e.g. users for users.List
+	method   bool   // Set for method values such as h.Login
+	receiver string // Receiver type of a method value, when it could be traced
+	name     string
+}
+
+// handlerRegistration is one registration of a handler, with what sits in front of it
+type handlerRegistration struct {
+	handler    handlerRef
+	prefixes   []ast.Expr // Router group prefixes, outermost first
+	middleware []string   // Middleware names
+}
+
+// newHandlerRef reads the handler a registration refers to. Selectors on one of the file's
+// imports are package functions, any other selector is a method value.
+func newHandlerRef(expr ast.Expr, node *ast.File, filePath string, variableTypes map[string]string) (handlerRef, bool) {
+	ref := handlerRef{fromDir: filepath.Dir(filePath), name: typeNameFromTypeExpr(expr)}
+	selector, ok := expr.(*ast.SelectorExpr)
+	if ref.name == "" || !ok {
+		return ref, ref.name != ""
+	}
+
+	ident, ok := selector.X.(*ast.Ident)
+	switch {
+	case ok && variableTypes[ident.Name] != "":
+		ref.method, ref.receiver = true, variableTypes[ident.Name]
+	case ok && ident.Obj == nil && importsPackage(node, ident.Name):
+		ref.pkg = ident.Name
+	default:
+		ref.method = true
+	}
+	return ref, true
+}
+
+// importsPackage reports whether a file imports a package under the given name
+func importsPackage(node *ast.File, pkgName string) bool {
+	for _, spec := range node.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err == nil && importName(spec, importPath) == pkgName {
+			return true
+		}
+	}
+	return false
+}
+
+// declaredHandlers indexes the functions of the scanned files by name, in file order
+func (p *Parser) declaredHandlers() map[string][]handlerKey {
+	paths := make([]string, 0, len(p.files))
+	for path := range p.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	declared := make(map[string][]handlerKey)
+	for _, path := range paths {
+		for _, decl := range p.files[path].Decls {
+			if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+				key := handlerKey{dir: filepath.Dir(path), receiver: receiverTypeName(funcDecl), name: funcDecl.Name.Name}
+				declared[key.name] = append(declared[key.name], key)
+			}
+		}
+	}
+	return declared
+}
+
+// handlerKeys resolves a handler reference into the keys of the declared handlers it can
+// mean. Qualified functions are looked up in the packages best matching their import path,
+// and method values whose receiver couldn't be traced match the method on every type.
+func (p *Parser) handlerKeys(ref handlerRef, declared map[string][]handlerKey) []handlerKey {
+	importPath := ""
+	if ref.pkg != "" {
+		importPath, _ = p.importPathFor(ref.fromDir, ref.pkg)
+	}
+
+	keys := []handlerKey{}
+	best := 0
+	for _, key := range declared[ref.name] {
+		if (key.receiver != "") != ref.method || (ref.receiver != "" && key.receiver != ref.receiver) {
+			continue
+		}
+		switch {
+		case ref.pkg != "":
+			match := importPathMatch(key.dir, importPath)
+			if match > best {
+				keys, best = []handlerKey{key}, match
+			} else if match == best && match > 0 {
+				keys = append(keys, key)
+			}
+		case ref.method || key.dir == ref.fromDir:
+			keys = append(keys, key)
+		}
+	}
+
+	// Routes of handlers outside the scanned directory are built at their registration
+	if len(keys) == 0 {
+		keys = append(keys, handlerKey{dir: ref.fromDir, receiver: ref.receiver, name: ref.name})
+	}
+	return keys
+}
+
+// tableRoute is one entry of a route registration table
+type tableRoute struct {
+	method   string
+	path     ast.Expr // Resolved once every constant is known
+	handler  string
+	receiver string     // Type of the handler's receiver when it is a method value, e.g. h.Login
+	prefixes []ast.Expr // Router group prefixes of this registration, outermost first
+	position token.Position
+	combined bool // path holds a ServeMux "METHOD /path" pattern and method is empty
+}
+
+// routeTableKeys maps the field names route table entries use onto method, path and handler
+var routeTableKeys = map[string]string{
+	"Method":      "method",
+	"Path":        "path",
+	"Pattern":     "path",
+	"Handler":     "handler",
+	"HandlerFunc": "handler",
+}
+
+// collectRouteTables records the entries of registration tables such as
+// []Route{{Method: "GET", Path: "/users", Handler: ListUsers}}
+func (p *Parser) collectRouteTables(fset *token.FileSet, node *ast.File) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		lit, ok := n.(*ast.CompositeLit)
+		if !ok {
+			return true
+		}
+
+		fields := make(map[string]ast.Expr)
+		for _, elt := range lit.Elts {
+			keyValue, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				continue
+			}
+			key, ok := keyValue.Key.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			if field, ok := routeTableKeys[key.Name]; ok {
+				fields[field] = keyValue.Value
+			}
+		}
+
+		if fields["method"] == nil || fields["path"] == nil || fields["handler"] == nil {
+			return true
+		}
+
+		method, ok := methodFromExpr(fields["method"])
+		handler := typeNameFromTypeExpr(fields["handler"])
+		if !ok || handler == "" {
+			getLogger().Warn("Could not read route table entry", "position", fset.Position(lit.Pos()).String())
+			return true
+		}
+
+		p.routeTables = append(p.routeTables, tableRoute{
+			method:   method,
+			path:     fields["path"],
+			handler:  handler,
+			position: fset.Position(lit.Pos()),
+		})
+		return true
+	})
+}
+
+// methodFromExpr resolves "GET" or http.MethodGet to an HTTP method
+func methodFromExpr(expr ast.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		method, err := strconv.Unquote(e.Value)
+		return strings.ToUpper(method), err == nil && method != ""
+	case *ast.SelectorExpr:
+		if method, ok := strings.CutPrefix(e.Sel.Name, "Method"); ok && method != "" {
+			return strings.ToUpper(method), true
+		}
+	}
+	return "", false
+}
+
+// applyRouteTables adds a route for every route table entry whose handler isn't already
+// annotated with a @route, picking up any other annotations the handler has
+func (p *Parser) applyRouteTables() {
+	annotated := make(map[string]bool)
+	for _, route := range p.routes {
+		annotated[route.Handler] = true
+	}
+
+	for _, entry := range p.routeTables {
+		if annotated[entry.handler] {
+			continue
+		}
+
+		path, ok := p.resolveStringConstant(entry.path, 0)
+		if !ok {
+			getLogger().Warn(fmt.Sprintf("Could not resolve route table path for handler %s", entry.handler),
+				"position", entry.position.String())
+			continue
+		}
+		if entry.combined {
+			pattern := path
+			if entry.method, path, ok = splitServeMuxPattern(pattern); !ok {
+				getLogger().Debug(fmt.Sprintf("Skipping pattern %q of handler %s, it has no method", pattern, entry.handler),
+					"position", entry.position.String())
+				continue
+			}
+		}
+		if prefix, ok := p.resolveGroupPrefix(entry.prefixes, entry.handler); ok {
+			path = prefixedPath(prefix, path)
+		}
+
+		// Methods are looked up on their receiver first, as several types may share a method name
+		decl, ok := p.handlerDecls[entry.receiver+"."+entry.handler]
+		if !ok {
+			decl, ok = p.handlerDecls[entry.handler]
+		}
+
+		var route *Route
+		if ok {
+			route = p.newRoute(decl.fset, decl.funcDecl, nil, decl.filePath, decl.basePath, entry.method, path)
+		} else {
+			// The handler lives outside the scanned directory
+			getLogger().Warn(fmt.Sprintf("Could not locate handler %s, its annotations are skipped", entry.handler),
+				"position", entry.position.String())
+			route = &Route{
+				Method:     entry.method,
+				Path:       path,
+				Handler:    entry.handler,
+				Receiver:   entry.receiver,
+				SourceFile: entry.position.Filename,
+				SourceLine: entry.position.Line,
+			}
+		}
+		if route.Name == "" {
+			route.Name = entry.handler
+		}
+
+		getLogger().Debug(fmt.Sprintf("Found route table entry %s %s for handler %s", route.Method, route.Path, route.Handler),
+			"position", entry.position.String())
+		p.routes = append(p.routes, route)
+	}
+}
+
+// collectRouteGroups records the router group prefixes in front of each handler, following
+// groups through variables (v1 := api.Group("/v1")) and nested groups
+func (p *Parser) collectRouteGroups(node *ast.File, filePath string) {
+	groups := make(map[string][]ast.Expr)
+
+	for _, decl := range node.Decls {
+		// The receiver types of method values tell handlers of the same name apart
+		variableTypes := map[string]string{}
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+			variableTypes = receiverVariableTypes(funcDecl)
+		}
+		p.collectDeclRouteGroups(decl, node, filePath, groups, variableTypes)
+	}
+}
+
+// collectDeclRouteGroups records the router group prefixes of the registrations in one declaration
+func (p *Parser) collectDeclRouteGroups(decl ast.Decl, node *ast.File, filePath string, groups map[string][]ast.Expr, variableTypes map[string]string) {
+	ast.Inspect(decl, func(n ast.Node) bool {
+		switch n := n.(type) {
+		// v1 := r.Group("/v1")
+		case *ast.AssignStmt:
+			if len(n.Lhs) != len(n.Rhs) {
+				return true
+			}
+			for i, lhs := range n.Lhs {
+				ident, ok := lhs.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				if prefixes, ok := groupPrefixes(n.Rhs[i], groups); ok {
+					groups[ident.Name] = prefixes
+				}
+			}
+		// var v1 = r.Group("/v1")
+		case *ast.ValueSpec:
+			if len(n.Names) != len(n.Values) {
+				return true
+			}
+			for i, name := range n.Names {
+				if prefixes, ok := groupPrefixes(n.Values[i], groups); ok {
+					groups[name.Name] = prefixes
+				}
+			}
+		// v1.GET("/users", ListUsers)
+		case *ast.CallExpr:
+			selector, ok := n.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			prefixes, _ := groupPrefixes(selector.X, groups)
+			if len(prefixes) > 0 {
+				p.callGroups[n] = prefixes
+			}
+
+			// Registrations outside any group are kept too, so a handler registered both
+			// on the router and on a group keeps both paths
+			if !routeRegistrationMethods[selector.Sel.Name] || len(n.Args) < 2 {
+				return true
+			}
+			if handler, ok := newHandlerRef(n.Args[len(n.Args)-1], node, filePath, variableTypes); ok {
+				p.routeGroups = append(p.routeGroups, handlerRegistration{handler: handler, prefixes: prefixes})
+			}
+		}
+		return true
+	})
+}
+
+// groupPrefixes returns the prefix expressions a router expression adds, for a
+// r.Group("/api") call, a variable holding a group, or a chain of both
+func groupPrefixes(expr ast.Expr, groups map[string][]ast.Expr) ([]ast.Expr, bool) {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		prefixes, ok := groups[e.Name]
+		return prefixes, ok
+	case *ast.CallExpr:
+		selector, ok := e.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return nil, false
+		}
+		parent, _ := groupPrefixes(selector.X, groups)
+
+		// chi's r.Group(func(r chi.Router) {...}) has no prefix
+		if selector.Sel.Name == "Group" && len(e.Args) > 0 {
+			if _, isFunc := e.Args[0].(*ast.FuncLit); !isFunc {
+				return append(append([]ast.Expr{}, parent...), e.Args[0]), true
+			}
+		}
+
+		// r.With(mw) and friends keep the prefixes of the router they are called on
+		return parent, len(parent) > 0
+	}
+	return nil, false
+}
+
+// applyRouteGroups prefixes annotated routes with the router groups their handlers are
+// registered on. A handler registered on several groups gets a route for each of them.
+// Annotated paths that already include the group prefix are left alone.
+func (p *Parser) applyRouteGroups() {
+	declared := p.declaredHandlers()
+	routeGroups := make(map[handlerKey][][]ast.Expr)
+	for _, registration := range p.routeGroups {
+		for _, key := range p.handlerKeys(registration.handler, declared) {
+			routeGroups[key] = append(routeGroups[key], registration.prefixes)
+		}
+	}
+
+	routes := []*Route{}
+	for _, route := range p.routes {
+		registrations, ok := routeGroups[route.handlerKey()]
+		if !ok || route.GRPC != nil {
+			routes = append(routes, route)
+			continue
+		}
+
+		paths := []string{}
+		seen := make(map[string]bool)
+		for _, prefixes := range registrations {
+			path := route.Path
+			if prefix, ok := p.resolveGroupPrefix(prefixes, route.Handler); ok {
+				path = prefixedPath(prefix, path)
+			}
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+
+		for i, path := range paths {
+			prefixed := route
+			if i > 0 {
+				clone := *route
+				prefixed = &clone
+			}
+			prefixed.Path = path
+			routes = append(routes, prefixed)
+		}
+	}
+	p.routes = routes
+}
+
+// resolveGroupPrefix joins the router group prefixes of a registration into one path prefix
+func (p *Parser) resolveGroupPrefix(prefixes []ast.Expr, handler string) (string, bool) {
+	prefix := ""
+	for _, expr := range prefixes {
+		value, ok := p.resolveStringConstant(expr, 0)
+		if !ok {
+			getLogger().Warn(fmt.Sprintf("Could not resolve router group prefix for handler %s", handler))
+			return "", false
+		}
+		if value = strings.Trim(value, "/"); value != "" {
+			prefix += "/" + value
+		}
+	}
+	return prefix, true
+}
+
+// prefixedPath puts a router group prefix in front of a path, unless the path already has it
+func prefixedPath(prefix, path string) string {
+	if prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/") {
+		return path
+	}
+	return prefix + path
+}
+
+// chainedMiddleware collects middleware from chi style r.With(mw).Get(...) chains
+func chainedMiddleware(expr ast.Expr) []string {
+	middleware := []string{}
+
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return middleware
+	}
+
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return middleware
+	}
+
+	if selector.Sel.Name == "With" {
+		for _, arg := range call.Args {
+			if name := middlewareName(arg); name != "" {
+				middleware = append(middleware, name)
+			}
+		}
+	}
+
+	return append(middleware, chainedMiddleware(selector.X)...)
+}
+
+// middlewareName renders a middleware argument as pkg.Name or Name
+func middlewareName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		if ident, ok := e.X.(*ast.Ident); ok {
+			return ident.Name + "." + e.Sel.Name
+		}
+		return e.Sel.Name
+	case *ast.CallExpr:
+		// auth.Required() returns the middleware
+		return middlewareName(e.Fun)
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// InitExampleDir is where -init writes the starter example
+const InitExampleDir = "brungo-example"
+
+// exampleHandlerSource shows the annotation syntax on a couple of plain net/http handlers. It
+// doubles as the end-to-end fixture of the tests, which generate a collection from it, so the
+// example users start from is known to work.
+const exampleHandlerSource = `package example
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// CreateUserRequest is the body accepted by CreateUser
+type CreateUserRequest struct {
+	// Display name shown to other users
+	Name  string ` + "`json:\"name\" binding:\"required\"`" + `
+	Email string ` + "`json:\"email\" binding:\"required\"`" + `
+	Age   int    ` + "`json:\"age\"`" + `
+}
+
+// User is returned by the user endpoints
+type User struct {
+	ID    string ` + "`json:\"id\"`" + `
+	Name  string ` + "`json:\"name\"`" + `
+	Email string ` + "`json:\"email\"`" + `
+}
+
+// @name Create User
+// @route POST /users
+// @description Creates a new user account
+// @tag users
+// @auth bearer
+// @body CreateUserRequest
+// @response 201 User
+func CreateUser(w http.ResponseWriter, r *http.Request) {
+	var request CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(User{Name: request.Name, Email: request.Email})
+}
+
+// @name Get User
+// @route GET /users/:id
+// @description Fetches a single user by id
+// @tag users
+// @response 200 User
+func GetUser(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(User{})
+}
+`
+
+// runInit writes the starter example and the collection generated from it. Nothing is
+// written if the example directory already exists.
+func runInit(dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("%s already exists, not overwriting it", dir)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "handlers.go"), []byte(exampleHandlerSource), 0644); err != nil {
+		return err
+	}
+
+	parser := NewParser()
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		return err
+	}
+
+	// Generate the collection the way a regular run does, environments included, so the
+	// {{baseUrl}} and {{authToken}} the requests reference are declared
+	brunoGen := NewBrunoGenerator(filepath.Join(dir, "bruno"), DefaultBaseURL)
+	brunoGen.Config.Variables = parser.Variables()
+	if err := brunoGen.GenerateCollection(routes); err != nil {
+		return err
+	}
+	if err := brunoGen.GenerateCollectionFile(); err != nil {
+		return err
+	}
+	return brunoGen.GenerateEnvironments()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunInitWritesRunnableCollection(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), InitExampleDir)
+	if err := runInit(dir); err != nil {
+		t.Fatalf("runInit() error = %v", err)
+	}
+
+	files := map[string]string{
+		"handlers.go":                  "// @route POST /users",
+		"bruno/bruno.json":             `"type": "collection"`,
+		"bruno/users/create-user.bru":  "{{authToken}}",
+		"bruno/users/get-user.bru":     "{{baseUrl}}/users/:id",
+		"bruno/environments/local.bru": "baseUrl: " + DefaultBaseURL,
+	}
+	for file, want := range files {
+		if content := readFile(t, filepath.Join(dir, file)); !strings.Contains(content, want) {
+			t.Errorf("%s missing %q:\n%s", file, want, content)
+		}
+	}
+	if content := readFile(t, filepath.Join(dir, "bruno/environments/local.bru")); !strings.Contains(content, BearerTokenVariable) {
+		t.Errorf("environment does not declare %s:\n%s", BearerTokenVariable, content)
+	}
+}
+
+func TestRunInitKeepsExistingFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), InitExampleDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	handlers := filepath.Join(dir, "handlers.go")
+	if err := os.WriteFile(handlers, []byte("package mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runInit(dir); err == nil {
+		t.Fatal("runInit() overwrote an existing example directory")
+	}
+	if content := readFile(t, handlers); content != "package mine\n" {
+		t.Errorf("handlers.go = %q, want it untouched", content)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bruno")); !os.IsNotExist(err) {
+		t.Errorf("runInit() wrote a collection into the existing directory")
+	}
+}
@@ -1,21 +1,30 @@
 package main
 
 import (
+	"io"
 	"log/slog"
 	"os"
+	"strings"
+	"time"
 )
 
 var globalLogger *slog.Logger
 var defaultLogger *slog.Logger
 
-func initializeLogging() *slog.Logger {
+// reporter collects the warnings and errors logged once logging is initialized
+var reporter = &warningReporter{}
+
+// initializeLogging sets up the global logger. Logs go to stderr so they never mix with
+// output written to stdout.
+func initializeLogging(format string, level string) *slog.Logger {
 	if globalLogger != nil {
 		return globalLogger
 	}
 
-	globalLogger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: mapLogLevels("DEBUG")}))
+	handler := newLogHandler(format, os.Stderr, &slog.HandlerOptions{Level: mapLogLevels(level)})
+	globalLogger = slog.New(&reportHandler{Handler: handler, reporter: reporter})
 
-	globalLogger.Info("Logging initialized!")
+	globalLogger.Debug("Logging initialized!")
 	return globalLogger
 }
 
@@ -24,12 +33,68 @@ func getLogger() *slog.Logger {
 		return defaultLogger
 	}
 
+	// Fall back to the slog default when logging hasn't been initialized (e.g. in tests).
+	if globalLogger == nil {
+		return slog.Default()
+	}
+
 	defaultLogger = globalLogger
 	return defaultLogger
 }
 
+// validLogFormat reports whether newLogHandler knows a format name
+func validLogFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case "json", "text", "logfmt":
+		return true
+	}
+	return false
+}
+
+// newLogHandler builds the slog handler for the requested output format
+func newLogHandler(format string, w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.NewTextHandler(w, opts)
+	case "logfmt":
+		opts.ReplaceAttr = logfmtReplaceAttr
+		return slog.NewTextHandler(w, opts)
+	default:
+		return slog.NewJSONHandler(w, opts)
+	}
+}
+
+// logfmtReplaceAttr adapts the text handler output to logfmt conventions:
+// RFC3339 timestamps and lowercase levels
+func logfmtReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+
+	switch a.Key {
+	case slog.TimeKey:
+		if t, ok := a.Value.Any().(time.Time); ok {
+			a.Value = slog.StringValue(t.Format(time.RFC3339))
+		}
+	case slog.LevelKey:
+		if level, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(strings.ToLower(level.String()))
+		}
+	}
+	return a
+}
+
+// validLogLevel reports whether mapLogLevels knows a level name
+func validLogLevel(level string) bool {
+	switch strings.ToUpper(level) {
+	case "DEBUG", "INFO", "WARN", "ERROR":
+		return true
+	}
+	return false
+}
+
 func mapLogLevels(level string) slog.Leveler {
-	switch level {
+	switch strings.ToUpper(level) {
 	case "DEBUG":
 		return slog.LevelDebug
 	case "WARN":
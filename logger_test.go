@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNewLogHandlerFormats(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(newLogHandler("logfmt", &out, &slog.HandlerOptions{Level: mapLogLevels("info")}))
+	logger.Debug("hidden")
+	logger.Warn("Duplicate route", "handler", "ListUsers")
+
+	logfmtLine := regexp.MustCompile(`^time=\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(Z|[+-]\d\d:\d\d) level=warn msg="Duplicate route" handler=ListUsers\n$`)
+	if !logfmtLine.MatchString(out.String()) {
+		t.Errorf("logfmt output = %q", out.String())
+	}
+
+	out.Reset()
+	slog.New(newLogHandler("text", &out, &slog.HandlerOptions{})).Info("Scanning")
+	if !strings.Contains(out.String(), "level=INFO msg=Scanning") {
+		t.Errorf("text output = %q", out.String())
+	}
+
+	out.Reset()
+	slog.New(newLogHandler("json", &out, &slog.HandlerOptions{})).Info("Scanning")
+	var record map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil || record["msg"] != "Scanning" {
+		t.Errorf("json output = %q, %v", out.String(), err)
+	}
+}
+
+func TestValidLogFormat(t *testing.T) {
+	for format, want := range map[string]bool{"json": true, "text": true, "LOGFMT": true, "lgfmt": false, "": false} {
+		if got := validLogFormat(format); got != want {
+			t.Errorf("validLogFormat(%q) = %v, want %v", format, got, want)
+		}
+	}
+}
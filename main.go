@@ -3,44 +3,441 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"slices"
+	"strings"
+	"text/template"
 )
 
-func main() {
-	initializeLogging()
-
-	logger := getLogger()
+// artifactGenerator writes one output format for the parsed routes and returns
+// the location of the artifact it produced
+type artifactGenerator func(routes []*Route) (string, error)
 
+func main() {
 	inputDir := flag.String("input", ".", "Directory containing Go handler code")
 	outputDir := flag.String("output", "./bruno", "Directory for Bruno files")
+	baseURLFlag := flag.String("url", DefaultBaseURL, "Base URL requests are sent to, e.g. https://api.example.com")
+	openAPIInput := flag.String("openapi", "", "Generate from this OpenAPI 3 YAML or JSON document instead of scanning -input for handlers")
+	formatList := flag.String("format", "bruno", "Comma-separated list of output formats (bruno, openapi, aggregate-json)")
+	emitOpenAPI := flag.String("emit-openapi", "", "Also write an OpenAPI document to this path (.json, .yaml or .yml), shorthand for adding openapi to -format with -openapi-output")
+	openAPIOutput := flag.String("openapi-output", "./openapi.json", "File path for the OpenAPI document")
+	aggregateOutput := flag.String("aggregate-output", "./requests.json", "File path for the aggregate-json document")
+	infer := flag.Bool("infer", false, "Infer route details (e.g. responses) from handler bodies")
+	framework := flag.String("framework", "", "Router framework whose registrations add routes for handlers without a @route ("+strings.Join(frameworkNames(), ", ")+")")
+	maxBodyBytes := flag.Int("max-body-bytes", DefaultMaxBodyBytes, "Maximum size of a generated example body before it is truncated (0 disables)")
+	tagMode := flag.String("tag-mode", TagModeAlias, "How multi-tag routes appear in secondary tag folders (alias, duplicate)")
+	environmentList := flag.String("environments", "", "Comma-separated environments as name=url or name=url|/path-prefix")
+	environmentVarList := flag.String("env-vars", "", "Comma-separated name=value variables added to every environment")
+	typeExampleList := flag.String("type-examples", "", "Comma-separated type=example mappings for example bodies, e.g. money.Amount=9.99 or civil.Date=\"2006-01-02\"")
+	authMiddlewareList := flag.String("auth-middleware", "", "Comma-separated middleware=auth mappings used by -infer (e.g. auth.Required=bearer)")
+	logFormat := flag.String("log-format", "json", "Log output format (json, text, logfmt)")
+	flag.StringVar(logFormat, "logformat", "json", "Deprecated alias of -log-format")
+	logLevel := flag.String("log-level", "info", "Minimum level of the logs shown (debug, info, warn, error)")
+	noBaseURL := flag.Bool("no-baseurl", false, "Generate relative request URLs without a base URL")
+	flattenBody := flag.Bool("flatten-body", false, "Flatten nested structs into dotted keys in example bodies")
+	includeOptional := flag.Bool("include-optional", true, "Include omitempty fields that aren't required in example bodies, false for minimal bodies")
+	pathFolders := flag.Bool("path-folders", false, "Place requests without a @tag in folders mirroring their path, e.g. users/posts for /users/:id/posts")
+	prefixFolder := flag.Bool("prefix-folder", false, "Group requests under a top-level folder for the path prefix all routes share")
+	defaultDescription := flag.String("default-description", "", "Template for routes without a @description, e.g. \"{{.Method}} {{.Path}} handled by {{.Handler}}\"")
+	includeTags := flag.String("include-tag", "", "Comma-separated tags, only routes with one of them are generated")
+	excludeTags := flag.String("exclude-tag", "", "Comma-separated tags, routes with any of them are skipped (e.g. internal)")
+	methodList := flag.String("methods", "", "Comma-separated methods to generate, e.g. GET,POST (defaults to all)")
+	skipDeprecated := flag.Bool("skip-deprecated", false, "Skip routes annotated with @deprecated")
+	sortMode := flag.String("sort", SortSource, "Order of the generated requests (source, path, method)")
+	includeTests := flag.Bool("include-tests", false, "Also scan _test.go files for annotated handlers")
+	excludeList := flag.String("exclude", "", "Comma-separated glob patterns of files and directories to skip, e.g. mocks,internal/legacy/*.go ("+strings.Join(DefaultExcludes, ", ")+" are always skipped)")
+	gitignore := flag.Bool("gitignore", false, "Also skip the paths listed in the input directory's .gitignore")
+	reportPath := flag.String("report", "", "File path for a JSON report of every warning and error (e.g. warnings.json)")
+	detectDuplicateBodies := flag.Bool("detect-duplicate-bodies", false, "Report requests that share an identical example body")
+	cpuProfile := flag.String("cpuprofile", "", "Write a CPU profile to this file")
+	memProfile := flag.String("memprofile", "", "Write a memory profile to this file when the run finishes")
+	docsSource := flag.Bool("docs-source", false, "Include the handler's source in each request's docs")
+	dryRun := flag.Bool("dry-run", false, "Print the Bruno files to stdout instead of writing them, other formats are skipped")
+	scripts := flag.Bool("scripts", false, "Add a post-response script stub to every request, saving the token of login routes")
+	overwrite := flag.Bool("overwrite", true, "Replace existing request files, false skips them")
+	merge := flag.Bool("merge", false, "Regenerate existing request files in place, keeping their assert, script and tests blocks")
+	strict := flag.Bool("strict", false, "Fail on problems that are otherwise reported as warnings")
+	collectionDocs := flag.Bool("collection-docs", false, "Write an index of every route, grouped by tag, to the docs of collection.bru")
+	collectionName := flag.String("collection-name", "", "Name written to bruno.json (defaults to the output directory name)")
+	initExample := flag.Bool("init", false, "Write an annotated example handler and its collection to ./"+InitExampleDir)
+	showVersion := flag.Bool("version", false, "Print the version, commit and build date, then exit")
+	configFile := flag.String("config", "", "Config file of flag defaults (defaults to the first of "+strings.Join(configFileNames, ", ")+" found walking up to the repository root)")
 	flag.Parse()
 
-	// Create the parser that extracts annotated handlers
-	parser := NewParser()
+	if *showVersion {
+		fmt.Println(versionInfo())
+		return
+	}
 
-	// Parse the handler functions and struct definitions
-	logger.Info(fmt.Sprintf("Scanning %s for annotated handlers...", *inputDir))
-	routes, err := parser.ParseDirectory(*inputDir)
+	// Flags given on the command line win over the config file, which wins over the defaults
+	configPath, err := loadConfig(flag.CommandLine, *configFile)
 	if err != nil {
-		logger.Error(fmt.Sprintf("Error parsing code: %v", err))
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-	logger.Info(fmt.Sprintf("Found %d handlers with route annotations", len(routes)))
 
-	// TODO: take the URL as an input? Need to detect if we already have the directory / bruno.json
-	// and go from there. Moreso the
-	brunoGen := NewBrunoGenerator(*outputDir, "api.example.com")
+	if !validLogLevel(*logLevel) {
+		fmt.Fprintf(os.Stderr, "Unsupported log level %q\n", *logLevel)
+		os.Exit(1)
+	}
+	if !validLogFormat(*logFormat) {
+		fmt.Fprintf(os.Stderr, "Unsupported log format %q\n", *logFormat)
+		os.Exit(1)
+	}
+	initializeLogging(*logFormat, *logLevel)
+
+	logger := getLogger()
+	if configPath != "" {
+		logger.Debug(fmt.Sprintf("Using config file %s", configPath))
+	}
+
+	stopCPUProfile := func() error { return nil }
+	if *cpuProfile != "" {
+		stop, err := startCPUProfile(*cpuProfile)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Error starting CPU profile: %v", err))
+			os.Exit(1)
+		}
+		stopCPUProfile = stop
+	}
 
-	// TODO: generate the bruno.json file.
+	// exit finishes the profiles and writes the report, if they were requested, before exiting
+	exit := func(code int) {
+		if err := stopCPUProfile(); err != nil {
+			logger.Error(fmt.Sprintf("Error writing CPU profile: %v", err))
+			code = 1
+		}
+		if *memProfile != "" {
+			if err := writeMemProfile(*memProfile); err != nil {
+				logger.Error(fmt.Sprintf("Error writing memory profile: %v", err))
+				code = 1
+			}
+		}
+		if *reportPath != "" {
+			if err := reporter.WriteReport(*reportPath); err != nil {
+				logger.Error(fmt.Sprintf("Error writing report: %v", err))
+				code = 1
+			}
+		}
+		os.Exit(code)
+	}
 
-	// Generate Bruno files for each handler with route annotations
-	for _, route := range routes {
-		logger.Info(fmt.Sprintf("Processing handler: %s %s", route.Method, route.Path))
-		// Generate Bruno .bru file
-		if err := brunoGen.GenerateRequestFile(route); err != nil {
-			logger.Error(fmt.Sprintf("Error generating Bruno file: %v", err))
+	if *initExample {
+		if err := runInit(InitExampleDir); err != nil {
+			logger.Error(fmt.Sprintf("Error writing example: %v", err))
+			exit(1)
+		}
+		logger.Info(fmt.Sprintf("Wrote example handlers and collection to %s", InitExampleDir))
+		exit(0)
+	}
+
+	if *tagMode != TagModeAlias && *tagMode != TagModeDuplicate {
+		logger.Error(fmt.Sprintf("Unsupported tag mode %q", *tagMode))
+		exit(1)
+	}
+
+	if *sortMode != SortSource && *sortMode != SortPath && *sortMode != SortMethod {
+		logger.Error(fmt.Sprintf("Unsupported sort mode %q", *sortMode))
+		exit(1)
+	}
+
+	formats, err := parseFormats(*formatList)
+	if err != nil {
+		logger.Error(err.Error())
+		exit(1)
+	}
+	if *emitOpenAPI != "" {
+		*openAPIOutput = *emitOpenAPI
+		if !slices.Contains(formats, "openapi") {
+			formats = append(formats, "openapi")
+		}
+	}
+
+	environments, err := parseEnvironments(*environmentList)
+	if err != nil {
+		logger.Error(err.Error())
+		exit(1)
+	}
+
+	environmentVars, err := parseEnvironmentVars(*environmentVarList)
+	if err != nil {
+		logger.Error(err.Error())
+		exit(1)
+	}
+
+	baseURL, err := parseBaseURL(*baseURLFlag)
+	if err != nil {
+		logger.Error(err.Error())
+		exit(1)
+	}
+
+	if err := validateFramework(*framework); err != nil {
+		logger.Error(err.Error())
+		exit(1)
+	}
+
+	// Create the parser that extracts annotated handlers
+	parser := NewParser()
+	parser.Infer = *infer
+	parser.Framework = *framework
+	parser.Strict = *strict
+	parser.IncludeTests = *includeTests
+	parser.Exclude = splitList(*excludeList)
+	parser.Gitignore = *gitignore
+	parser.DocsSource = *docsSource
+	if *defaultDescription != "" {
+		parser.DefaultDescription, err = template.New("default-description").Parse(*defaultDescription)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Invalid default description template: %v", err))
+			exit(1)
+		}
+	}
+	for _, mapping := range splitList(*typeExampleList) {
+		typeName, example, found := strings.Cut(mapping, "=")
+		if !found {
+			logger.Error(fmt.Sprintf("Invalid type example %q, expected type=example", mapping))
+			exit(1)
+		}
+		RegisterWellKnownType(strings.TrimSpace(typeName), parseTypeExample(strings.TrimSpace(example)))
+	}
+
+	for _, mapping := range strings.Split(*authMiddlewareList, ",") {
+		if strings.TrimSpace(mapping) == "" {
 			continue
 		}
+		name, mode, found := strings.Cut(mapping, "=")
+		if !found {
+			logger.Error(fmt.Sprintf("Invalid auth middleware mapping %q, expected middleware=auth", mapping))
+			exit(1)
+		}
+		parser.AuthMiddleware[strings.TrimSpace(name)] = strings.TrimSpace(mode)
+	}
+
+	// Parse the handler functions and struct definitions, or the operations of a spec
+	var routes []*Route
+	if *openAPIInput != "" {
+		logger.Info(fmt.Sprintf("Importing routes from %s...", *openAPIInput))
+		routes, err = ImportOpenAPI(*openAPIInput)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Error importing OpenAPI document: %v", err))
+			exit(1)
+		}
+		logger.Info(fmt.Sprintf("Found %d operations", len(routes)))
+	} else {
+		logger.Info(fmt.Sprintf("Scanning %s for annotated handlers...", *inputDir))
+		routes, err = parser.ParseDirectory(*inputDir)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Error parsing code: %v", err))
+			exit(1)
+		}
+		if diagnostics := parser.Diagnostics(); len(diagnostics) > 0 {
+			logger.Warn(fmt.Sprintf("Skipped %d malformed annotations, run with -strict to fail on them", len(diagnostics)))
+		}
+		logger.Info(fmt.Sprintf("Found %d handlers with route annotations", len(routes)))
+	}
+
+	// Leave out the routes the collection isn't meant to show, e.g. internal ones
+	filter := allFilters(
+		methodFilter(splitList(*methodList)),
+		includeTagFilter(splitList(*includeTags)),
+		excludeTagFilter(splitList(*excludeTags)),
+		deprecationFilter(*skipDeprecated),
+	)
+	if filtered := filterRoutes(routes, filter); len(filtered) != len(routes) {
+		logger.Info(fmt.Sprintf("Skipping %d routes excluded by -methods, -include-tag, -exclude-tag or -skip-deprecated", len(routes)-len(filtered)))
+		routes = filtered
 	}
-	logger.Info(fmt.Sprintf("\nDone! Generated Bruno files in %s", *outputDir))
+
+	newBrunoGenerator := func() *BrunoGenerator {
+		brunoGen := NewBrunoGenerator(*outputDir, baseURL)
+		brunoGen.Config.MaxBodyBytes = *maxBodyBytes
+		brunoGen.Config.TagMode = *tagMode
+		brunoGen.Config.Environments = environments
+		brunoGen.Config.EnvironmentVariables = environmentVars
+		brunoGen.Config.NoBaseURL = *noBaseURL
+		brunoGen.Config.FlattenBody = *flattenBody
+		brunoGen.Config.OmitOptional = !*includeOptional
+		brunoGen.Config.PathFolders = *pathFolders
+		brunoGen.Config.SortMode = *sortMode
+		brunoGen.Config.Variables = parser.Variables()
+		brunoGen.Config.Strict = *strict
+		brunoGen.Config.DetectDuplicateBodies = *detectDuplicateBodies
+		brunoGen.Config.CollectionName = *collectionName
+		brunoGen.Config.DryRun = *dryRun
+		brunoGen.Config.Scripts = *scripts
+		brunoGen.Config.Overwrite = *overwrite
+		brunoGen.Config.Merge = *merge
+		return brunoGen
+	}
+
+	// Every generator runs over the same parsed routes so the codebase is only scanned once.
+	generators := map[string]artifactGenerator{
+		"bruno": func(routes []*Route) (string, error) {
+			brunoGen := newBrunoGenerator()
+			if *prefixFolder {
+				brunoGen.DetectPrefixFolder(routes)
+			}
+
+			// Generate Bruno files for each handler with route annotations
+			if err := brunoGen.GenerateCollection(routes); err != nil {
+				return "", err
+			}
+
+			if *collectionDocs {
+				brunoGen.GenerateCollectionDocs(routes)
+			}
+			if err := brunoGen.GenerateCollectionFile(); err != nil {
+				return "", err
+			}
+
+			for _, requests := range brunoGen.DuplicateBodies() {
+				logger.Info(fmt.Sprintf("%d requests share an identical body: %s", len(requests), strings.Join(requests, ", ")))
+			}
+
+			// Environments come last so they can declare the variables the requests referenced.
+			if err := brunoGen.GenerateEnvironments(); err != nil {
+				return "", err
+			}
+
+			if *dryRun {
+				logger.Info(fmt.Sprintf("Dry run: %d files would be written to %s", brunoGen.DryRunFileCount(), *outputDir))
+			}
+			return *outputDir, nil
+		},
+		"aggregate-json": func(routes []*Route) (string, error) {
+			brunoGen := newBrunoGenerator()
+			return *aggregateOutput, brunoGen.GenerateAggregate(*aggregateOutput, routes)
+		},
+		"openapi": func(routes []*Route) (string, error) {
+			openAPIGen := NewOpenAPIGenerator(*openAPIOutput, baseURL)
+			return *openAPIOutput, openAPIGen.GenerateDocument(routes)
+		},
+	}
+
+	for _, format := range formats {
+		if *dryRun && format != "bruno" {
+			logger.Info(fmt.Sprintf("Skipping %s output on a dry run", format))
+			continue
+		}
+
+		artifact, err := generators[format](routes)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Error generating %s output: %v", format, err))
+			exit(1)
+		}
+		if !*dryRun {
+			logger.Info(fmt.Sprintf("Wrote %s output to %s", format, artifact))
+		}
+	}
+
+	logger.Info("Done!")
+
+	// Errors that didn't stop the run (e.g. skipped requests) still fail it
+	if reporter.hasErrors() {
+		exit(1)
+	}
+	exit(0)
+}
+
+// parseFormats splits a comma-separated format list, dropping duplicates and
+// rejecting formats we don't know how to generate
+func parseFormats(formatList string) ([]string, error) {
+	formats := []string{}
+	seen := make(map[string]bool)
+
+	for _, format := range strings.Split(formatList, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format == "" || seen[format] {
+			continue
+		}
+
+		switch format {
+		case "bruno", "openapi", "aggregate-json":
+		default:
+			return nil, fmt.Errorf("unsupported output format %q", format)
+		}
+
+		seen[format] = true
+		formats = append(formats, format)
+	}
+
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no output format selected")
+	}
+
+	return formats, nil
+}
+
+// parseBaseURL checks the -url flag is an absolute URL and drops any trailing slash,
+// since route paths already start with one
+func parseBaseURL(rawURL string) (string, error) {
+	rawURL = strings.TrimRight(strings.TrimSpace(rawURL), "/")
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
+		return "", fmt.Errorf("invalid base URL %q, expected e.g. http://localhost:8080", rawURL)
+	}
+	return rawURL, nil
+}
+
+// parseEnvironmentVars parses the -env-vars flag of comma-separated name=value pairs
+func parseEnvironmentVars(varList string) (map[string]string, error) {
+	vars := make(map[string]string)
+
+	for _, entry := range strings.Split(varList, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		name, value, found := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid environment variable %q, expected name=value", entry)
+		}
+		if name == BaseURLVariable {
+			return nil, fmt.Errorf("%s is set from -url or -environments, not -env-vars", BaseURLVariable)
+		}
+		vars[name] = strings.TrimSpace(value)
+	}
+
+	return vars, nil
+}
+
+// parseEnvironments parses the -environments flag. Each entry is name=url with an
+// optional |/prefix for deployments that serve the API below a path.
+func parseEnvironments(environmentList string) ([]BrunoEnvironment, error) {
+	environments := []BrunoEnvironment{}
+
+	for _, entry := range strings.Split(environmentList, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, value, found := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid environment %q, expected name=url", entry)
+		}
+
+		rawURL, basePath, _ := strings.Cut(value, "|")
+		rawURL = strings.TrimRight(strings.TrimSpace(rawURL), "/")
+		parsedURL, err := url.Parse(rawURL)
+		if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
+			return nil, fmt.Errorf("invalid URL %q for environment %s", rawURL, name)
+		}
+
+		basePath = strings.TrimRight(strings.TrimSpace(basePath), "/")
+		if basePath != "" && !strings.HasPrefix(basePath, "/") {
+			return nil, fmt.Errorf("path prefix %q for environment %s must start with /", basePath, name)
+		}
+
+		environments = append(environments, BrunoEnvironment{
+			Name:     name,
+			BaseURL:  rawURL,
+			BasePath: basePath,
+		})
+	}
+
+	return environments, nil
 }
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseFormats(t *testing.T) {
+	formats, err := parseFormats(" Bruno,openapi, bruno ,aggregate-json,")
+	if err != nil {
+		t.Fatalf("parseFormats() error = %v", err)
+	}
+	if want := []string{"bruno", "openapi", "aggregate-json"}; !slices.Equal(formats, want) {
+		t.Errorf("parseFormats() = %q, want %q", formats, want)
+	}
+
+	for _, formatList := range []string{"bruno,postman", " , "} {
+		if _, err := parseFormats(formatList); err == nil {
+			t.Errorf("parseFormats(%q) accepted it", formatList)
+		}
+	}
+}
+
+func TestParseEnvironmentsWithPathPrefixes(t *testing.T) {
+	environments, err := parseEnvironments("prod=https://api.example.com/, staging = https://staging.example.com|/staging/")
+	if err != nil {
+		t.Fatalf("parseEnvironments() error = %v", err)
+	}
+	want := []BrunoEnvironment{
+		{Name: "prod", BaseURL: "https://api.example.com"},
+		{Name: "staging", BaseURL: "https://staging.example.com", BasePath: "/staging"},
+	}
+	if !slices.Equal(environments, want) {
+		t.Errorf("parseEnvironments() = %+v, want %+v", environments, want)
+	}
+
+	for _, environmentList := range []string{"prod", "prod=api.example.com", "staging=https://staging.example.com|staging"} {
+		if _, err := parseEnvironments(environmentList); err == nil {
+			t.Errorf("parseEnvironments(%q) accepted it", environmentList)
+		}
+	}
+}
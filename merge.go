@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ProvenanceMarker starts the first line of every file brungo writes. It carries a hash of the
+// generated content so a re-run can tell untouched files apart from ones edited in Bruno.
+const ProvenanceMarker = "# brungo:generated"
+
+type bruBlock struct {
+	Name string
+	Raw  string
+}
+
+// withProvenance prefixes generated content with the provenance marker
+func withProvenance(content string) string {
+	return fmt.Sprintf("%s sha256=%s\n%s", ProvenanceMarker, contentHash(content), content)
+}
+
+// isUntouched reports whether a file still matches the content brungo generated for it
+func isUntouched(existing string) bool {
+	firstLine, rest, _ := strings.Cut(existing, "\n")
+	if !strings.HasPrefix(firstLine, ProvenanceMarker) {
+		return false
+	}
+
+	recorded := strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(firstLine, ProvenanceMarker)), "sha256=")
+	return recorded == contentHash(rest)
+}
+
+// contentHash hashes the content of a generated file
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// mergedFileContent returns the content to write to path for generated content. Untouched or
+// missing files are replaced, while edited files keep their hand-written blocks and only get the
+// generated blocks refreshed.
+func mergedFileContent(path string, generated string) (string, error) {
+	generated = generatedHeader(nil, []string{}) + generated
+
+	existing, err := os.ReadFile(path)
+	if os.IsNotExist(err) || (err == nil && isUntouched(string(existing))) {
+		return withProvenance(generated), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	merged := generatedHeader(nil, []string{}) + mergeBruBlocks(parseBruBlocks(string(existing)), parseBruBlocks(generated))
+
+	// Keep the hash of the pure generated content so the file stays marked as edited.
+	return fmt.Sprintf("%s sha256=%s\n%s", ProvenanceMarker, contentHash(generated), merged), nil
+}
+
+// userBlocks are the blocks of a request file that are kept as written in Bruno when merging,
+// rather than replaced with the generated ones
+var userBlocks = map[string]bool{
+	"assert":               true,
+	"script:pre-request":   true,
+	"script:post-response": true,
+	"tests":                true,
+}
+
+// keptRequestBlocks returns the names of userBlocks in order, for the header of merged request files
+func keptRequestBlocks() []string {
+	names := make([]string, 0, len(userBlocks))
+	for name := range userBlocks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mergeRequestFile regenerates an existing request file, keeping its hand-written assert,
+// script and tests blocks along with any other block brungo doesn't generate
+func mergeRequestFile(existing string, generated string) string {
+	existingBlocks := parseBruBlocks(existing)
+	kept := make(map[string]bool)
+	for _, block := range existingBlocks {
+		if userBlocks[block.Name] {
+			kept[block.Name] = true
+		}
+	}
+
+	regenerated := []bruBlock{}
+	for _, block := range parseBruBlocks(generated) {
+		if !kept[block.Name] {
+			regenerated = append(regenerated, block)
+		}
+	}
+
+	return mergeBruBlocks(existingBlocks, regenerated)
+}
+
+// mergeBruBlocks replaces the existing blocks that brungo generates, keeping everything
+// else in its original order and appending generated blocks that are missing
+func mergeBruBlocks(existing, generated []bruBlock) string {
+	generatedByName := make(map[string]bruBlock)
+	for _, block := range generated {
+		generatedByName[block.Name] = block
+	}
+
+	used := make(map[string]bool)
+	blocks := []string{}
+	for _, block := range existing {
+		if replacement, ok := generatedByName[block.Name]; ok {
+			blocks = append(blocks, replacement.Raw)
+			used[block.Name] = true
+			continue
+		}
+		blocks = append(blocks, block.Raw)
+	}
+
+	for _, block := range generated {
+		if !used[block.Name] {
+			blocks = append(blocks, block.Raw)
+		}
+	}
+
+	return joinSections(blocks) + "\n"
+}
+
+// parseBruBlocks splits a .bru file into its top-level blocks. Blocks open with a line ending
+// in '{' or '[' and close with an unindented '}' or ']'. Comments outside blocks are dropped.
+func parseBruBlocks(content string) []bruBlock {
+	blocks := []bruBlock{}
+
+	var current []string
+	var name string
+	for _, line := range strings.Split(content, "\n") {
+		if current == nil {
+			trimmed := strings.TrimSpace(line)
+			if strings.HasSuffix(trimmed, "{") || strings.HasSuffix(trimmed, "[") {
+				name = strings.TrimSpace(strings.TrimRight(trimmed, "{["))
+				current = []string{line}
+			}
+			continue
+		}
+
+		current = append(current, line)
+		if line == "}" || line == "]" {
+			blocks = append(blocks, bruBlock{Name: name, Raw: strings.Join(current, "\n")})
+			current = nil
+		}
+	}
+
+	return blocks
+}
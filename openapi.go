@@ -0,0 +1,769 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type OpenAPIGenerator struct {
+	OutputPath string
+	BaseURL    string
+	Title      string
+	Version    string
+}
+
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Servers    []OpenAPIServer                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components *OpenAPIComponents                      `json:"components,omitempty"`
+}
+
+type OpenAPIComponents struct {
+	SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type OpenAPISecurityScheme struct {
+	Type   string             `json:"type"`
+	Scheme string             `json:"scheme,omitempty"`
+	In     string             `json:"in,omitempty"`
+	Name   string             `json:"name,omitempty"`
+	Flows  *OpenAPIOAuthFlows `json:"flows,omitempty"`
+}
+
+type OpenAPIOAuthFlows struct {
+	AuthorizationCode *OpenAPIOAuthFlow `json:"authorizationCode,omitempty"`
+	ClientCredentials *OpenAPIOAuthFlow `json:"clientCredentials,omitempty"`
+}
+
+type OpenAPIOAuthFlow struct {
+	AuthorizationURL string            `json:"authorizationUrl,omitempty"`
+	TokenURL         string            `json:"tokenUrl"`
+	Scopes           map[string]string `json:"scopes"`
+}
+
+// OpenAPISecurityRequirement maps security scheme names onto the scopes they need
+type OpenAPISecurityRequirement map[string][]string
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+type OpenAPIOperation struct {
+	OperationID string                       `json:"operationId,omitempty"`
+	Tags        []string                     `json:"tags,omitempty"`
+	Summary     string                       `json:"summary,omitempty"`
+	Description string                       `json:"description,omitempty"`
+	Parameters  []OpenAPIParameter           `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse  `json:"responses"`
+	Security    []OpenAPISecurityRequirement `json:"security,omitempty"`
+	Deprecated  bool                         `json:"deprecated,omitempty"`
+	RateLimit   string                       `json:"x-rate-limit,omitempty"` // From @ratelimit, OpenAPI has no field of its own
+}
+
+type OpenAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required"`
+	Schema      *OpenAPISchema `json:"schema,omitempty"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPISchema struct {
+	Type                 string                    `json:"type,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Ref                  string                    `json:"$ref,omitempty"` // Only read when importing a spec
+}
+
+// UnmarshalJSON reads a schema whose additionalProperties may also be a bool when importing a
+// spec: true allows any value and reads as an empty schema, false as no schema at all
+func (s *OpenAPISchema) UnmarshalJSON(data []byte) error {
+	type plainSchema OpenAPISchema
+	var schema struct {
+		plainSchema
+		AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return err
+	}
+	*s = OpenAPISchema(schema.plainSchema)
+
+	switch additional := strings.TrimSpace(string(schema.AdditionalProperties)); additional {
+	case "", "null", "false":
+		return nil
+	case "true":
+		s.AdditionalProperties = &OpenAPISchema{}
+		return nil
+	}
+	s.AdditionalProperties = &OpenAPISchema{}
+	return json.Unmarshal(schema.AdditionalProperties, s.AdditionalProperties)
+}
+
+const OpenAPIVersion = "3.0.3"
+
+// NewOpenAPIGenerator creates a new OpenAPI generator instance
+func NewOpenAPIGenerator(outputPath string, baseURL string) *OpenAPIGenerator {
+	return &OpenAPIGenerator{
+		OutputPath: outputPath,
+		BaseURL:    baseURL,
+		Title:      "brungo",
+		Version:    "1.0.0",
+	}
+}
+
+// GenerateDocument writes an OpenAPI document describing every route to OutputPath, as
+// YAML when it ends in .yaml or .yml and as JSON otherwise
+func (g *OpenAPIGenerator) GenerateDocument(routes []*Route) error {
+	doc := g.buildDocument(routes)
+
+	jsonBytes, err := json.MarshalIndent(doc, "", JSONOutputIndent)
+	if err != nil {
+		return err
+	}
+
+	if ext := strings.ToLower(filepath.Ext(g.OutputPath)); ext == ".yaml" || ext == ".yml" {
+		jsonBytes, err = jsonToYAML(jsonBytes)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Make sure the parent directory exists.
+	if err := os.MkdirAll(filepath.Dir(g.OutputPath), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(g.OutputPath, jsonBytes, 0644)
+}
+
+// buildDocument maps the parsed routes onto an OpenAPI document
+func (g *OpenAPIGenerator) buildDocument(routes []*Route) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info: OpenAPIInfo{
+			Title:   g.Title,
+			Version: g.Version,
+		},
+		Paths: make(map[string]map[string]*OpenAPIOperation),
+	}
+
+	if g.BaseURL != "" {
+		doc.Servers = []OpenAPIServer{{URL: g.BaseURL}}
+	}
+
+	for _, route := range routes {
+		// gRPC methods aren't part of the HTTP API surface
+		if route.GRPC != nil {
+			continue
+		}
+
+		path := openAPIPath(route.Path)
+
+		operation := &OpenAPIOperation{
+			OperationID: route.Handler,
+			Tags:        route.Tags,
+			Summary:     route.Name,
+			Description: strings.TrimSpace(route.Description),
+			Deprecated:  route.Deprecated,
+			RateLimit:   route.RateLimit,
+			Responses: map[string]*OpenAPIResponse{
+				"default": {Description: "Default response"},
+			},
+		}
+
+		for _, response := range route.Responses {
+			openAPIResponse := &OpenAPIResponse{Description: http.StatusText(response.StatusCode)}
+			if response.Body != nil {
+				openAPIResponse.Content = map[string]OpenAPIMediaType{
+					"application/json": {Schema: openAPISchemaForBody(response.Body, DefaultContentType)},
+				}
+			}
+			operation.Responses[strconv.Itoa(response.StatusCode)] = openAPIResponse
+		}
+		if len(route.Responses) > 0 {
+			delete(operation.Responses, "default")
+		}
+
+		for _, param := range pathParams(route) {
+			schema := &OpenAPISchema{Type: "string"}
+			if param.Type != "" {
+				schema = openAPISchemaForType(param.Type)
+			}
+			operation.Parameters = append(operation.Parameters, OpenAPIParameter{
+				Name:     param.Name,
+				In:       "path",
+				Required: true,
+				Schema:   schema,
+			})
+		}
+
+		for _, header := range route.Headers {
+			operation.Parameters = append(operation.Parameters, OpenAPIParameter{
+				Name:   header.Name,
+				In:     "header",
+				Schema: &OpenAPISchema{Type: "string"},
+			})
+		}
+
+		for _, param := range route.QueryParams {
+			operation.Parameters = append(operation.Parameters, OpenAPIParameter{
+				Name:        param.Name,
+				In:          "query",
+				Description: param.Description,
+				Required:    param.Required,
+				Schema:      openAPISchemaForType(param.Type),
+			})
+		}
+
+		if name, scheme := openAPISecurityScheme(route); scheme != nil {
+			if doc.Components == nil {
+				doc.Components = &OpenAPIComponents{SecuritySchemes: make(map[string]*OpenAPISecurityScheme)}
+			}
+			// Routes sharing an oauth2 scheme each add their scopes to it
+			if existing, ok := doc.Components.SecuritySchemes[name]; ok {
+				scheme = existing
+			}
+			if flow := scheme.oauthFlow(); flow != nil {
+				for _, scope := range route.Scopes {
+					flow.Scopes[scope] = ""
+				}
+			}
+			doc.Components.SecuritySchemes[name] = scheme
+
+			scopes := route.Scopes
+			if scopes == nil {
+				scopes = []string{}
+			}
+			operation.Security = []OpenAPISecurityRequirement{{name: scopes}}
+		}
+
+		if route.ContentType == GraphQLContentType {
+			operation.RequestBody = &OpenAPIRequestBody{
+				Required: true,
+				Content: map[string]OpenAPIMediaType{
+					bodyRendererFor(route).MIMEType(): {Schema: openAPIGraphQLSchema(route)},
+				},
+			}
+		} else if route.RequestBody != nil {
+			operation.RequestBody = &OpenAPIRequestBody{
+				Required: true,
+				Content: map[string]OpenAPIMediaType{
+					bodyRendererFor(route).MIMEType(): {Schema: openAPISchemaForBody(route.RequestBody, route.ContentType)},
+				},
+			}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*OpenAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = operation
+	}
+
+	return doc
+}
+
+// openAPISecurityScheme returns the security scheme for a route's auth mode and the name it
+// is registered under, or a nil scheme for routes without auth
+func openAPISecurityScheme(route *Route) (string, *OpenAPISecurityScheme) {
+	switch route.Auth {
+	case "bearer":
+		// Tokens in another header or with another prefix can only be described as an api key
+		if isCustomBearer(route) {
+			return "bearer-" + bearerHeader(route), &OpenAPISecurityScheme{Type: "apiKey", In: "header", Name: bearerHeader(route)}
+		}
+		return "bearerAuth", &OpenAPISecurityScheme{Type: "http", Scheme: "bearer"}
+	case "basic":
+		return "basicAuth", &OpenAPISecurityScheme{Type: "http", Scheme: "basic"}
+	case "oauth2":
+		flow := &OpenAPIOAuthFlow{TokenURL: route.AuthOptions.TokenURL, Scopes: make(map[string]string)}
+		if oauth2Grant(route) == OAuth2ClientCredentials {
+			return "oauth2-client-credentials", &OpenAPISecurityScheme{Type: "oauth2", Flows: &OpenAPIOAuthFlows{ClientCredentials: flow}}
+		}
+		flow.AuthorizationURL = route.AuthOptions.AuthURL
+		return "oauth2-authorization-code", &OpenAPISecurityScheme{Type: "oauth2", Flows: &OpenAPIOAuthFlows{AuthorizationCode: flow}}
+	case "apikey":
+		in := "header"
+		if isQueryAPIKey(route) {
+			in = "query"
+		}
+		name := apiKeyName(route)
+		// Routes can send their key under different names, so each gets its own scheme
+		return "apiKey-" + name, &OpenAPISecurityScheme{Type: "apiKey", In: in, Name: name}
+	}
+	return "", nil
+}
+
+// oauthFlow returns the flow of an oauth2 scheme, or nil for other schemes
+func (s *OpenAPISecurityScheme) oauthFlow() *OpenAPIOAuthFlow {
+	switch {
+	case s.Flows == nil:
+		return nil
+	case s.Flows.ClientCredentials != nil:
+		return s.Flows.ClientCredentials
+	default:
+		return s.Flows.AuthorizationCode
+	}
+}
+
+// openAPISchemaForBody builds an object schema from a request body's fields, naming
+// them as they are sent for the body's content type
+func openAPISchemaForBody(requestBody *RequestBody, contentType string) *OpenAPISchema {
+	schema := &OpenAPISchema{
+		Type:        "object",
+		Description: requestBody.Description,
+		Properties:  make(map[string]*OpenAPISchema),
+	}
+
+	for _, field := range requestBody.Fields {
+		// Inline maps allow arbitrary extra keys on the parent object
+		if isAdditionalProperties(field) {
+			schema.AdditionalProperties = openAPISchemaForType(field.MapValueType)
+			if field.Nested != nil {
+				schema.AdditionalProperties = openAPISchemaForBody(field.Nested, contentType)
+			}
+			continue
+		}
+		if field.Inline {
+			continue
+		}
+
+		fieldSchema := openAPISchemaForType(field.Type)
+		switch {
+		case field.Type == "array" && field.Nested != nil:
+			fieldSchema.Items = openAPISchemaForBody(field.Nested, contentType)
+		case field.Type == "array":
+			fieldSchema.Items = openAPISchemaForType(field.ElemType)
+		case field.Type == "map" && field.Nested != nil:
+			fieldSchema.AdditionalProperties = openAPISchemaForBody(field.Nested, contentType)
+		case field.Type == "map":
+			fieldSchema.AdditionalProperties = openAPISchemaForType(field.MapValueType)
+		case field.Nested != nil:
+			fieldSchema = openAPISchemaForBody(field.Nested, contentType)
+		}
+		fieldSchema.Description = field.Description
+		fieldSchema.Nullable = field.Pointer
+
+		// OpenAPI 3.0 can't express the dependency directly, so call it out in the description.
+		if field.When != nil {
+			note := fmt.Sprintf("Only applies when %s is %s.", field.When.Field, field.When.Value)
+			if fieldSchema.Description != "" && !strings.HasSuffix(fieldSchema.Description, ".") {
+				fieldSchema.Description += "."
+			}
+			fieldSchema.Description = strings.TrimSpace(fieldSchema.Description + " " + note)
+		}
+		name := wireName(field, contentType)
+		schema.Properties[name] = fieldSchema
+
+		if field.Required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	return schema
+}
+
+// openAPIGraphQLSchema describes the body of a GraphQL request, the query along with the
+// @body fields as its variables
+func openAPIGraphQLSchema(route *Route) *OpenAPISchema {
+	variables := &OpenAPISchema{Type: "object"}
+	if route.RequestBody != nil {
+		variables = openAPISchemaForBody(route.RequestBody, DefaultContentType)
+	}
+	return &OpenAPISchema{
+		Type: "object",
+		Properties: map[string]*OpenAPISchema{
+			"query":     {Type: "string"},
+			"variables": variables,
+		},
+		Required: []string{"query"},
+	}
+}
+
+// openAPISchemaForType maps a parsed field type onto an OpenAPI schema
+func openAPISchemaForType(fieldType string) *OpenAPISchema {
+	// Well-known types are described by the value they marshal to
+	if example, ok := wellKnownTypes[fieldType]; ok {
+		switch example.(type) {
+		case string:
+			return &OpenAPISchema{Type: "string"}
+		case json.Number:
+			return &OpenAPISchema{Type: "number"}
+		case int:
+			return &OpenAPISchema{Type: "integer"}
+		case map[string]interface{}:
+			return &OpenAPISchema{Type: "object"}
+		}
+		return &OpenAPISchema{}
+	}
+
+	switch strings.ToLower(fieldType) {
+	case "string":
+		return &OpenAPISchema{Type: "string"}
+	case "int", "int64", "int32":
+		return &OpenAPISchema{Type: "integer"}
+	case "float64", "float32":
+		return &OpenAPISchema{Type: "number"}
+	case "bool":
+		return &OpenAPISchema{Type: "boolean"}
+	case "array", "slice":
+		return &OpenAPISchema{Type: "array", Items: &OpenAPISchema{}}
+	case "map":
+		return &OpenAPISchema{Type: "object"}
+	default:
+		return &OpenAPISchema{}
+	}
+}
+
+// openAPIPath converts colon style and constrained path parameters into OpenAPI templates,
+// e.g. /users/:id and /users/{id:[0-9]+} both become /users/{id}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, _, ok := splitPathParam(segment); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// openAPISpec is the part of an OpenAPI 3 document read by ImportOpenAPI. Path items are
+// kept raw since they mix operations with shared parameters.
+type openAPISpec struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas         map[string]*OpenAPISchema         `json:"schemas"`
+		SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes"`
+	} `json:"components"`
+}
+
+// openAPISchemaRefPrefix starts the $ref of every schema declared under components
+const openAPISchemaRefPrefix = "#/components/schemas/"
+
+// ImportOpenAPI reads an OpenAPI 3 JSON or YAML document and maps its operations onto routes,
+// so a collection can be generated from a spec instead of annotated handlers
+func ImportOpenAPI(specFile string) ([]*Route, error) {
+	data, err := os.ReadFile(specFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// YAML specs are converted to JSON so both share the decoder below
+	if ext := strings.ToLower(filepath.Ext(specFile)); ext == ".yaml" || ext == ".yml" {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, fmt.Errorf("reading OpenAPI document %s: %w", specFile, err)
+		}
+	}
+
+	var spec openAPISpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("reading OpenAPI document %s: %w", specFile, err)
+	}
+
+	paths := make([]string, 0, len(spec.Paths))
+	for specPath := range spec.Paths {
+		paths = append(paths, specPath)
+	}
+	sort.Strings(paths)
+
+	routes := []*Route{}
+	for _, specPath := range paths {
+		item := spec.Paths[specPath]
+
+		// Parameters declared on the path apply to each of its operations
+		var shared []OpenAPIParameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return nil, fmt.Errorf("reading parameters of %s: %w", specPath, err)
+			}
+		}
+
+		methods := []string{}
+		keys := make(map[string]string)
+		for key := range item {
+			if isOpenAPIMethod(key) {
+				methods = append(methods, strings.ToUpper(key))
+				keys[strings.ToUpper(key)] = key
+			}
+		}
+		sortMethods(methods)
+
+		for _, method := range methods {
+			var operation OpenAPIOperation
+			if err := json.Unmarshal(item[keys[method]], &operation); err != nil {
+				return nil, fmt.Errorf("reading %s %s: %w", method, specPath, err)
+			}
+			routes = append(routes, spec.route(specFile, method, specPath, &operation, shared))
+		}
+	}
+
+	return routes, nil
+}
+
+// isOpenAPIMethod reports whether a path item key is an HTTP operation
+func isOpenAPIMethod(key string) bool {
+	switch strings.ToUpper(key) {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// sortMethods puts methods in their conventional order, others sorted after them
+func sortMethods(methods []string) {
+	sort.Slice(methods, func(i, j int) bool {
+		orderI, knownI := methodOrder[methods[i]]
+		orderJ, knownJ := methodOrder[methods[j]]
+		if knownI != knownJ {
+			return knownI
+		}
+		if orderI != orderJ {
+			return orderI < orderJ
+		}
+		return methods[i] < methods[j]
+	})
+}
+
+// route maps one operation onto a route
+func (spec *openAPISpec) route(specFile, method, path string, operation *OpenAPIOperation, shared []OpenAPIParameter) *Route {
+	name := operation.Summary
+	if name == "" {
+		name = operation.OperationID
+	}
+
+	route := &Route{
+		Name:        name,
+		Method:      method,
+		Path:        path,
+		PathParams:  pathParamNames(path),
+		Handler:     operation.OperationID,
+		SourceFile:  specFile,
+		Description: operation.Description,
+		Tags:        operation.Tags,
+		Deprecated:  operation.Deprecated,
+	}
+
+	for _, param := range append(append([]OpenAPIParameter{}, shared...), operation.Parameters...) {
+		switch param.In {
+		case "query":
+			route.QueryParams = append(route.QueryParams, QueryParam{
+				Name:        param.Name,
+				Type:        spec.fieldType(param.Schema),
+				Description: param.Description,
+				Required:    param.Required,
+			})
+		case "header":
+			route.Headers = append(route.Headers, RequestHeader{Name: param.Name})
+		}
+	}
+
+	if operation.RequestBody != nil {
+		// JSON is preferred when an operation accepts several content types
+		contentTypes := make([]string, 0, len(bodyRenderers))
+		for contentType := range bodyRenderers {
+			contentTypes = append(contentTypes, contentType)
+		}
+		sort.Slice(contentTypes, func(i, j int) bool {
+			if (contentTypes[i] == DefaultContentType) != (contentTypes[j] == DefaultContentType) {
+				return contentTypes[i] == DefaultContentType
+			}
+			return contentTypes[i] < contentTypes[j]
+		})
+
+		for _, contentType := range contentTypes {
+			media, ok := operation.RequestBody.Content[bodyRenderers[contentType].MIMEType()]
+			if !ok || media.Schema == nil {
+				continue
+			}
+			route.RequestBody = spec.body(media.Schema, map[string]bool{})
+			if contentType != DefaultContentType {
+				route.ContentType = contentType
+			}
+			break
+		}
+	}
+
+	statuses := make([]string, 0, len(operation.Responses))
+	for status := range operation.Responses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			continue // default and 2XX style ranges have no single status to assert
+		}
+		response := ResponseSpec{StatusCode: code}
+		if media, ok := operation.Responses[status].Content["application/json"]; ok && media.Schema != nil {
+			response.Body = spec.body(media.Schema, map[string]bool{})
+			response.TypeName = response.Body.TypeName
+		}
+		route.Responses = append(route.Responses, response)
+	}
+
+	// Only the first security requirement is used, Bruno requests have a single auth mode
+	if len(operation.Security) > 0 {
+		for schemeName, scopes := range operation.Security[0] {
+			if scheme, ok := spec.Components.SecuritySchemes[schemeName]; ok {
+				applyOpenAPISecurity(route, scheme)
+				route.Scopes = scopes
+			}
+			break
+		}
+	}
+
+	return route
+}
+
+// applyOpenAPISecurity sets a route's auth mode from a security scheme
+func applyOpenAPISecurity(route *Route, scheme *OpenAPISecurityScheme) {
+	switch {
+	case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "bearer"):
+		route.Auth = "bearer"
+	case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "basic"):
+		route.Auth = "basic"
+	case scheme.Type == "apiKey" && scheme.In != "cookie":
+		route.Auth = "apikey"
+		route.AuthOptions.In = scheme.In
+		route.AuthOptions.Key = scheme.Name
+	}
+}
+
+// resolve follows a schema's $ref, returning the referenced schema and its name
+func (spec *openAPISpec) resolve(schema *OpenAPISchema) (*OpenAPISchema, string) {
+	if schema == nil || !strings.HasPrefix(schema.Ref, openAPISchemaRefPrefix) {
+		return schema, ""
+	}
+	name := strings.TrimPrefix(schema.Ref, openAPISchemaRefPrefix)
+	return spec.Components.Schemas[name], name
+}
+
+// body maps an object schema onto a request body. Schemas already being mapped higher
+// up are left unresolved to avoid infinite recursion.
+func (spec *openAPISpec) body(schema *OpenAPISchema, resolving map[string]bool) *RequestBody {
+	schema, name := spec.resolve(schema)
+	requestBody := &RequestBody{TypeName: name}
+	if schema == nil {
+		return requestBody
+	}
+	requestBody.Description = schema.Description
+
+	if name != "" {
+		resolving[name] = true
+		defer delete(resolving, name)
+	}
+
+	required := make(map[string]bool)
+	for _, property := range schema.Required {
+		required[property] = true
+	}
+
+	properties := make([]string, 0, len(schema.Properties))
+	for property := range schema.Properties {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+
+	for _, property := range properties {
+		field := RequestBodyField{
+			Name:     property,
+			JSONName: property,
+			Required: required[property],
+		}
+
+		propertySchema, ref := spec.resolve(schema.Properties[property])
+		if propertySchema == nil || resolving[ref] {
+			field.Type = "unknown"
+			requestBody.Fields = append(requestBody.Fields, field)
+			continue
+		}
+		field.Type = spec.fieldType(propertySchema)
+		field.Description = propertySchema.Description
+		field.Pointer = propertySchema.Nullable
+
+		switch {
+		case field.Type == "array":
+			field.ElemType = spec.fieldType(propertySchema.Items)
+			if spec.isObject(propertySchema.Items) {
+				field.Nested = spec.body(propertySchema.Items, resolving)
+			}
+		case field.Type == "map":
+			field.MapKeyType = "string"
+			field.MapValueType = spec.fieldType(propertySchema.AdditionalProperties)
+			if spec.isObject(propertySchema.AdditionalProperties) {
+				field.Nested = spec.body(propertySchema.AdditionalProperties, resolving)
+			}
+		case spec.isObject(propertySchema):
+			field.Nested = spec.body(schema.Properties[property], resolving)
+		}
+
+		requestBody.Fields = append(requestBody.Fields, field)
+	}
+
+	return requestBody
+}
+
+// fieldType maps a schema onto the Go type name the generators expect
+func (spec *openAPISpec) fieldType(schema *OpenAPISchema) string {
+	schema, name := spec.resolve(schema)
+	if schema == nil {
+		return "unknown"
+	}
+
+	switch schema.Type {
+	case "string":
+		return "string"
+	case "integer":
+		return "int"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		return "array"
+	}
+	if len(schema.Properties) == 0 && schema.AdditionalProperties != nil {
+		return "map"
+	}
+	if name != "" {
+		return name
+	}
+	return "object"
+}
+
+// isObject reports whether a schema describes an object with declared properties
+func (spec *openAPISpec) isObject(schema *OpenAPISchema) bool {
+	schema, _ = spec.resolve(schema)
+	return schema != nil && len(schema.Properties) > 0
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestGenerateOpenAPIDocument(t *testing.T) {
+	routes := []*Route{
+		{
+			Name:    "Update User",
+			Method:  "PUT",
+			Path:    "/users/:id",
+			Handler: "UpdateUser",
+			Tags:    []string{"users"},
+			RequestBody: &RequestBody{TypeName: "UpdateUserRequest", Fields: []RequestBodyField{
+				{Name: "Name", Type: "string", JSONName: "name", Required: true},
+				{Name: "Age", Type: "int", JSONName: "age"},
+			}},
+			Responses: []ResponseSpec{{StatusCode: 204}},
+		},
+		{Method: GRPCRouteMethod, Path: "/helloworld.Greeter/SayHello", GRPC: &GRPCMethod{Service: "helloworld.Greeter", Method: "SayHello"}},
+	}
+
+	path := filepath.Join(t.TempDir(), "docs", "openapi.json")
+	if err := NewOpenAPIGenerator(path, "https://api.example.com").GenerateDocument(routes); err != nil {
+		t.Fatalf("GenerateDocument() error = %v", err)
+	}
+
+	var doc OpenAPIDocument
+	if err := json.Unmarshal([]byte(readFile(t, path)), &doc); err != nil {
+		t.Fatalf("document is not JSON: %v", err)
+	}
+	if len(doc.Paths) != 1 || doc.Servers[0].URL != "https://api.example.com" {
+		t.Fatalf("paths = %v, servers = %v, want only the HTTP route", doc.Paths, doc.Servers)
+	}
+
+	operation := doc.Paths["/users/{id}"]["put"]
+	if operation == nil || operation.OperationID != "UpdateUser" || operation.Summary != "Update User" {
+		t.Fatalf("operation = %+v", operation)
+	}
+	if len(operation.Parameters) != 1 || operation.Parameters[0].Name != "id" || operation.Parameters[0].In != "path" {
+		t.Errorf("parameters = %+v, want the id path parameter", operation.Parameters)
+	}
+	if _, ok := operation.Responses["204"]; !ok || len(operation.Responses) != 1 {
+		t.Errorf("responses = %v, want only 204", operation.Responses)
+	}
+	schema := operation.RequestBody.Content["application/json"].Schema
+	if schema.Properties["name"].Type != "string" || schema.Properties["age"].Type != "integer" || !slices.Equal(schema.Required, []string{"name"}) {
+		t.Errorf("request body schema = %+v", schema)
+	}
+}
+
+func TestImportOpenAPI(t *testing.T) {
+	jsonSpec := `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/users/{id}": {
+      "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+      "patch": {
+        "operationId": "UpdateUser",
+        "summary": "Update User",
+        "tags": ["users"],
+        "parameters": [{"name": "notify", "in": "query", "schema": {"type": "boolean"}}],
+        "requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/User"}}}},
+        "responses": {"200": {"description": "OK"}, "default": {"description": "Error"}},
+        "security": [{"bearerAuth": []}]
+      },
+      "get": {"summary": "Get User", "responses": {}}
+    }
+  },
+  "components": {
+    "schemas": {
+      "User": {
+        "type": "object",
+        "required": ["name"],
+        "properties": {
+          "name": {"type": "string"},
+          "manager": {"$ref": "#/components/schemas/User"},
+          "roles": {"type": "array", "items": {"type": "string"}}
+        }
+      }
+    },
+    "securitySchemes": {"bearerAuth": {"type": "http", "scheme": "bearer"}}
+  }
+}`
+	// The same spec as YAML, in the block and flow styles specs are written in
+	yamlSpec := `openapi: 3.0.3
+paths:
+  /users/{id}:
+    parameters:
+    - name: id
+      in: path
+      required: true
+      schema: {type: string}
+    patch:
+      operationId: UpdateUser
+      summary: Update User # shown as the request name
+      tags: [users]
+      parameters:
+        - name: notify
+          in: query
+          schema:
+            type: boolean
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/User'
+      responses:
+        200:
+          description: OK
+        default:
+          description: Error
+      security:
+        - bearerAuth: []
+    get:
+      summary: Get User
+      responses: {}
+components:
+  schemas:
+    User:
+      type: object
+      required: [name]
+      properties:
+        name:
+          type: string
+        manager:
+          $ref: "#/components/schemas/User"
+        roles:
+          type: array
+          items: {type: string}
+  securitySchemes:
+    bearerAuth:
+      type: http
+      scheme: bearer
+`
+
+	for file, spec := range map[string]string{"openapi.json": jsonSpec, "openapi.yaml": yamlSpec} {
+		path := filepath.Join(t.TempDir(), file)
+		if err := os.WriteFile(path, []byte(spec), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		routes, err := ImportOpenAPI(path)
+		if err != nil {
+			t.Fatalf("%s: ImportOpenAPI() error = %v", file, err)
+		}
+		if len(routes) != 2 || routes[0].Method != "GET" || routes[1].Method != "PATCH" {
+			t.Fatalf("%s: expected GET then PATCH, got %+v", file, routes)
+		}
+
+		route := routes[1]
+		if route.Name != "Update User" || route.Auth != "bearer" || len(route.PathParams) != 1 {
+			t.Errorf("%s: unexpected route %+v", file, route)
+		}
+		if len(route.QueryParams) != 1 || route.QueryParams[0].Type != "bool" {
+			t.Errorf("%s: QueryParams = %+v", file, route.QueryParams)
+		}
+		if len(route.Responses) != 1 || route.Responses[0].StatusCode != 200 {
+			t.Errorf("%s: Responses = %+v", file, route.Responses)
+		}
+
+		body := route.RequestBody
+		if body == nil || body.TypeName != "User" || len(body.Fields) != 3 {
+			t.Fatalf("%s: RequestBody = %+v", file, body)
+		}
+		// Fields are sorted, and the self reference is left unresolved
+		if manager := body.Fields[0]; manager.JSONName != "manager" || manager.Nested != nil {
+			t.Errorf("%s: manager field = %+v", file, manager)
+		}
+		if name := body.Fields[1]; !name.Required || name.Type != "string" {
+			t.Errorf("%s: name field = %+v", file, name)
+		}
+		if roles := body.Fields[2]; roles.Type != "array" || roles.ElemType != "string" {
+			t.Errorf("%s: roles field = %+v", file, roles)
+		}
+	}
+}
+
+func TestImportOpenAPIBooleanAdditionalProperties(t *testing.T) {
+	spec := `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/labels": {
+      "put": {
+        "requestBody": {"content": {"application/json": {"schema": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "labels": {"type": "object", "additionalProperties": true},
+            "counts": {"type": "object", "additionalProperties": {"type": "integer"}},
+            "owner": {"type": "object", "additionalProperties": false, "properties": {"id": {"type": "string"}}}
+          }
+        }}}}
+      }
+    }
+  }
+}`
+	path := filepath.Join(t.TempDir(), "openapi.json")
+	if err := os.WriteFile(path, []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := ImportOpenAPI(path)
+	if err != nil {
+		t.Fatalf("ImportOpenAPI() error = %v", err)
+	}
+
+	fields := map[string]RequestBodyField{}
+	for _, field := range routes[0].RequestBody.Fields {
+		fields[field.JSONName] = field
+	}
+	if labels := fields["labels"]; labels.Type != "map" {
+		t.Errorf("labels = %+v, want a map of any value", labels)
+	}
+	if counts := fields["counts"]; counts.Type != "map" || counts.MapValueType != "int" {
+		t.Errorf("counts = %+v, want a map of int", counts)
+	}
+	if owner := fields["owner"]; owner.Nested == nil || len(owner.Nested.Fields) != 1 {
+		t.Errorf("owner = %+v, want an object closed to extra keys", owner)
+	}
+}
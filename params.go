@@ -0,0 +1,111 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+type PathParam struct {
+	Name string
+	Type string // Go type learned from router constraints or uri-bound fields, empty if unknown
+}
+
+// integerConstraintPattern matches router constraints that only allow digits, e.g. [0-9]+ or \d{1,6}
+var integerConstraintPattern = regexp.MustCompile(`^(\[0-9\]|\\d)(\+|\*|\{\d+(,\d*)?\})?$`)
+
+// uuidConstraintPattern matches the usual UUID regular expressions
+var uuidConstraintPattern = regexp.MustCompile(`(?i)\[0-9a-f(-)?\]\{8\}`)
+
+// splitPathParam parses a path segment into a parameter name and its router constraint.
+// It understands :id, :id<int> (fiber), {id} and {id:[0-9]+} (gorilla, chi).
+func splitPathParam(segment string) (string, string, bool) {
+	switch {
+	case strings.HasPrefix(segment, ":"):
+		name, constraint, _ := strings.Cut(strings.TrimPrefix(segment, ":"), "<")
+		return name, strings.TrimSuffix(constraint, ">"), name != ""
+	case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+		name, constraint, _ := strings.Cut(segment[1:len(segment)-1], ":")
+		return name, constraint, name != ""
+	}
+	return "", "", false
+}
+
+// brunoPath rewrites every path parameter into Bruno's :name form, dropping router constraints
+func brunoPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, _, ok := splitPathParam(segment); ok {
+			segments[i] = ":" + name
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// pathParamNames returns the names of the :name and {name} parameters in a path, in order
+func pathParamNames(path string) []string {
+	names := []string{}
+	for _, segment := range strings.Split(path, "/") {
+		if name, _, ok := splitPathParam(segment); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// pathParams lists a route's path parameters. Their type comes from uri-tagged fields of
+// the bound structs, falling back to the router constraint.
+func pathParams(route *Route) []PathParam {
+	boundTypes := make(map[string]string)
+	for _, body := range []*RequestBody{route.RequestBody, route.URIBody} {
+		if body == nil {
+			continue
+		}
+		for _, field := range body.Fields {
+			if name, _, _ := strings.Cut(field.Tags["uri"], ","); name != "" {
+				boundTypes[name] = field.Type
+			}
+		}
+	}
+
+	params := []PathParam{}
+	for _, segment := range strings.Split(route.Path, "/") {
+		name, constraint, ok := splitPathParam(segment)
+		if !ok {
+			continue
+		}
+
+		paramType := boundTypes[name]
+		if paramType == "" {
+			paramType = constraintType(constraint)
+		}
+		params = append(params, PathParam{Name: name, Type: paramType})
+	}
+	return params
+}
+
+// constraintType maps a router constraint onto the Go type it implies
+func constraintType(constraint string) string {
+	switch lower := strings.ToLower(constraint); {
+	case lower == "":
+		return ""
+	case lower == "int" || integerConstraintPattern.MatchString(constraint):
+		return "int"
+	case lower == "uuid" || lower == "guid" || uuidConstraintPattern.MatchString(constraint):
+		return "uuid.UUID"
+	}
+	return "string"
+}
+
+// pathParamExample returns the example value shown for a path parameter of the given type
+func pathParamExample(paramType string) string {
+	switch paramType {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		return "1"
+	}
+
+	// Well-known types render their usual value, anything else gets a placeholder string
+	if value := formatExampleValue(defaultValueForType(paramType)); value != "" {
+		return value
+	}
+	return "example"
+}
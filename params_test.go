@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPathParams(t *testing.T) {
+	uriBody := &RequestBody{
+		TypeName: "UserURI",
+		Fields: []RequestBodyField{
+			{Name: "ID", Type: "int", Tags: map[string]string{"uri": "id"}},
+			{Name: "Slug", Type: "string", Tags: map[string]string{"uri": "slug"}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		route    *Route
+		expected []PathParam
+		examples []string
+	}{
+		{
+			name:     "bound int and string params",
+			route:    &Route{Path: "/users/:id/posts/:slug", URIBody: uriBody},
+			expected: []PathParam{{Name: "id", Type: "int"}, {Name: "slug", Type: "string"}},
+			examples: []string{"1", "example"},
+		},
+		{
+			name:     "router constraints",
+			route:    &Route{Path: "/orders/{id:[0-9]+}/items/{item}"},
+			expected: []PathParam{{Name: "id", Type: "int"}, {Name: "item", Type: ""}},
+			examples: []string{"1", "example"},
+		},
+		{
+			name:     "constraint ending in a brace",
+			route:    &Route{Path: `/orders/{id:\d{1,6}}`},
+			expected: []PathParam{{Name: "id", Type: "int"}},
+			examples: []string{"1"},
+		},
+		{
+			name:     "fiber constraints",
+			route:    &Route{Path: "/files/:id<guid>"},
+			expected: []PathParam{{Name: "id", Type: "uuid.UUID"}},
+			examples: []string{"00000000-0000-0000-0000-000000000000"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pathParams(tt.route)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("pathParams() = %+v, want %+v", got, tt.expected)
+			}
+			for i, param := range got {
+				if example := pathParamExample(param.Type); example != tt.examples[i] {
+					t.Errorf("pathParamExample(%q) = %q, want %q", param.Type, example, tt.examples[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBrunoPathDropsConstraints(t *testing.T) {
+	if got := brunoPath("/orders/{id:[0-9]+}/items/:item<int>"); got != "/orders/:id/items/:item" {
+		t.Errorf("brunoPath() = %q", got)
+	}
+	if got := brunoPath(`/orders/{id:\d{1,6}}`); got != "/orders/:id" {
+		t.Errorf("brunoPath() = %q", got)
+	}
+}
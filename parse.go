@@ -9,40 +9,182 @@ import (
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
 )
 
 var (
 	namePattern        = regexp.MustCompile(`@name\s+(.+)`)
 	routePattern       = regexp.MustCompile(`@route\s+([A-Z]+)\s+(.+)`)
 	descriptionPattern = regexp.MustCompile(`@description\s+(.+)`)
-	bodyPattern        = regexp.MustCompile(`@body\s+(\w+)`)
+	bodyPattern        = regexp.MustCompile(`@body\s+([\w.]+)`)
+	authPattern        = regexp.MustCompile(`@auth[ \t]+(\w+)((?:[ \t]+\S+)*)`)
+	whenPattern        = regexp.MustCompile(`@when\s+(\w+)\s*=\s*(.+)`)
+	grpcPattern        = regexp.MustCompile(`@grpc\s+([\w.]+)\.(\w+)`)
+	settingsPattern    = regexp.MustCompile(`@settings\s+(.+)`)
+	redirectsPattern   = regexp.MustCompile(`@follow-redirects\s+(\w+)`)
+	basePathPattern    = regexp.MustCompile(`@basepath\s+(\S+)`)
+	bulkPattern        = regexp.MustCompile(`@bulk\b(?:\s+itemCount=(\d+))?`)
+	bodyTypePattern    = regexp.MustCompile(`@bodytype\s+([\w-]+)`)
+	tagPattern         = regexp.MustCompile(`@tag\s+(.+)`)
+	scopePattern       = regexp.MustCompile(`@scope\s+(.+)`)
+	rateLimitPattern   = regexp.MustCompile(`@ratelimit\s+(.+)`)
+	headerPattern      = regexp.MustCompile(`@header\s+([\w-]+)(?:\s+"([^"]*)"|\s+(\S.*))?`)
+	queryPattern       = regexp.MustCompile(`@query\s+(\w[\w.\[\]-]*)(?:\s+([\w.\[\]]+))?(\s+required)?(?:\s+"([^"]*)")?`)
+	varPattern         = regexp.MustCompile(`@var\s+(?:secret\s+(\w+)|(\w+)\s*=\s*(.*))`)
+	responsePattern    = regexp.MustCompile(`@response\s+(\d{3})(?:\s+([\w.]+))?`)
+	deprecatedPattern  = regexp.MustCompile(`@deprecated\b[ \t]*(.*)`)
 )
 
+// annotationNames are the recognized annotation tags, a @description runs until the next one
+var annotationNames = map[string]bool{
+	"name": true, "route": true, "description": true, "body": true, "bodytype": true, "auth": true,
+	"when": true, "grpc": true, "settings": true, "follow-redirects": true, "basepath": true,
+	"bulk": true, "tag": true, "scope": true, "header": true, "query": true, "var": true,
+	"response": true, "deprecated": true, "graphql-query": true, "ratelimit": true,
+}
+
+// annotationTagPattern finds @word tags. The @ may not follow a letter or digit, so e-mail
+// addresses such as jane@example.com aren't read as tags.
+var annotationTagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}])(@([a-z][a-z-]*))`)
+
+// annotationTag is a recognized tag found in a comment line
+type annotationTag struct {
+	name       string
+	start, end int // Byte offsets of the tag, e.g. "@body", within the line
+}
+
+// annotationTags returns the recognized tags of a line in order
+func annotationTags(line string) []annotationTag {
+	tags := []annotationTag{}
+	for _, match := range annotationTagPattern.FindAllStringSubmatchIndex(line, -1) {
+		name := line[match[4]:match[5]]
+		if annotationNames[name] {
+			tags = append(tags, annotationTag{name: name, start: match[2], end: match[3]})
+		}
+	}
+	return tags
+}
+
+// commentLines splits a comment group into its lines. Only the leading comment markers are
+// removed, "// " or the " * " of block comments, so the rest of the line (markdown
+// indentation, URLs) is kept as written.
+func commentLines(comments *ast.CommentGroup) []string {
+	lines := []string{}
+	for _, comment := range comments.List {
+		text := comment.Text
+		if !strings.HasPrefix(text, "/*") {
+			lines = append(lines, strings.TrimPrefix(strings.TrimPrefix(text, "//"), " "))
+			continue
+		}
+
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+		for _, line := range strings.Split(text, "\n") {
+			if trimmed := strings.TrimLeft(line, " \t"); strings.HasPrefix(trimmed, "*") {
+				line = strings.TrimPrefix(trimmed[1:], " ")
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// authOptions are the option=value settings @auth accepts
+var authOptions = map[string]bool{
+	"header":       true, // Bearer token header
+	"prefix":       true, // Bearer token prefix
+	"grant":        true, // OAuth2 grant type
+	"token_url":    true, // OAuth2 access token URL
+	"auth_url":     true, // OAuth2 authorization URL
+	"callback_url": true, // OAuth2 redirect URL
+}
+
+// MaxSourceSnippetLines bounds the handler source shown in the docs with -docs-source.
+const MaxSourceSnippetLines = 40
+
 // Parser extracts information about API routes
 type Parser struct {
-	routes []*Route
+	routes             []*Route
+	constants          map[string]ast.Expr           // Package-level constants, used to resolve @basepath references
+	middleware         []handlerRegistration         // Middleware names registered in front of each registration of a handler
+	routeGroups        []handlerRegistration         // Router group prefixes, outermost first, of each registration of a handler
+	callGroups         map[*ast.CallExpr][]ast.Expr  // Router group prefixes of each call made on a group, for framework registrations
+	routeTables        []tableRoute                  // Entries of []Route{...} style registration tables and framework registrations
+	handlerDecls       map[string]*handlerDecl       // Every function seen, for route table entries without annotations
+	sources            map[string][]byte             // File contents read for DocsSource, by path
+	structIndex        map[string][]*structLocation  // Struct declarations by bare name, built once per ParseDirectory
+	files              map[string]*ast.File          // Parsed files by path, shared by handler and struct lookups during ParseDirectory
+	variables          map[string]CollectionVariable // Collection variables declared with @var
+	diagnostics        []Diagnostic                  // Malformed annotations found by the last ParseDirectory
+	excludes           []string                      // Patterns of the paths skipped by the current ParseDirectory
+	variableOrder      []string                      // Variable names in the order they were declared
+	Infer              bool                          // Infer route details from handler bodies when annotations are missing
+	Framework          string                        // Router framework whose route registrations are discovered, e.g. gin
+	Strict             bool                          // Treat suspicious annotations as errors instead of warnings
+	IncludeTests       bool                          // Scan _test.go files for annotated handlers too
+	Exclude            []string                      // Glob patterns of files and directories to skip, on top of DefaultExcludes
+	Gitignore          bool                          // Also skip the paths the scanned directory's .gitignore lists
+	DocsSource         bool                          // Capture each handler's source so it can be shown in the docs
+	AuthMiddleware     map[string]string             // Maps middleware names onto the auth mode they enforce
+	DefaultDescription *template.Template            // Description for routes without a @description, executed with the Route
 }
 
 // NewParser creates a new Parser
 func NewParser() *Parser {
+	authMiddleware := make(map[string]string)
+	for name, mode := range defaultAuthMiddleware {
+		authMiddleware[name] = mode
+	}
+
 	return &Parser{
-		routes: []*Route{},
+		routes:         []*Route{},
+		callGroups:     make(map[*ast.CallExpr][]ast.Expr),
+		variables:      make(map[string]CollectionVariable),
+		handlerDecls:   make(map[string]*handlerDecl),
+		sources:        make(map[string][]byte),
+		constants:      make(map[string]ast.Expr),
+		AuthMiddleware: authMiddleware,
 	}
 }
 
 // ParseDirectory parses all Go files in a directory
 func (p *Parser) ParseDirectory(dirPath string) ([]*Route, error) {
+	// Every file is parsed once per run, the ASTs are dropped when the run is over
+	p.files = make(map[string]*ast.File)
+	p.structIndex = nil
+	p.diagnostics = nil
+	p.middleware, p.routeGroups = nil, nil
+	defer func() { p.files = nil }()
+
+	excludes, err := p.scanExcludes(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	p.excludes = excludes
+
 	// First, find all handler functions and their annotations to create route stubs
-	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+	paths := []string{}
+	err = filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
+		// Dependencies and excluded paths aren't first-party handler code
+		if skip, err := skipExcluded(dirPath, path, info, p.excludes); skip {
+			return err
+		}
+
+		// Handlers in test files are fixtures, not part of the API
+		if strings.HasSuffix(path, "_test.go") && !p.IncludeTests {
+			return nil
+		}
+
 		if !info.IsDir() && strings.HasSuffix(path, ".go") {
-			if err := p.FindHandlers(path); err != nil {
-				return err
-			}
+			paths = append(paths, path)
 		}
 		return nil
 	})
@@ -51,6 +193,52 @@ func (p *Parser) ParseDirectory(dirPath string) ([]*Route, error) {
 		return nil, err
 	}
 
+	// Parsing is spread across CPUs, the handlers are then collected file by file in walk
+	// order so routes always come out in the same order
+	files, err := parseFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		p.files[file.path] = file.node
+		p.collectHandlers(file.fset, file.node, file.path)
+	}
+	if p.Strict && len(p.diagnostics) > 0 {
+		return nil, fmt.Errorf("%d malformed annotations, first at %s", len(p.diagnostics), p.diagnostics[0])
+	}
+
+	// Now that every constant is known, add table-registered routes and prefix routes
+	// with their router groups and @basepath
+	if p.Infer || p.Framework != "" {
+		p.applyRouteGroups()
+		p.applyRouteTables()
+	}
+	p.applyBasePaths()
+
+	// Path parameters are read once prefixes have settled the final path
+	for _, route := range p.routes {
+		if route.GRPC == nil {
+			route.PathParams = pathParamNames(route.Path)
+		}
+	}
+
+	// Apply auth requirements from middleware found in the route registrations
+	if p.Infer {
+		p.applyMiddlewareAuth()
+	}
+
+	// Scopes only mean something next to an auth mode, so check them once auth is settled
+	p.checkScopes()
+
+	// The same handler can be found twice for one route, e.g. registered on two sub-routers
+	// that share a prefix, which would otherwise be reported as a collision
+	p.collapseDuplicateRoutes()
+
+	// Colliding routes would overwrite each other's output, so catch them before generation
+	if err := p.checkDuplicateRoutes(); err != nil {
+		return nil, err
+	}
+
 	// Then, go through all files again to find struct definitions referenced by the routes
 	for i, route := range p.routes {
 		// Skip routes that don't need a request body
@@ -58,20 +246,140 @@ func (p *Parser) ParseDirectory(dirPath string) ([]*Route, error) {
 			continue
 		}
 
-		// Look for the struct in all files
-		requestBody, err := p.FindStruct(dirPath, route.BodyType)
+		// Look for the struct, preferring the handler's own package
+		requestBody, structDir, err := p.findStructFrom(dirPath, filepath.Dir(route.SourceFile), route.BodyType)
 		if err != nil {
 			return nil, err
 		}
 
-		if requestBody != nil {
-			p.routes[i].RequestBody = requestBody
+		// A missing struct would leave the request without a body
+		if requestBody == nil {
+			if p.Strict {
+				return nil, fmt.Errorf("%s:%d: could not find body struct %s for handler %s", route.SourceFile, route.SourceLine, route.BodyType, route.Handler)
+			}
+			getLogger().Warn(fmt.Sprintf("Could not find body struct %s for handler %s, generating it without a body", route.BodyType, route.Handler),
+				"category", "missing-body", "handler", route.Handler, "file", route.SourceFile, "line", route.SourceLine)
+			continue
+		}
+
+		if err := p.resolveNestedStructs(dirPath, structDir, requestBody, map[string]bool{route.BodyType: true}); err != nil {
+			return nil, err
+		}
+		p.routes[i].RequestBody = requestBody
+	}
+
+	// Resolve the structs path parameters are bound to
+	for _, route := range p.routes {
+		if route.URIType == "" {
+			continue
+		}
+
+		uriBody, _, err := p.findStructFrom(dirPath, filepath.Dir(route.SourceFile), route.URIType)
+		if err != nil {
+			return nil, err
+		}
+		route.URIBody = uriBody
+	}
+
+	// Resolve the struct definitions for any documented responses
+	for _, route := range p.routes {
+		for i, response := range route.Responses {
+			if response.TypeName == "" {
+				continue
+			}
+
+			responseBody, structDir, err := p.findStructFrom(dirPath, filepath.Dir(route.SourceFile), response.TypeName)
+			if err != nil {
+				return nil, err
+			}
+
+			// Inferred types are often maps or gin.H, only documented ones are expected to exist
+			if responseBody == nil {
+				if response.Inferred {
+					continue
+				}
+				getLogger().Warn(fmt.Sprintf("Could not find response struct %s for handler %s", response.TypeName, route.Handler),
+					"handler", route.Handler, "file", route.SourceFile, "line", route.SourceLine)
+				continue
+			}
+
+			if err := p.resolveNestedStructs(dirPath, structDir, responseBody, map[string]bool{response.TypeName: true}); err != nil {
+				return nil, err
+			}
+			route.Responses[i].Body = responseBody
 		}
 	}
 
+	// Give routes without a @description the default one, now that the route is complete
+	if err := p.applyDefaultDescription(); err != nil {
+		return nil, err
+	}
+
 	return p.routes, nil
 }
 
+// applyDefaultDescription renders the DefaultDescription template for routes lacking a description
+func (p *Parser) applyDefaultDescription() error {
+	if p.DefaultDescription == nil {
+		return nil
+	}
+
+	for _, route := range p.routes {
+		if route.Description != "" {
+			continue
+		}
+
+		var description strings.Builder
+		if err := p.DefaultDescription.Execute(&description, route); err != nil {
+			return fmt.Errorf("default description for handler %s: %w", route.Handler, err)
+		}
+		route.Description = strings.TrimSpace(description.String())
+	}
+
+	return nil
+}
+
+// parsedFile is a Go file parsed ahead of collecting its handlers
+type parsedFile struct {
+	path string
+	fset *token.FileSet
+	node *ast.File
+	err  error
+}
+
+// parseFiles parses files across a pool of runtime.NumCPU() workers, returning them in
+// the order they were given
+func parseFiles(paths []string) ([]parsedFile, error) {
+	files := make([]parsedFile, len(paths))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(runtime.NumCPU(), len(paths)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fset := token.NewFileSet()
+				node, err := parser.ParseFile(fset, paths[i], nil, parser.ParseComments)
+				files[i] = parsedFile{path: paths[i], fset: fset, node: node, err: err}
+			}
+		}()
+	}
+
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, file := range files {
+		if file.err != nil {
+			return nil, file.err
+		}
+	}
+	return files, nil
+}
+
 // FindHandlers parses a file to find handler functions and their annotations
 func (p *Parser) FindHandlers(filePath string) error {
 	fset := token.NewFileSet()
@@ -80,12 +388,44 @@ func (p *Parser) FindHandlers(filePath string) error {
 		return err
 	}
 
+	p.collectHandlers(fset, node, filePath)
+	return nil
+}
+
+// collectHandlers records the handler functions and annotations of a parsed file
+func (p *Parser) collectHandlers(fset *token.FileSet, node *ast.File, filePath string) {
 	// TODO: right now this lets us annotate any function, not just handler funcs.
 
+	if p.Infer {
+		p.collectMiddleware(fset, node, filePath)
+	}
+	if p.Infer || p.Framework != "" {
+		p.collectRouteGroups(node, filePath)
+	}
+	p.collectRegistrations(fset, node)
+
+	p.collectConstants(node)
+
+	// A @basepath in the file's package comment applies to every handler in the file
+	fileBasePath := ""
+	if node.Doc != nil {
+		fileBasePath = p.extractAnnotations(node.Doc)["basepath"]
+		p.collectVariables(node.Doc, filePath)
+	}
+
 	// Extract handler annotations
 	ast.Inspect(node, func(n ast.Node) bool {
 		// Look for function declarations (handlers)
 		if funcDecl, ok := n.(*ast.FuncDecl); ok {
+			// Route tables and registrations can point at handlers without annotations, so keep them around
+			if p.Infer || p.Framework != "" {
+				decl := &handlerDecl{fset: fset, funcDecl: funcDecl, filePath: filePath, basePath: fileBasePath}
+				p.handlerDecls[funcDecl.Name.Name] = decl
+				if receiver := receiverTypeName(funcDecl); receiver != "" {
+					p.handlerDecls[receiver+"."+funcDecl.Name.Name] = decl
+				}
+			}
+
 			// Skip if no comments
 			if funcDecl.Doc == nil {
 				return true
@@ -93,73 +433,682 @@ func (p *Parser) FindHandlers(filePath string) error {
 
 			handlerName := funcDecl.Name.Name
 
-			// Extract annotations from comments
-			annotations := p.extractAnnotations(funcDecl.Doc)
+			// A @route the pattern doesn't match would otherwise make the route vanish silently
+			p.checkRouteAnnotations(fset, funcDecl)
+
+			// Extract annotations from comments
+			annotations := p.extractAnnotations(funcDecl.Doc)
+
+			// A handler can be registered under several @route lines
+			specs := extractRouteSpecs(funcDecl.Doc)
+
+			// gRPC methods are addressed by service and method rather than an HTTP route
+			var grpcMethod *GRPCMethod
+			if service, ok := annotations["grpc_service"]; ok {
+				grpcMethod = &GRPCMethod{
+					Service: service,
+					Method:  annotations["grpc_method"],
+				}
+				specs = []routeSpec{{method: GRPCRouteMethod, path: grpcMethod.FullMethod()}}
+			}
+
+			// Only process functions with a @route (or @grpc) annotation
+			for _, spec := range specs {
+				route := p.newRoute(fset, funcDecl, annotations, filePath, fileBasePath, spec.method, spec.path)
+				route.GRPC = grpcMethod
+
+				// Routes sharing a handler share its name too, so their files are told apart
+				if len(specs) > 1 {
+					route.FileName = routeFileName(route, spec)
+				}
+
+				p.routes = append(p.routes, route)
+				getLogger().Debug(fmt.Sprintf("Found route: %s %s in handler %s", spec.method, spec.path, handlerName))
+			}
+		}
+		return true
+	})
+
+	if p.Infer {
+		p.collectRouteTables(fset, node)
+	}
+}
+
+// routeSpec is the method and path of one @route annotation
+type routeSpec struct {
+	method string
+	path   string
+}
+
+// extractRouteSpecs returns every well-formed @route annotation in a doc comment, in order
+func extractRouteSpecs(comments *ast.CommentGroup) []routeSpec {
+	specs := []routeSpec{}
+	for _, comment := range comments.List {
+		if matches := routePattern.FindStringSubmatch(comment.Text); len(matches) > 2 && httpMethods[matches[1]] {
+			specs = append(specs, routeSpec{method: matches[1], path: strings.TrimSpace(matches[2])})
+		}
+	}
+	return specs
+}
+
+// httpMethods are the methods a @route may use
+var httpMethods = map[string]bool{
+	"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true,
+	"HEAD": true, "OPTIONS": true, "CONNECT": true, "TRACE": true,
+}
+
+// checkRouteAnnotations records a diagnostic, logged as a warning, for every @route line of
+// a handler that is malformed: a lower-case or unknown method, or a missing path
+func (p *Parser) checkRouteAnnotations(fset *token.FileSet, funcDecl *ast.FuncDecl) {
+	for _, comment := range funcDecl.Doc.List {
+		for i, line := range strings.Split(comment.Text, "\n") {
+			tags := annotationTags(line)
+			if len(tags) == 0 || tags[0].name != "route" {
+				continue
+			}
+
+			fields := strings.Fields(line[tags[0].end:])
+			problem := ""
+			switch {
+			case len(fields) == 0:
+				problem = "@route is missing its method and path"
+			case len(fields) == 1:
+				problem = fmt.Sprintf("@route %s is missing its path", fields[0])
+			case !httpMethods[strings.ToUpper(fields[0])]:
+				problem = fmt.Sprintf("@route has unknown method %s", fields[0])
+			case fields[0] != strings.ToUpper(fields[0]):
+				problem = fmt.Sprintf("@route method %s must be upper case, e.g. %s", fields[0], strings.ToUpper(fields[0]))
+			default:
+				continue
+			}
+
+			position := fset.Position(comment.Pos())
+			diagnostic := Diagnostic{
+				File:    position.Filename,
+				Line:    position.Line + i,
+				Handler: funcDecl.Name.Name,
+				Message: fmt.Sprintf("%s on handler %s", problem, funcDecl.Name.Name),
+			}
+			p.diagnostics = append(p.diagnostics, diagnostic)
+			getLogger().Warn(diagnostic.String(), "category", "annotation",
+				"handler", diagnostic.Handler, "file", diagnostic.File, "line", diagnostic.Line)
+		}
+	}
+}
+
+// Diagnostics returns the malformed annotations found by the last ParseDirectory
+func (p *Parser) Diagnostics() []Diagnostic {
+	return p.diagnostics
+}
+
+// routeFileName builds a file name for one of several routes of a handler from the
+// shared name, the method and the annotated path, e.g. get-user_get_users_id
+func routeFileName(route *Route, spec routeSpec) string {
+	name := route.Name
+	if name == "" {
+		name = route.Handler
+	}
+	return slugify(name) + "_" + methodPathFileName(spec.method, spec.path)
+}
+
+// newRoute builds the route for a handler from its doc annotations. Callers that already
+// extracted the annotations pass them in, so their warnings are only logged once.
+func (p *Parser) newRoute(fset *token.FileSet, funcDecl *ast.FuncDecl, annotations map[string]string, filePath, fileBasePath, method, path string) *Route {
+	doc := funcDecl.Doc
+	if doc == nil {
+		doc = &ast.CommentGroup{}
+	}
+	if annotations == nil {
+		annotations = p.extractAnnotations(doc)
+	}
+	handlerName := funcDecl.Name.Name
+
+	route := &Route{
+		Name:         annotations["name"],
+		Method:       method,
+		Path:         path,
+		Handler:      handlerName,
+		Receiver:     receiverTypeName(funcDecl),
+		SourceFile:   filePath,
+		SourceLine:   fset.Position(funcDecl.Pos()).Line,
+		Description:  annotations["description"],
+		BodyType:     annotations["body"], // Store the body type name to be resolved later
+		ContentType:  annotations["bodytype"],
+		GraphQLQuery: annotations["graphql_query"],
+		Tags:         p.extractTags(doc),
+		Labels:       p.extractLabels(doc),
+		Scopes:       p.extractScopes(doc),
+		RateLimit:    annotations["ratelimit"],
+		QueryParams:  p.extractQueryParams(doc),
+		Headers:      p.extractHeaders(doc),
+		Auth:         strings.ToLower(annotations["auth"]),
+		AuthOptions: AuthOptions{
+			In:          annotations["auth_in"],
+			Key:         annotations["auth_key"],
+			Header:      annotations["auth_header"],
+			Prefix:      annotations["auth_prefix"],
+			Grant:       annotations["auth_grant"],
+			TokenURL:    annotations["auth_token_url"],
+			AuthURL:     annotations["auth_auth_url"],
+			CallbackURL: annotations["auth_callback_url"],
+		},
+		Responses: p.extractResponses(doc),
+		Settings:  p.extractSettings(doc, handlerName),
+		BasePath:  fileBasePath,
+		Bulk:      p.extractBulk(doc),
+	}
+	if basePath, ok := annotations["basepath"]; ok {
+		route.BasePath = basePath
+	}
+	if deprecation, ok := annotations["deprecated"]; ok {
+		route.Deprecated, route.Deprecation = true, deprecation
+	}
+	if route.Auth != "" && !supportedAuthModes[route.Auth] {
+		getLogger().Warn(fmt.Sprintf("Unsupported @auth mode %q on handler %s, ignoring it", route.Auth, handlerName))
+		route.Auth = ""
+	}
+
+	// Explicit @response annotations take precedence over anything inferred
+	if p.Infer {
+		route.Responses = mergeResponses(route.Responses, p.inferResponses(fset, funcDecl))
+	}
+
+	if p.Infer {
+		route.URIType = uriBindingType(funcDecl)
+	}
+
+	if p.DocsSource {
+		route.SourceSnippet = p.sourceSnippet(fset, funcDecl)
+	}
+
+	return route
+}
+
+// sourceSnippet returns the source of a handler, doc comment included, cut down to
+// MaxSourceSnippetLines lines
+func (p *Parser) sourceSnippet(fset *token.FileSet, funcDecl *ast.FuncDecl) string {
+	start := fset.Position(funcDecl.Pos())
+	if funcDecl.Doc != nil {
+		start = fset.Position(funcDecl.Doc.Pos())
+	}
+	end := fset.Position(funcDecl.End())
+
+	src, ok := p.sources[start.Filename]
+	if !ok {
+		var err error
+		src, err = os.ReadFile(start.Filename)
+		if err != nil {
+			getLogger().Warn(fmt.Sprintf("Could not read source of handler %s: %v", funcDecl.Name.Name, err))
+			return ""
+		}
+		p.sources[start.Filename] = src
+	}
+	if end.Offset > len(src) || start.Offset > end.Offset {
+		return ""
+	}
+
+	lines := strings.Split(string(src[start.Offset:end.Offset]), "\n")
+	if len(lines) > MaxSourceSnippetLines {
+		lines = append(lines[:MaxSourceSnippetLines], "// ...")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// structLocation is a file declaring a struct, along with its package
+type structLocation struct {
+	file    string
+	dir     string // Directory of the file, which identifies its package
+	pkgName string
+	body    *RequestBody // The parsed struct, filled in on its first lookup
+}
+
+// FindStruct searches for a specific struct definition across all files
+func (p *Parser) FindStruct(dirPath, structName string) (*RequestBody, error) {
+	requestBody, _, err := p.findStructFrom(dirPath, "", structName)
+	return requestBody, err
+}
+
+// findStructFrom resolves a struct referenced from code in fromDir, and the directory of its
+// package. Unqualified names prefer the struct declared in that same package, while pkg.Name
+// follows the import of pkg made by the files in fromDir, falling back to packages named pkg.
+func (p *Parser) findStructFrom(dirPath, fromDir, typeName string) (*RequestBody, string, error) {
+	if err := p.indexStructs(dirPath); err != nil {
+		return nil, "", err
+	}
+
+	pkgName, structName, qualified := strings.Cut(typeName, ".")
+	if !qualified {
+		pkgName, structName = "", typeName
+	}
+
+	candidates := []*structLocation{}
+	if importPath, ok := p.importPathFor(fromDir, pkgName); qualified && ok {
+		// Keep the declarations whose directory best matches the end of the import path
+		best := 0
+		for _, location := range p.structIndex[structName] {
+			match := importPathMatch(location.dir, importPath)
+			switch {
+			case match > best:
+				candidates, best = []*structLocation{location}, match
+			case match == best && match > 0:
+				candidates = append(candidates, location)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		for _, location := range p.structIndex[structName] {
+			if pkgName == "" || location.pkgName == pkgName {
+				candidates = append(candidates, location)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, "", nil
+	}
+
+	location := candidates[0]
+	found := false
+	for _, candidate := range candidates {
+		if candidate.dir == fromDir {
+			location, found = candidate, true
+			break
+		}
+	}
+	if !found && len(candidates) > 1 {
+		getLogger().Warn(fmt.Sprintf("Struct %s is declared in several packages, using the one in %s", typeName, location.dir))
+	}
+
+	if location.body == nil {
+		requestBody, err := p.ParseStructFromFile(location.file, structName)
+		if err != nil || requestBody == nil {
+			return requestBody, location.dir, err
+		}
+		location.body = requestBody
+	}
+
+	// Every lookup gets its own fields, since nested structs are resolved into them
+	requestBody := *location.body
+	requestBody.Fields = append([]RequestBodyField{}, location.body.Fields...)
+	return &requestBody, location.dir, nil
+}
+
+// importPathFor returns the path a file in dir imports under the given package name
+func (p *Parser) importPathFor(dir, pkgName string) (string, bool) {
+	paths := []string{}
+	for path := range p.files {
+		if filepath.Dir(path) == dir {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		for _, spec := range p.files[path].Imports {
+			importPath, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			if importName(spec, importPath) == pkgName {
+				return importPath, true
+			}
+		}
+	}
+	return "", false
+}
+
+// importName returns the name an import is referred to by, be it an explicit alias or the
+// last element of the path
+func importName(spec *ast.ImportSpec, importPath string) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	return importPath[strings.LastIndex(importPath, "/")+1:]
+}
+
+// importPathMatch counts the trailing elements of an import path that a directory ends with,
+// e.g. 2 for internal/models in /src/app/internal/models
+func importPathMatch(dir, importPath string) int {
+	dirParts := strings.Split(filepath.ToSlash(dir), "/")
+	importParts := strings.Split(importPath, "/")
+
+	match := 0
+	for match < len(dirParts) && match < len(importParts) &&
+		dirParts[len(dirParts)-1-match] == importParts[len(importParts)-1-match] {
+		match++
+	}
+	return match
+}
+
+// indexStructs records which files declare each struct, once per run
+func (p *Parser) indexStructs(dirPath string) error {
+	if p.structIndex != nil {
+		return nil
+	}
+	p.structIndex = make(map[string][]*structLocation)
+
+	return filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if skip, err := skipExcluded(dirPath, path, info, p.excludes); skip {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		// Structs in test files are fixtures, like their handlers
+		if strings.HasSuffix(path, "_test.go") && !p.IncludeTests {
+			return nil
+		}
+
+		node, err := p.parseFile(path)
+		if err != nil {
+			return err
+		}
+
+		for _, decl := range node.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				if _, ok := typeSpec.Type.(*ast.StructType); !ok {
+					continue
+				}
+				p.structIndex[typeSpec.Name.Name] = append(p.structIndex[typeSpec.Name.Name], &structLocation{
+					file:    path,
+					dir:     filepath.Dir(path),
+					pkgName: node.Name.Name,
+				})
+			}
+		}
+		return nil
+	})
+}
+
+// parseFile parses a file with its comments, reusing the AST when ParseDirectory has
+// already parsed it
+func (p *Parser) parseFile(path string) (*ast.File, error) {
+	if node, ok := p.files[path]; ok {
+		return node, nil
+	}
+
+	node, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if p.files != nil {
+		p.files[path] = node
+	}
+	return node, nil
+}
+
+// collapseDuplicateRoutes keeps one route per method, path and handler. The route with the
+// most metadata wins and takes the place of the first one found.
+func (p *Parser) collapseDuplicateRoutes() {
+	index := make(map[string]int)
+	routes := []*Route{}
+
+	for _, route := range p.routes {
+		key := strings.ToUpper(route.Method) + " " + route.Path + " " + route.Handler
+		i, seen := index[key]
+		if !seen {
+			index[key] = len(routes)
+			routes = append(routes, route)
+			continue
+		}
+		if routeMetadata(route) > routeMetadata(routes[i]) {
+			routes[i] = route
+		}
+	}
+
+	if collapsed := len(p.routes) - len(routes); collapsed > 0 {
+		getLogger().Info(fmt.Sprintf("Collapsed %d duplicate routes", collapsed))
+	}
+	p.routes = routes
+}
+
+// routeMetadata scores how much a route documents, to pick between duplicates
+func routeMetadata(route *Route) int {
+	score := 0
+	for _, documented := range []bool{route.Description != "", route.BodyType != "", route.Name != "", len(route.Responses) > 0, route.Auth != ""} {
+		if documented {
+			score++
+		}
+	}
+	return score
+}
+
+// checkDuplicateRoutes warns about (or in strict mode rejects) different handlers
+// annotated with the same method and path
+func (p *Parser) checkDuplicateRoutes() error {
+	seen := make(map[string]*Route)
+
+	for _, route := range p.routes {
+		key := strings.ToUpper(route.Method) + " " + route.Path
+		first, ok := seen[key]
+		if !ok {
+			seen[key] = route
+			continue
+		}
+
+		details := fmt.Sprintf("%s: handler %s (%s:%d) conflicts with %s (%s:%d)",
+			key, route.Handler, route.SourceFile, route.SourceLine, first.Handler, first.SourceFile, first.SourceLine)
+		if p.Strict {
+			return fmt.Errorf("duplicate route %s", details)
+		}
+		getLogger().Warn("Duplicate route "+details, "category", "duplicate-route",
+			"handler", route.Handler, "file", route.SourceFile, "line", route.SourceLine)
+	}
+
+	return nil
+}
+
+// collectConstants records the package-level constants declared in a file
+func (p *Parser) collectConstants(node *ast.File) {
+	for _, decl := range node.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.CONST {
+			continue
+		}
+
+		for _, spec := range genDecl.Specs {
+			valueSpec, ok := spec.(*ast.ValueSpec)
+			if !ok {
+				continue
+			}
+			for i, name := range valueSpec.Names {
+				if i < len(valueSpec.Values) {
+					p.constants[name.Name] = valueSpec.Values[i]
+				}
+			}
+		}
+	}
+}
+
+// resolveStringConstant evaluates a string constant expression built from literals,
+// other constants and concatenation
+func (p *Parser) resolveStringConstant(expr ast.Expr, depth int) (string, bool) {
+	// Guard against constants that refer back to themselves
+	if depth > 16 {
+		return "", false
+	}
+
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.STRING {
+			return "", false
+		}
+		value, err := strconv.Unquote(e.Value)
+		return value, err == nil
+	case *ast.Ident:
+		value, ok := p.constants[e.Name]
+		if !ok {
+			return "", false
+		}
+		return p.resolveStringConstant(value, depth+1)
+	case *ast.SelectorExpr:
+		// pkg.Const resolves against constants from any scanned package
+		return p.resolveStringConstant(e.Sel, depth+1)
+	case *ast.ParenExpr:
+		return p.resolveStringConstant(e.X, depth+1)
+	case *ast.BinaryExpr:
+		if e.Op != token.ADD {
+			return "", false
+		}
+		left, ok := p.resolveStringConstant(e.X, depth+1)
+		if !ok {
+			return "", false
+		}
+		right, ok := p.resolveStringConstant(e.Y, depth+1)
+		return left + right, ok
+	}
+	return "", false
+}
 
-			// Check if we have route information
-			method, hasMethod := annotations["route_method"]
-			path, hasPath := annotations["route_path"]
-
-			// Only process functions with a @route annotation
-			if hasMethod && hasPath {
-				route := &Route{
-					Name:        annotations["name"],
-					Method:      method,
-					Path:        path,
-					Handler:     handlerName,
-					Description: annotations["description"],
-					BodyType:    annotations["body"], // Store the body type name to be resolved later
-					Tags:        make(map[string]string),
-				}
+// applyBasePaths prefixes every route with its resolved @basepath
+func (p *Parser) applyBasePaths() {
+	for _, route := range p.routes {
+		if route.BasePath == "" || route.GRPC != nil {
+			continue
+		}
 
-				p.routes = append(p.routes, route)
-				fmt.Printf("Found route: %s %s in handler %s\n", method, path, handlerName)
+		basePath := route.BasePath
+		if !strings.HasPrefix(basePath, "/") {
+			expr, err := parser.ParseExpr(basePath)
+			resolved, ok := "", false
+			if err == nil {
+				resolved, ok = p.resolveStringConstant(expr, 0)
+			}
+			if !ok {
+				getLogger().Warn(fmt.Sprintf("Could not resolve @basepath %s on handler %s", route.BasePath, route.Handler))
+				continue
 			}
+			basePath = resolved
 		}
-		return true
-	})
 
-	return nil
+		route.BasePath = strings.TrimRight(basePath, "/")
+		route.Path = route.BasePath + route.Path
+	}
 }
 
-// FindStruct searches for a specific struct definition across all files
-func (p *Parser) FindStruct(dirPath, structName string) (*RequestBody, error) {
-	var foundStruct *RequestBody
+// resolveNestedStructs looks up fields whose type is another struct and attaches its
+// definition, preferring structs from the package in structDir. Types already being
+// resolved higher up are skipped to avoid infinite recursion. The fields of embedded
+// structs are then promoted into requestBody.
+func (p *Parser) resolveNestedStructs(dirPath, structDir string, requestBody *RequestBody, resolving map[string]bool) error {
+	for i, field := range requestBody.Fields {
+		// Arrays resolve the struct of their elements, maps the struct of their values
+		structType := field.Type
+		switch field.Type {
+		case "array":
+			structType = field.ElemType
+		case "map":
+			structType = field.MapValueType
+		}
+
+		if isBuiltinType(structType) || isWellKnownType(structType) || resolving[structType] {
+			continue
+		}
 
-	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+		nested, nestedDir, err := p.findStructFrom(dirPath, structDir, structType)
 		if err != nil {
 			return err
 		}
-
-		// Skip if already found or not a Go file
-		if foundStruct != nil || info.IsDir() || !strings.HasSuffix(path, ".go") {
-			return nil
+		if nested == nil {
+			if field.Inline && field.Type != "map" {
+				getLogger().Debug(fmt.Sprintf("Could not find embedded struct %s in %s, skipping its fields", structType, requestBody.TypeName))
+			}
+			continue
 		}
 
-		// Try to find the struct in this file
-		requestBody, err := p.ParseStructFromFile(path, structName)
+		resolving[structType] = true
+		err = p.resolveNestedStructs(dirPath, nestedDir, nested, resolving)
+		delete(resolving, structType)
 		if err != nil {
 			return err
 		}
 
-		if requestBody != nil {
-			foundStruct = requestBody
+		requestBody.Fields[i].Nested = nested
+	}
+
+	requestBody.Fields = promoteEmbeddedFields(requestBody.Fields)
+	return nil
+}
+
+// promoteEmbeddedFields replaces resolved embedded structs with their fields. As with
+// encoding/json, a field declared on the struct itself wins over a promoted one of the same name.
+func promoteEmbeddedFields(fields []RequestBodyField) []RequestBodyField {
+	declared := make(map[string]bool)
+	for _, field := range fields {
+		if !isEmbeddedStruct(field) {
+			declared[field.JSONName] = true
 		}
+	}
 
-		return nil
-	})
+	promoted := []RequestBodyField{}
+	for _, field := range fields {
+		if !isEmbeddedStruct(field) {
+			promoted = append(promoted, field)
+			continue
+		}
+		for _, nestedField := range field.Nested.Fields {
+			if !declared[nestedField.JSONName] {
+				promoted = append(promoted, nestedField)
+			}
+		}
+	}
+	return promoted
+}
 
-	if err != nil {
-		return nil, err
+// isEmbeddedStruct reports whether a field is a resolved struct whose fields are flattened into the parent
+func isEmbeddedStruct(field RequestBodyField) bool {
+	return field.Inline && field.Nested != nil && field.Type != "map"
+}
+
+// isBuiltinType reports whether a parsed field type can't refer to a struct
+func isBuiltinType(fieldType string) bool {
+	switch fieldType {
+	case "string", "bool", "byte", "rune", "error", "any",
+		"int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+		"float32", "float64", "complex64", "complex128",
+		"array", "map", "unknown", "":
+		return true
 	}
+	return false
+}
 
-	return foundStruct, nil
+// qualifiedTypeName returns the type name of a field, keeping the package of imported
+// types (url.URL) and looking through pointers. Slices and maps are reported as array and
+// map, other type expressions are unknown.
+func qualifiedTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok {
+			return pkg.Name + "." + t.Sel.Name
+		}
+		return t.Sel.Name
+	case *ast.StarExpr:
+		return qualifiedTypeName(t.X)
+	case *ast.ArrayType:
+		return "array"
+	case *ast.MapType:
+		return "map"
+	}
+	return "unknown"
 }
 
 // ParseStructFromFile parses a file looking for a specific struct
 func (p *Parser) ParseStructFromFile(filePath, structName string) (*RequestBody, error) {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, filePath, nil, parser.ParseComments)
+	node, err := p.parseFile(filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -188,23 +1137,44 @@ func (p *Parser) ParseStructFromFile(filePath, structName string) (*RequestBody,
 			// Extract struct fields
 			fields := []RequestBodyField{}
 			for _, field := range structType.Fields.List {
-				if len(field.Names) == 0 {
-					continue // Skip embedded fields
+				// Embedded structs are named after their type, and have their fields promoted
+				// even when the type itself is unexported
+				embedded := len(field.Names) == 0
+				var fieldName string
+				if embedded {
+					typeName := qualifiedTypeName(field.Type)
+					fieldName = typeName[strings.LastIndex(typeName, ".")+1:]
+				} else {
+					fieldName = field.Names[0].Name
+					if !ast.IsExported(fieldName) {
+						continue // Unexported fields are never marshaled
+					}
 				}
 
-				fieldName := field.Names[0].Name
-
 				// Get field type as string
 				var fieldType string
+				var mapKeyType, mapValueType string
+				var elemType string
+				_, pointer := field.Type.(*ast.StarExpr)
 				switch t := field.Type.(type) {
 				case *ast.Ident:
 					fieldType = t.Name
 				case *ast.SelectorExpr:
-					fieldType = t.Sel.Name
+					// Keep the package so well-known types like url.URL can be recognised
+					fieldType = qualifiedTypeName(t)
+				case *ast.StarExpr:
+					fieldType = qualifiedTypeName(t)
 				case *ast.ArrayType:
 					fieldType = "array"
+					elemType = qualifiedTypeName(t.Elt)
+					// encoding/json writes byte slices as base64 strings
+					if elemType == "byte" || elemType == "uint8" {
+						fieldType, elemType = "string", ""
+					}
 				case *ast.MapType:
 					fieldType = "map"
+					mapKeyType = qualifiedTypeName(t.Key)
+					mapValueType = qualifiedTypeName(t.Value)
 				default:
 					fieldType = "unknown"
 				}
@@ -212,7 +1182,16 @@ func (p *Parser) ParseStructFromFile(filePath, structName string) (*RequestBody,
 				// Parse struct tags
 				tags := make(map[string]string)
 				jsonName := fieldName
+				jsonNamed := false
+				xmlName := ""
+				formName := ""
+				example := ""
+				xmlAttr := false
+				xmlCharData := false
 				required := false
+				inline := false
+				omitEmpty := false
+				var validation *FieldValidation
 
 				if field.Tag != nil && len(field.Tag.Value) > 0 {
 					tagValue := strings.Trim(field.Tag.Value, "`")
@@ -220,25 +1199,98 @@ func (p *Parser) ParseStructFromFile(filePath, structName string) (*RequestBody,
 
 					// Parse json tag
 					if jsonTag, ok := structTags.Lookup("json"); ok {
+						if jsonTag == "-" {
+							continue // Always omitted by encoding/json, "-," names a field "-"
+						}
 						parts := strings.Split(jsonTag, ",")
 						if len(parts) > 0 && parts[0] != "" {
-							jsonName = parts[0]
+							jsonName, jsonNamed = parts[0], true
+						}
+						for _, option := range parts[1:] {
+							switch option {
+							case "inline":
+								inline = true
+							case "omitempty":
+								omitEmpty = true
+							}
 						}
 						tags["json"] = jsonTag
 					}
 
+					// Parse xml tag
+					if xmlTag, ok := structTags.Lookup("xml"); ok {
+						parts := strings.Split(xmlTag, ",")
+						xmlName = parts[0]
+						for _, option := range parts[1:] {
+							switch option {
+							case "attr":
+								xmlAttr = true
+							case "chardata":
+								xmlCharData = true
+							}
+							// omitempty makes no difference to examples, every field is rendered
+						}
+						tags["xml"] = xmlTag
+					}
+
+					// Parse form tag, gin binds form and multipart bodies by it
+					if formTag, ok := structTags.Lookup("form"); ok {
+						formName, _, _ = strings.Cut(formTag, ",")
+						tags["form"] = formTag
+					}
+
+					// Parse query tag for structs bound to the query string
+					if queryTag, ok := structTags.Lookup("query"); ok {
+						tags["query"] = queryTag
+					}
+
+					// Parse uri tag for path parameter bindings
+					if uriTag, ok := structTags.Lookup("uri"); ok {
+						tags["uri"] = uriTag
+					}
+
 					// Parse binding tag for required fields
 					if bindingTag, ok := structTags.Lookup("binding"); ok {
 						required = strings.Contains(bindingTag, "required")
 						tags["binding"] = bindingTag
 					}
+
+					// An example tag replaces the generated example value
+					if exampleTag, ok := structTags.Lookup("example"); ok {
+						example = exampleTag
+						tags["example"] = exampleTag
+					}
+
+					// Both gin's binding tag and go-playground's validate tag carry validator rules
+					for _, key := range []string{"binding", "validate"} {
+						if rules, ok := structTags.Lookup(key); ok {
+							tags[key] = rules
+							validation = parseValidationRules(rules, validation)
+						}
+					}
+				}
+
+				// Like encoding/json, only embedded structs without a json name are flattened
+				if embedded && !jsonNamed {
+					inline = true
 				}
 
 				// Extract field description from comments
 				fieldDescription := ""
+				var condition *FieldCondition
 				if field.Doc != nil {
 					for _, comment := range field.Doc.List {
 						text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+
+						// @when lines describe when the field applies rather than what it is
+						if matches := whenPattern.FindStringSubmatch(text); len(matches) > 2 {
+							condition = &FieldCondition{
+								Field: matches[1],
+								Value: strings.TrimSpace(matches[2]),
+							}
+							continue
+						}
+
 						if fieldDescription != "" {
 							fieldDescription += " "
 						}
@@ -248,12 +1300,26 @@ func (p *Parser) ParseStructFromFile(filePath, structName string) (*RequestBody,
 
 				// Create a new field
 				requestField := RequestBodyField{
-					Name:        fieldName,
-					Type:        fieldType,
-					JSONName:    jsonName,
-					Required:    required,
-					Description: fieldDescription,
-					Tags:        tags,
+					Name:         fieldName,
+					Type:         fieldType,
+					JSONName:     jsonName,
+					FormName:     formName,
+					XMLName:      xmlName,
+					XMLAttr:      xmlAttr,
+					XMLCharData:  xmlCharData,
+					Required:     required,
+					OmitEmpty:    omitEmpty,
+					Pointer:      pointer,
+					Description:  fieldDescription,
+					Example:      example,
+					Tags:         tags,
+					When:         condition,
+					Validation:   validation,
+					Inline:       inline,
+					Embedded:     embedded,
+					MapKeyType:   mapKeyType,
+					MapValueType: mapValueType,
+					ElemType:     elemType,
 				}
 
 				fields = append(fields, requestField)
@@ -274,11 +1340,348 @@ func (p *Parser) ParseStructFromFile(filePath, structName string) (*RequestBody,
 	return requestBody, nil
 }
 
+// validationFormats are the validator rules that fix a field's format
+var validationFormats = map[string]bool{
+	"email": true, "url": true, "http_url": true, "uri": true,
+	"uuid": true, "uuid4": true, "ip": true, "ipv4": true, "datetime": true,
+}
+
+// parseValidationRules adds the constraints in a comma-separated validator tag, such as
+// "required,min=3,max=20", to validation. Rules after dive apply to elements and are skipped.
+func parseValidationRules(rules string, validation *FieldValidation) *FieldValidation {
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "dive" {
+			break
+		}
+
+		if validation == nil {
+			validation = &FieldValidation{}
+		}
+		switch {
+		case validationFormats[name]:
+			validation.Format = name
+		case name == "min" || name == "gte":
+			validation.Min = param
+		case name == "max" || name == "lte":
+			validation.Max = param
+		case name == "oneof":
+			validation.OneOf = strings.Fields(param)
+		}
+	}
+
+	// Tags with only unrelated rules, such as required, don't need a validation
+	if validation != nil && validation.Format == "" && validation.Min == "" && validation.Max == "" && len(validation.OneOf) == 0 {
+		return nil
+	}
+	return validation
+}
+
+// collectVariables records the collection variables declared with @var in a package comment
+func (p *Parser) collectVariables(comments *ast.CommentGroup, filePath string) {
+	for _, comment := range comments.List {
+		matches := varPattern.FindStringSubmatch(comment.Text)
+		if len(matches) < 4 {
+			continue
+		}
+
+		variable := CollectionVariable{Name: matches[2], Value: strings.TrimSpace(matches[3])}
+		if matches[1] != "" {
+			variable = CollectionVariable{Name: matches[1], Secret: true}
+		}
+
+		if existing, ok := p.variables[variable.Name]; ok {
+			if existing != variable {
+				getLogger().Warn(fmt.Sprintf("Variable %s in %s conflicts with an earlier @var, keeping the first", variable.Name, filePath))
+			}
+			continue
+		}
+		p.variables[variable.Name] = variable
+		p.variableOrder = append(p.variableOrder, variable.Name)
+	}
+}
+
+// Variables returns the collection variables declared with @var, in the order they were found
+func (p *Parser) Variables() []CollectionVariable {
+	variables := make([]CollectionVariable, 0, len(p.variableOrder))
+	for _, name := range p.variableOrder {
+		variables = append(variables, p.variables[name])
+	}
+	return variables
+}
+
+// extractScopes collects the scopes of every @scope annotation, separated by commas or spaces
+func (p *Parser) extractScopes(comments *ast.CommentGroup) []string {
+	scopes := []string{}
+	seen := make(map[string]bool)
+
+	for _, comment := range comments.List {
+		matches := scopePattern.FindStringSubmatch(comment.Text)
+		if len(matches) < 2 {
+			continue
+		}
+
+		for _, scope := range strings.FieldsFunc(matches[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			if seen[scope] {
+				continue
+			}
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes
+}
+
+// supportedAuthModes are the @auth modes requests can be generated for
+var supportedAuthModes = map[string]bool{
+	"none":   true,
+	"bearer": true,
+	"basic":  true,
+	"apikey": true,
+	"oauth2": true,
+}
+
+// extractQueryParams collects the @query annotations. Each is written as
+// @query name [type] [required] ["description"], with the type defaulting to string.
+// Repeating a name replaces the earlier parameter.
+func (p *Parser) extractQueryParams(comments *ast.CommentGroup) []QueryParam {
+	params := []QueryParam{}
+	index := make(map[string]int)
+
+	for _, comment := range comments.List {
+		matches := queryPattern.FindStringSubmatch(comment.Text)
+		if len(matches) < 5 {
+			continue
+		}
+
+		param := QueryParam{
+			Name:        matches[1],
+			Type:        matches[2],
+			Required:    matches[3] != "",
+			Description: matches[4],
+		}
+		// @query name required, without a type
+		if param.Type == "required" {
+			param.Type, param.Required = "", true
+		}
+		if param.Type == "" {
+			param.Type = "string"
+		}
+
+		if i, ok := index[param.Name]; ok {
+			params[i] = param
+			continue
+		}
+		index[param.Name] = len(params)
+		params = append(params, param)
+	}
+
+	return params
+}
+
+// extractHeaders collects the @header annotations in order. The value may be quoted,
+// as in @header Authorization "Bearer token", or left out entirely.
+func (p *Parser) extractHeaders(comments *ast.CommentGroup) []RequestHeader {
+	headers := []RequestHeader{}
+
+	for _, comment := range comments.List {
+		matches := headerPattern.FindStringSubmatch(comment.Text)
+		if len(matches) < 4 {
+			continue
+		}
+
+		value := matches[2]
+		if value == "" {
+			value = strings.TrimSpace(matches[3])
+		}
+		headers = append(headers, RequestHeader{Name: matches[1], Value: value})
+	}
+
+	return headers
+}
+
+// checkScopes reports scopes declared without an auth mode, and bearer routes without scopes
+func (p *Parser) checkScopes() {
+	logger := getLogger()
+
+	for _, route := range p.routes {
+		switch {
+		case len(route.Scopes) > 0 && (route.Auth == "" || route.Auth == "none"):
+			logger.Warn(fmt.Sprintf("Handler %s declares @scope but no @auth, scopes are only documented", route.Handler))
+		case len(route.Scopes) == 0 && route.Auth == "bearer":
+			logger.Info(fmt.Sprintf("Handler %s uses bearer auth without declaring any @scope", route.Handler))
+		}
+	}
+}
+
+// extractTags collects the comma-separated values of every @tag annotation
+func (p *Parser) extractTags(comments *ast.CommentGroup) []string {
+	tags := []string{}
+	seen := make(map[string]bool)
+
+	for _, comment := range comments.List {
+		matches := tagPattern.FindStringSubmatch(comment.Text)
+		if len(matches) < 2 {
+			continue
+		}
+		if _, _, ok := tagLabel(matches[1]); ok {
+			continue
+		}
+
+		for _, tag := range strings.Split(matches[1], ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "" || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// extractLabels collects the @tag key value annotations, a later value replacing an earlier one
+func (p *Parser) extractLabels(comments *ast.CommentGroup) map[string]string {
+	var labels map[string]string
+	for _, comment := range comments.List {
+		matches := tagPattern.FindStringSubmatch(comment.Text)
+		if len(matches) < 2 {
+			continue
+		}
+		if key, value, ok := tagLabel(matches[1]); ok {
+			if labels == nil {
+				labels = make(map[string]string)
+			}
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+// tagLabel splits a @tag annotation made of a key and a value, e.g. @tag domain billing
+func tagLabel(text string) (string, string, bool) {
+	fields := strings.Fields(text)
+	if len(fields) != 2 || strings.Contains(text, ",") {
+		return "", "", false
+	}
+	return fields[0], fields[1], true
+}
+
+// extractSettings collects @settings key=value pairs and @follow-redirects into Bruno
+// request settings, dropping unknown keys and invalid values with a warning
+func (p *Parser) extractSettings(comments *ast.CommentGroup, handlerName string) map[string]string {
+	logger := getLogger()
+	settings := make(map[string]string)
+
+	for _, comment := range comments.List {
+		pairs := []string{}
+		if matches := settingsPattern.FindStringSubmatch(comment.Text); len(matches) > 1 {
+			pairs = strings.Fields(matches[1])
+		}
+		if matches := redirectsPattern.FindStringSubmatch(comment.Text); len(matches) > 1 {
+			pairs = append(pairs, "followRedirects="+matches[1])
+		}
+
+		for _, pair := range pairs {
+			key, value, found := strings.Cut(pair, "=")
+			if !found {
+				logger.Warn(fmt.Sprintf("Invalid setting %q on handler %s, expected key=value", pair, handlerName))
+				continue
+			}
+
+			kind, known := knownRequestSettings[key]
+			if !known {
+				logger.Warn(fmt.Sprintf("Unknown setting %q on handler %s", key, handlerName))
+				continue
+			}
+
+			if !validSettingValue(kind, value) {
+				logger.Warn(fmt.Sprintf("Invalid value %q for setting %s on handler %s", value, key, handlerName))
+				continue
+			}
+
+			settings[key] = value
+		}
+	}
+
+	return settings
+}
+
+// knownRequestSettings are the keys Bruno accepts in a request settings block, with their value kind
+var knownRequestSettings = map[string]string{
+	"encodeUrl":       "bool",
+	"followRedirects": "bool",
+	"maxRedirects":    "int",
+	"timeout":         "int",
+}
+
+// validSettingValue checks a setting value against its kind
+func validSettingValue(kind string, value string) bool {
+	switch kind {
+	case "bool":
+		_, err := strconv.ParseBool(value)
+		return err == nil
+	case "int":
+		n, err := strconv.Atoi(value)
+		return err == nil && n >= 0
+	}
+	return true
+}
+
+// extractBulk reads the @bulk annotation, defaulting to a single item when no count is given
+func (p *Parser) extractBulk(comments *ast.CommentGroup) *BulkConfig {
+	for _, comment := range comments.List {
+		matches := bulkPattern.FindStringSubmatch(comment.Text)
+		if len(matches) < 2 {
+			continue
+		}
+
+		bulk := &BulkConfig{ItemCount: 1}
+		if count, err := strconv.Atoi(matches[1]); err == nil && count > 0 {
+			bulk.ItemCount = count
+		}
+		return bulk
+	}
+	return nil
+}
+
+// extractResponses collects every @response annotation from the comments
+func (p *Parser) extractResponses(comments *ast.CommentGroup) []ResponseSpec {
+	responses := []ResponseSpec{}
+	index := make(map[int]int)
+
+	for _, comment := range comments.List {
+		if matches := responsePattern.FindStringSubmatch(comment.Text); len(matches) > 2 {
+			statusCode, err := strconv.Atoi(matches[1])
+			if err != nil {
+				continue
+			}
+
+			response := ResponseSpec{
+				StatusCode: statusCode,
+				TypeName:   matches[2],
+			}
+
+			// Each status code documents a single reply, a repeated one replaces the earlier
+			if i, ok := index[statusCode]; ok {
+				getLogger().Warn(fmt.Sprintf("@response %d is documented more than once, keeping %s", statusCode, strings.TrimSpace(matches[0])))
+				responses[i] = response
+				continue
+			}
+			index[statusCode] = len(responses)
+			responses = append(responses, response)
+		}
+	}
+
+	return responses
+}
+
 // extractAnnotations extracts annotations from comments comments
 func (p *Parser) extractAnnotations(comments *ast.CommentGroup) map[string]string {
 	annotations := make(map[string]string)
 
-	parsingDescription := false
 	for _, comment := range comments.List {
 		text := comment.Text
 
@@ -287,44 +1690,150 @@ func (p *Parser) extractAnnotations(comments *ast.CommentGroup) map[string]strin
 			annotations["name"] = matches[1]
 		}
 
-		// Extract @route METHOD /path
-		if matches := routePattern.FindStringSubmatch(text); len(matches) > 2 {
-			annotations["route_method"] = matches[1]
-			annotations["route_path"] = matches[2]
-		}
-
 		// Extract @body
 		if matches := bodyPattern.FindStringSubmatch(text); len(matches) > 1 {
 			annotations["body"] = matches[1]
 		}
 
-		// Extract @description
-		descIndex := strings.Index(text, "@description")
-		if descIndex != -1 || parsingDescription {
-			parsingDescription = true
+		// Extract @bodytype
+		if matches := bodyTypePattern.FindStringSubmatch(text); len(matches) > 1 {
+			annotations["bodytype"] = strings.ToLower(matches[1])
+		}
+
+		// Extract @deprecated [note]
+		if matches := deprecatedPattern.FindStringSubmatch(text); len(matches) > 1 {
+			annotations["deprecated"] = strings.TrimSpace(strings.TrimSuffix(matches[1], "*/"))
+		}
+
+		// Extract @ratelimit 100/minute
+		if matches := rateLimitPattern.FindStringSubmatch(text); len(matches) > 1 {
+			annotations["ratelimit"] = strings.TrimSpace(strings.TrimSuffix(matches[1], "*/"))
+		}
+
+		// Extract @basepath /prefix or @basepath ConstantName
+		if matches := basePathPattern.FindStringSubmatch(text); len(matches) > 1 {
+			annotations["basepath"] = matches[1]
+		}
+
+		// Extract @grpc package.Service.Method
+		if matches := grpcPattern.FindStringSubmatch(text); len(matches) > 2 {
+			annotations["grpc_service"] = matches[1]
+			annotations["grpc_method"] = matches[2]
+		}
 
-			var descText string
-			// Get the text after @description
-			if descIndex != -1 {
-				descText = text[descIndex+len("@description"):]
-			} else {
-				descText = text
+		// Extract @auth MODE [header|query] [key] [option=value...]
+		if matches := authPattern.FindStringSubmatch(text); len(matches) > 2 {
+			annotations["auth"] = matches[1]
+			for _, option := range strings.Fields(strings.TrimSuffix(matches[2], "*/")) {
+				name, value, isOption := strings.Cut(option, "=")
+				switch {
+				case isOption && authOptions[name]:
+					annotations["auth_"+name] = value
+				case isOption:
+					getLogger().Warn(fmt.Sprintf("Unknown @auth option %s", name))
+				case (option == "header" || option == "query") && annotations["auth_in"] == "" && annotations["auth_key"] == "":
+					annotations["auth_in"] = option
+				case annotations["auth_key"] == "":
+					annotations["auth_key"] = option
+				}
 			}
+		}
+
+	}
+
+	lines := commentLines(comments)
+	if description, ok := extractTextAnnotation(lines, "description"); ok {
+		annotations["description"] = normalizeDescription(description)
+	}
+	if query, ok := extractTextAnnotation(lines, "graphql-query"); ok {
+		annotations["graphql_query"] = strings.TrimSpace(query)
+	}
 
-			// Find the next annotation tag if there is one
-			nextTagIndex := strings.IndexAny(descText, "@")
+	return annotations
+}
 
-			if nextTagIndex != -1 {
-				// Only take the text until the next tag
-				parsingDescription = false
-				descText = descText[:nextTagIndex]
+// extractTextAnnotation collects the text of a free-form annotation such as @description,
+// from the tag up to the next recognized tag, which may be on the same line or a later
+// one. The indentation the following lines share is removed, the rest is kept for
+// markdown such as nested lists. Reports false when the annotation is missing.
+func extractTextAnnotation(lines []string, name string) (string, bool) {
+	description := []string{}
+	found, parsing := false, false
+	for _, line := range lines {
+		from, to := 0, len(line)
+		if !parsing {
+			from = -1
+		}
+		for _, tag := range annotationTags(line) {
+			if !parsing && tag.name == name {
+				from, parsing, found = tag.end, true, true
+				continue
+			}
+			if parsing && tag.start >= from {
+				// Tags are byte offsets of ASCII '@', so slicing here keeps every rune whole
+				to, parsing = tag.start, false
+				break
 			}
+		}
+		if from == -1 {
+			continue
+		}
 
-			descText = strings.TrimSpace(strings.ReplaceAll(descText, "/", ""))
+		text := strings.TrimRight(line[from:to], " \t")
+		if from > 0 {
+			text = strings.TrimSpace(text)
+		}
+		description = append(description, text)
+	}
+	if len(description) > 1 {
+		dedent(description[1:])
+	}
+	return strings.Join(description, "\n"), found
+}
 
-			annotations["description"] += descText + "\n"
+// dedent removes the leading whitespace every non-blank line shares
+func dedent(lines []string) {
+	indent, first := "", true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
 		}
+		lineIndent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			indent, first = lineIndent, false
+			continue
+		}
+		for !strings.HasPrefix(lineIndent, indent) {
+			indent = indent[:len(indent)-1]
+		}
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, indent)
 	}
+}
 
-	return annotations
+// normalizeDescription tidies an assembled description: trailing spaces and the blank
+// lines around it are dropped and runs of blank lines collapse into one paragraph break.
+// Fenced code blocks are kept as written.
+func normalizeDescription(description string) string {
+	lines := []string{}
+	blank, fenced := false, false
+	for _, line := range strings.Split(description, "\n") {
+		if !fenced {
+			line = strings.TrimRight(line, " \t")
+		}
+		if line == "" && !fenced {
+			blank = len(lines) > 0
+			continue
+		}
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
 }
@@ -0,0 +1,2080 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// parseDocComment parses a source snippet and returns the doc comment of its first function
+func parseDocComment(t *testing.T, src string) *ast.CommentGroup {
+	t.Helper()
+
+	node, err := parser.ParseFile(token.NewFileSet(), "handler.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("failed to parse source: %v", err)
+	}
+
+	for _, decl := range node.Decls {
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+			return funcDecl.Doc
+		}
+	}
+
+	t.Fatal("no function declaration found")
+	return nil
+}
+
+func TestExtractAnnotationsMultibyteDescription(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{
+			name: "accented characters",
+			src: `package main
+
+// @route GET /café
+// @description Crée un utilisateur à la volée
+func Handler() {}
+`,
+			expected: "Crée un utilisateur à la volée",
+		},
+		{
+			name: "emoji before next tag",
+			src: `package main
+
+// @route GET /party
+// @description Starts the party 🎉🥳@body PartyRequest
+func Handler() {}
+`,
+			expected: "Starts the party 🎉🥳",
+		},
+		{
+			name: "multi-line with mixed scripts",
+			src: `package main
+
+// @route GET /greet
+// @description こんにちは世界
+// naïve résumé ✨
+// @body GreetRequest
+func Handler() {}
+`,
+			expected: "こんにちは世界\nnaïve résumé ✨",
+		},
+	}
+
+	p := NewParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annotations := p.extractAnnotations(parseDocComment(t, tt.src))
+
+			// Only the content matters here, not the trailing newlines between lines.
+			if got := strings.TrimRight(annotations["description"], "\n"); got != tt.expected {
+				t.Errorf("description = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExtractAnnotationsNormalizesDescription(t *testing.T) {
+	src := `package main
+
+// @route GET /users
+// @description
+//
+// Lists the users.
+//
+//
+// Results are paged.
+//
+// @body ListRequest
+func Handler() {}
+`
+
+	annotations := NewParser().extractAnnotations(parseDocComment(t, src))
+	if got, want := annotations["description"], "Lists the users.\n\nResults are paged."; got != want {
+		t.Errorf("description = %q, want %q", got, want)
+	}
+}
+
+func TestExtractAnnotationsDescriptionOrder(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         string
+		description string
+		body        string
+	}{
+		{
+			name: "description after route",
+			src: `package main
+
+// @route POST /users
+// @description Creates a user
+// @body CreateUserRequest
+func Handler() {}
+`,
+			description: "Creates a user",
+			body:        "CreateUserRequest",
+		},
+		{
+			name: "description first",
+			src: `package main
+
+// @description Creates a user
+// and sends a welcome mail
+// @route POST /users
+// @body CreateUserRequest
+func Handler() {}
+`,
+			description: "Creates a user\nand sends a welcome mail",
+			body:        "CreateUserRequest",
+		},
+		{
+			name: "description last",
+			src: `package main
+
+// @body CreateUserRequest
+// @route POST /users
+// @description Creates a user
+func Handler() {}
+`,
+			description: "Creates a user",
+			body:        "CreateUserRequest",
+		},
+		{
+			name: "tag on the same line",
+			src: `package main
+
+// @route POST /users
+// @description Creates a user @body CreateUserRequest
+func Handler() {}
+`,
+			description: "Creates a user",
+			body:        "CreateUserRequest",
+		},
+		{
+			name: "e-mail addresses and unknown tags are text",
+			src: `package main
+
+// @route POST /users
+// @description Mails support@example.com, @mentions stay too
+// @body CreateUserRequest
+func Handler() {}
+`,
+			description: "Mails support@example.com, @mentions stay too",
+			body:        "CreateUserRequest",
+		},
+		{
+			name: "no description",
+			src: `package main
+
+// Handler creates a user
+// @route POST /users
+// @body CreateUserRequest
+func Handler() {}
+`,
+			body: "CreateUserRequest",
+		},
+	}
+
+	p := NewParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annotations := p.extractAnnotations(parseDocComment(t, tt.src))
+			if annotations["description"] != tt.description {
+				t.Errorf("description = %q, want %q", annotations["description"], tt.description)
+			}
+			if annotations["body"] != tt.body {
+				t.Errorf("body = %q, want %q", annotations["body"], tt.body)
+			}
+		})
+	}
+}
+
+func TestExtractAnnotationsKeepsMarkdownDescription(t *testing.T) {
+	want := "Creates a user, see https://example.com/docs/users.\n\n" +
+		"- Sends a welcome mail\n" +
+		"  - unless `quiet` is set\n\n" +
+		"```json\n" +
+		"{\n" +
+		"  \"name\": \"Jane\"\n" +
+		"}\n" +
+		"```"
+
+	tests := map[string]string{
+		"line comments": `package main
+
+// @route POST /users
+// @description Creates a user, see https://example.com/docs/users.
+//
+// - Sends a welcome mail
+//   - unless ` + "`quiet`" + ` is set
+//
+// ` + "```json" + `
+// {
+//   "name": "Jane"
+// }
+// ` + "```" + `
+// @body CreateUserRequest
+func Handler() {}
+`,
+		"block comment": `package main
+
+/*
+ * @route POST /users
+ * @description Creates a user, see https://example.com/docs/users.
+ *
+ * - Sends a welcome mail
+ *   - unless ` + "`quiet`" + ` is set
+ *
+ * ` + "```json" + `
+ * {
+ *   "name": "Jane"
+ * }
+ * ` + "```" + `
+ */
+func Handler() {}
+`,
+	}
+
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			annotations := NewParser().extractAnnotations(parseDocComment(t, src))
+			if got := annotations["description"]; got != want {
+				t.Errorf("description =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestExtractAnnotationsAuthOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected map[string]string
+	}{
+		{
+			name: "api key in query",
+			src: `package main
+
+// @auth apikey query api_key
+func Handler() {}
+`,
+			expected: map[string]string{"auth": "apikey", "auth_in": "query", "auth_key": "api_key"},
+		},
+		{
+			name: "bearer with custom header and prefix",
+			src: `package main
+
+// @auth bearer header=X-Auth-Token prefix=Token
+func Handler() {}
+`,
+			expected: map[string]string{"auth": "bearer", "auth_header": "X-Auth-Token", "auth_prefix": "Token"},
+		},
+		{
+			name: "block comment",
+			src: `package main
+
+/*
+@auth bearer
+@description Lists the users
+*/
+func Handler() {}
+`,
+			expected: map[string]string{"auth": "bearer"},
+		},
+		{
+			name: "single line block comment",
+			src: `package main
+
+/* @auth bearer */
+func Handler() {}
+`,
+			expected: map[string]string{"auth": "bearer"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annotations := NewParser().extractAnnotations(parseDocComment(t, tt.src))
+			for key, want := range tt.expected {
+				if annotations[key] != want {
+					t.Errorf("annotations[%q] = %q, want %q", key, annotations[key], want)
+				}
+			}
+			for _, key := range []string{"auth_header", "auth_key"} {
+				if _, ok := annotations[key]; ok && tt.expected[key] == "" {
+					t.Errorf("unexpected %s %q", key, annotations[key])
+				}
+			}
+		})
+	}
+}
+
+func TestParseDirectoryWarnsAboutAnnotationsOnce(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+// @route GET /users
+// @route GET /v2/users
+// @auth bearer realm=users
+func ListUsers() {}
+`,
+	})
+	captured := captureReport(t)
+
+	if _, err := NewParser().ParseDirectory(dir); err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	if len(captured.findings) != 1 || captured.findings[0].Message != "Unknown @auth option realm" {
+		t.Errorf("findings = %+v, want one warning about the realm option", captured.findings)
+	}
+}
+
+func TestExtractQueryParams(t *testing.T) {
+	doc := parseDocComment(t, `package main
+
+// @query page int "page number"
+// @query q required "search text"
+// @query sort
+// @query page int required
+func Handler() {}
+`)
+
+	expected := []QueryParam{
+		{Name: "page", Type: "int", Required: true},
+		{Name: "q", Type: "string", Required: true, Description: "search text"},
+		{Name: "sort", Type: "string"},
+	}
+	if params := NewParser().extractQueryParams(doc); !reflect.DeepEqual(params, expected) {
+		t.Errorf("extractQueryParams() = %+v, want %+v", params, expected)
+	}
+}
+
+func TestExtractHeaders(t *testing.T) {
+	doc := parseDocComment(t, `package main
+
+// @header Authorization "Bearer token"
+// @header X-Request-ID
+// @header Accept application/json
+func Handler() {}
+`)
+
+	expected := []RequestHeader{
+		{Name: "Authorization", Value: "Bearer token"},
+		{Name: "X-Request-ID"},
+		{Name: "Accept", Value: "application/json"},
+	}
+	if headers := NewParser().extractHeaders(doc); !reflect.DeepEqual(headers, expected) {
+		t.Errorf("extractHeaders() = %+v, want %+v", headers, expected)
+	}
+}
+
+func TestExtractResponsesKeepsOnePerStatus(t *testing.T) {
+	doc := parseDocComment(t, `package main
+
+// @response 200 User
+// @response 404
+// @response 200 UserList
+func Handler() {}
+`)
+
+	expected := []ResponseSpec{{StatusCode: 200, TypeName: "UserList"}, {StatusCode: 404}}
+	if responses := NewParser().extractResponses(doc); !reflect.DeepEqual(responses, expected) {
+		t.Errorf("extractResponses() = %+v, want %+v", responses, expected)
+	}
+}
+
+// writeSourceFiles writes Go source files into a temporary directory and returns it
+func writeSourceFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestParseDirectoryResolvesBasePathConstant(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"routes.go": `package api
+
+const APIPrefix = "/api"
+const V1Prefix = APIPrefix + "/v1/"
+`,
+		"users.go": `package api
+
+// @route GET /users
+// @basepath V1Prefix
+func ListUsers() {}
+
+// @route POST /users
+// @basepath /admin
+func CreateUser() {}
+
+// @route DELETE /users/:id
+// @basepath MissingPrefix
+func DeleteUser() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	want := map[string]string{
+		"ListUsers":  "/api/v1/users",
+		"CreateUser": "/admin/users",
+		"DeleteUser": "/users/:id", // unresolved prefixes are left off
+	}
+	for _, route := range routes {
+		if route.Path != want[route.Handler] {
+			t.Errorf("%s path = %q, want %q", route.Handler, route.Path, want[route.Handler])
+		}
+	}
+}
+
+func TestParseDirectoryAppliesFileBasePath(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `// Package api serves users.
+// @basepath Prefix
+package api
+
+const Prefix = "/api/v2"
+
+// @route GET /users
+func ListUsers() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	if len(routes) != 1 || routes[0].Path != "/api/v2/users" {
+		t.Fatalf("expected a single /api/v2/users route, got %+v", routes)
+	}
+}
+
+func TestParseDirectoryKeepsSliceElementTypes(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"orders.go": `package api
+
+type Item struct {
+	SKU string ` + "`json:\"sku\"`" + `
+}
+
+type Order struct {
+	Tags   []string ` + "`json:\"tags\"`" + `
+	Items  []*Item  ` + "`json:\"items\"`" + `
+	Raw    []byte   ` + "`json:\"raw\"`" + `
+	Matrix [][]int  ` + "`json:\"matrix\"`" + `
+}
+
+// @route POST /orders
+// @body Order
+func CreateOrder() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	if len(routes) != 1 || routes[0].RequestBody == nil {
+		t.Fatalf("expected one route with a body, got %+v", routes)
+	}
+
+	got := exampleBody(routes[0].RequestBody, &exampleOptions{budget: newBodyBudget(0), itemCount: 1})
+	want := map[string]interface{}{
+		"tags":   []interface{}{""},
+		"items":  []interface{}{map[string]interface{}{"sku": ""}},
+		"raw":    "",
+		"matrix": []interface{}{[]interface{}{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exampleBody() = %v, want %v", got, want)
+	}
+}
+
+func TestParseDirectoryUnwrapsPointerFields(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+type Owner struct {
+	ID string ` + "`json:\"id\"`" + `
+}
+
+type Patch struct {
+	Name  *string ` + "`json:\"name\"`" + `
+	Count *int    ` + "`json:\"count\"`" + `
+	Owner *Owner  ` + "`json:\"owner\"`" + `
+	Note  string  ` + "`json:\"note\"`" + `
+}
+
+// @route PATCH /users/:id
+// @body Patch
+func PatchUser() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	if len(routes) != 1 || routes[0].RequestBody == nil {
+		t.Fatalf("expected one route with a body, got %+v", routes)
+	}
+
+	for _, field := range routes[0].RequestBody.Fields {
+		if field.Pointer != (field.Name != "Note") {
+			t.Errorf("field %s Pointer = %v", field.Name, field.Pointer)
+		}
+	}
+
+	got := exampleBody(routes[0].RequestBody, &exampleOptions{budget: newBodyBudget(0)})
+	want := map[string]interface{}{
+		"name":  "",
+		"count": 0,
+		"owner": map[string]interface{}{"id": ""},
+		"note":  "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exampleBody() = %v, want %v", got, want)
+	}
+}
+
+func TestParseDirectoryOrdersRoutesByFile(t *testing.T) {
+	files := map[string]string{}
+	want := []string{}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		files[name+"/handlers.go"] = "package " + name + "\n\n// @route GET /" + name + "\nfunc List() {}\n\n// @route POST /" + name + "\nfunc Create() {}\n"
+		want = append(want, "GET /"+name, "POST /"+name)
+	}
+	dir := writeSourceFiles(t, files)
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	got := []string{}
+	for _, route := range routes {
+		got = append(got, route.Method+" "+route.Path)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("routes = %v, want %v", got, want)
+	}
+}
+
+func TestParseDirectoryFollowsBodyImports(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"legacy/models/user.go": "package models\n\ntype CreateUser struct {\n\tLogin string `json:\"login\"`\n}\n",
+		"v2/models/user.go":     "package models\n\ntype CreateUser struct {\n\tEmail string `json:\"email\"`\n}\n",
+		"api/users.go": `package api
+
+import m "example.com/app/v2/models"
+
+// @route POST /users
+// @body m.CreateUser
+func CreateUser(req m.CreateUser) {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	if len(routes) != 1 || routes[0].RequestBody == nil {
+		t.Fatalf("expected one route with a body, got %+v", routes)
+	}
+	if fields := routes[0].RequestBody.Fields; len(fields) != 1 || fields[0].JSONName != "email" {
+		t.Errorf("body fields = %+v, want the v2 struct", fields)
+	}
+}
+
+func TestParseDirectoryFlattensEmbeddedStructs(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+import "gorm.io/gorm"
+
+type audit struct {
+	CreatedBy string ` + "`json:\"created_by\"`" + `
+}
+
+type BaseRequest struct {
+	audit
+	RequestID string ` + "`json:\"request_id\"`" + `
+	Name      string ` + "`json:\"name\"`" + `
+}
+
+type CreateUser struct {
+	gorm.Model
+	*BaseRequest
+	Name string ` + "`json:\"name\"`" + `
+}
+
+// @route POST /users
+// @body CreateUser
+func CreateUserHandler() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	if len(routes) != 1 || routes[0].RequestBody == nil {
+		t.Fatalf("expected one route with a body, got %+v", routes)
+	}
+
+	got := exampleBody(routes[0].RequestBody, &exampleOptions{budget: newBodyBudget(0)})
+	want := map[string]interface{}{"created_by": "", "request_id": "", "name": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exampleBody() = %v, want %v", got, want)
+	}
+}
+
+func TestParseDirectoryNestsNamedEmbeddedStructs(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"orders.go": `package api
+
+type Inner struct {
+	Note string ` + "`json:\"note\"`" + `
+}
+
+type Meta struct {
+	Source string ` + "`json:\"source\"`" + `
+}
+
+type Order struct {
+	Inner ` + "`json:\"inner\"`" + `
+	*Meta ` + "`json:\",omitempty\"`" + `
+	ID    string ` + "`json:\"id\"`" + `
+}
+
+// @route POST /orders
+// @body Order
+func CreateOrder() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	if len(routes) != 1 || routes[0].RequestBody == nil {
+		t.Fatalf("expected one route with a body, got %+v", routes)
+	}
+
+	// A json name nests the embedded struct, options alone leave it flattened
+	got := exampleBody(routes[0].RequestBody, &exampleOptions{budget: newBodyBudget(0)})
+	want := map[string]interface{}{
+		"inner":  map[string]interface{}{"note": ""},
+		"source": "",
+		"id":     "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exampleBody() = %v, want %v", got, want)
+	}
+}
+
+func TestParseDirectorySkipsHiddenFields(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+type User struct {
+	Name      string ` + "`json:\"name\"`" + `
+	CreatedBy string ` + "`json:\"-\"`" + `
+	Dash      string ` + "`json:\"-,\"`" + `
+	secret    string
+}
+
+// @route POST /users
+// @body User
+func CreateUser() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	if len(routes) != 1 || routes[0].RequestBody == nil {
+		t.Fatalf("expected one route with a body, got %+v", routes)
+	}
+
+	var names []string
+	for _, field := range routes[0].RequestBody.Fields {
+		names = append(names, field.JSONName)
+	}
+	if want := []string{"name", "-"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("fields = %v, want %v", names, want)
+	}
+}
+
+func TestParseDirectoryReadsExampleTags(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+type User struct {
+	Email string ` + "`json:\"email\" example:\"jane@acme.com\"`" + `
+	Age   int    ` + "`json:\"age\" example:\"42\"`" + `
+}
+
+// @route POST /users
+// @body User
+func CreateUser() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	if len(routes) != 1 || routes[0].RequestBody == nil {
+		t.Fatalf("expected one route with a body, got %+v", routes)
+	}
+
+	got := exampleBody(routes[0].RequestBody, &exampleOptions{budget: newBodyBudget(0)})
+	if want := map[string]interface{}{"email": "jane@acme.com", "age": 42}; !reflect.DeepEqual(got, want) {
+		t.Errorf("exampleBody() = %v, want %v", got, want)
+	}
+}
+
+func TestParseDirectoryResolvesRecursiveStructs(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"tree.go": `package api
+
+type Node struct {
+	Name     string ` + "`json:\"name\"`" + `
+	Parent   *Node  ` + "`json:\"parent\"`" + `
+	Children []Node ` + "`json:\"children\"`" + `
+	Owner    Owner  ` + "`json:\"owner\"`" + `
+}
+
+type Owner struct {
+	Root *Node ` + "`json:\"root\"`" + `
+}
+
+// @route POST /nodes
+// @body Node
+func CreateNode() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	if len(routes) != 1 || routes[0].RequestBody == nil {
+		t.Fatalf("expected one route with a body, got %+v", routes)
+	}
+
+	// Local structs are resolved, but a type is never expanded inside itself
+	fields := routes[0].RequestBody.Fields
+	if fields[1].Nested != nil || fields[2].Nested != nil {
+		t.Errorf("self-referencing fields should stay unresolved, got %+v", fields)
+	}
+	owner := fields[3].Nested
+	if owner == nil || owner.TypeName != "Owner" {
+		t.Fatalf("expected Owner to be resolved, got %+v", fields[3])
+	}
+	if owner.Fields[0].Nested != nil {
+		t.Errorf("Owner.Root refers back to Node and should stay unresolved")
+	}
+}
+
+func TestParseDirectorySeparatesTagLabels(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"billing.go": `package api
+
+// @route GET /invoices
+// @tag billing, admin
+// @tag domain finance
+// @tag tier gold
+func ListInvoices() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	if len(routes) != 1 {
+		t.Fatalf("expected one route, got %+v", routes)
+	}
+
+	if want := []string{"billing", "admin"}; !reflect.DeepEqual(routes[0].Tags, want) {
+		t.Errorf("Tags = %v, want %v", routes[0].Tags, want)
+	}
+	if want := []string{"billing", "admin", "domain:finance", "tier:gold"}; !reflect.DeepEqual(requestTags(routes[0]), want) {
+		t.Errorf("requestTags() = %v, want %v", requestTags(routes[0]), want)
+	}
+}
+
+func TestParseDirectoryReadsDeprecated(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+// @name List Users
+// @route GET /v1/users
+// @deprecated use GET /v2/users
+// @description Lists users
+func ListUsers() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	if len(routes) != 1 || !routes[0].Deprecated || routes[0].Deprecation != "use GET /v2/users" {
+		t.Fatalf("expected a deprecated route, got %+v", routes)
+	}
+
+	g := NewBrunoGenerator(t.TempDir(), "api.example.com")
+	meta, _ := g.generateBrunoMetaDataSection(routes[0])
+	if !strings.Contains(meta, "name: [DEPRECATED] List Users") {
+		t.Errorf("meta missing deprecated name:\n%s", meta)
+	}
+	docs, _ := g.generateDocs(routes[0])
+	if want := "> **Deprecated**: use GET /v2/users\n\nLists users\n"; docs != want {
+		t.Errorf("generateDocs() = %q, want %q", docs, want)
+	}
+}
+
+func TestParseDirectoryDocumentsScopesAndRateLimits(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+// @route GET /users
+// @auth bearer
+// @scope read:users, admin
+// @scope read:users
+// @ratelimit 100/minute per token
+func ListUsers() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	route := routes[0]
+	if !slices.Equal(route.Scopes, []string{"read:users", "admin"}) || route.RateLimit != "100/minute per token" {
+		t.Fatalf("scopes = %q, rate limit = %q", route.Scopes, route.RateLimit)
+	}
+
+	docs, _ := NewBrunoGenerator(t.TempDir(), "api.example.com").generateDocs(route)
+	for _, want := range []string{"### Required scopes\n\n- `read:users`\n- `admin`\n", "### Rate limit\n\n100/minute per token\n"} {
+		if !strings.Contains(docs, want) {
+			t.Errorf("generateDocs() = %q, want it to contain %q", docs, want)
+		}
+	}
+
+	doc := NewOpenAPIGenerator("openapi.json", "https://api.example.com").buildDocument(routes)
+	operation := doc.Paths["/users"]["get"]
+	if want := []OpenAPISecurityRequirement{{"bearerAuth": {"read:users", "admin"}}}; !reflect.DeepEqual(operation.Security, want) {
+		t.Errorf("security = %v, want %v", operation.Security, want)
+	}
+	if scheme := doc.Components.SecuritySchemes["bearerAuth"]; scheme == nil || scheme.Scheme != "bearer" {
+		t.Errorf("securitySchemes = %+v, want a bearer scheme", doc.Components.SecuritySchemes)
+	}
+	if operation.RateLimit != "100/minute per token" {
+		t.Errorf("x-rate-limit = %q", operation.RateLimit)
+	}
+}
+
+func TestParseDirectoryDocumentsConditionalFields(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"payments.go": `package api
+
+type PaymentRequest struct {
+	Method string ` + "`json:\"method\"`" + `
+	// Card number to charge
+	// @when method = card
+	CardNumber string ` + "`json:\"card_number\"`" + `
+}
+
+// @route POST /payments
+// @body PaymentRequest
+func CreatePayment() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	route := routes[0]
+	field := route.RequestBody.Fields[1]
+	if field.When == nil || *field.When != (FieldCondition{Field: "method", Value: "card"}) || field.Description != "Card number to charge" {
+		t.Fatalf("card_number = %+v, want a condition on method = card", field)
+	}
+	if route.RequestBody.Fields[0].When != nil {
+		t.Errorf("method should have no condition, got %+v", route.RequestBody.Fields[0].When)
+	}
+
+	docs, _ := NewBrunoGenerator(t.TempDir(), "api.example.com").generateDocs(route)
+	if want := "### Conditional fields\n\n- `card_number` only applies when `method` is `card`\n"; !strings.Contains(docs, want) {
+		t.Errorf("generateDocs() = %q, want it to contain %q", docs, want)
+	}
+
+	doc := NewOpenAPIGenerator("openapi.json", "https://api.example.com").buildDocument(routes)
+	schema := doc.Paths["/payments"]["post"].RequestBody.Content["application/json"].Schema
+	if got, want := schema.Properties["card_number"].Description, "Card number to charge. Only applies when method is card."; got != want {
+		t.Errorf("card_number description = %q, want %q", got, want)
+	}
+}
+
+func TestParseDirectoryFlattensInlineMaps(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"objects.go": `package api
+
+type ObjectRequest struct {
+	Kind  string            ` + "`json:\"kind\"`" + `
+	Extra map[string]string ` + "`json:\",inline\"`" + `
+}
+
+// @route POST /objects
+// @body ObjectRequest
+func CreateObject() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	route := routes[0]
+
+	body := exampleBody(route.RequestBody, &exampleOptions{budget: newBodyBudget(0)})
+	if want := map[string]interface{}{"kind": "", AdditionalPropertyKey: ""}; !reflect.DeepEqual(body, want) {
+		t.Errorf("exampleBody() = %v, want %v", body, want)
+	}
+
+	docs, _ := NewBrunoGenerator(t.TempDir(), "api.example.com").generateDocs(route)
+	if want := "### Additional properties\n\nAny extra keys are accepted at the top level of the body and collected into `Extra`.\n"; !strings.Contains(docs, want) {
+		t.Errorf("generateDocs() = %q, want it to contain %q", docs, want)
+	}
+
+	doc := NewOpenAPIGenerator("openapi.json", "https://api.example.com").buildDocument(routes)
+	schema := doc.Paths["/objects"]["post"].RequestBody.Content["application/json"].Schema
+	if _, ok := schema.Properties["Extra"]; ok || len(schema.Properties) != 1 {
+		t.Errorf("properties = %v, want only kind", schema.Properties)
+	}
+	if schema.AdditionalProperties == nil || schema.AdditionalProperties.Type != "string" {
+		t.Errorf("additionalProperties = %+v, want a string schema", schema.AdditionalProperties)
+	}
+}
+
+func TestParseDirectoryRendersRequestSettings(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"downloads.go": `package api
+
+// @route GET /downloads/:id
+// @follow-redirects false
+// @settings timeout=5000 encodeUrl=yes retries=3
+func Download() {}
+`,
+	})
+	captured := captureReport(t)
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	route := routes[0]
+	if want := map[string]string{"followRedirects": "false", "timeout": "5000"}; !reflect.DeepEqual(route.Settings, want) {
+		t.Errorf("settings = %v, want %v", route.Settings, want)
+	}
+
+	messages := []string{}
+	for _, finding := range captured.findings {
+		messages = append(messages, finding.Message)
+	}
+	for _, want := range []string{`Invalid value "yes" for setting encodeUrl`, `Unknown setting "retries"`} {
+		if !slices.ContainsFunc(messages, func(message string) bool { return strings.Contains(message, want) }) {
+			t.Errorf("warnings = %q, want one containing %q", messages, want)
+		}
+	}
+
+	if section, want := NewBrunoGenerator(t.TempDir(), "api.example.com").generateSettingsSection(route), "settings {\n  followRedirects: false\n  timeout: 5000\n}"; section != want {
+		t.Errorf("generateSettingsSection() =\n%s\nwant\n%s", section, want)
+	}
+}
+
+func TestParseDirectoryAppliesDefaultDescription(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+// @route GET /users
+func ListUsers() {}
+
+// @route POST /users
+// @description Creates a user
+func CreateUser() {}
+`,
+	})
+
+	parser := NewParser()
+	parser.DefaultDescription = template.Must(template.New("default-description").Parse("{{.Method}} {{.Path}} handled by {{.Handler}}"))
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	want := map[string]string{"ListUsers": "GET /users handled by ListUsers", "CreateUser": "Creates a user"}
+	for _, route := range routes {
+		if description := strings.TrimSpace(route.Description); description != want[route.Handler] {
+			t.Errorf("%s description = %q, want %q", route.Handler, description, want[route.Handler])
+		}
+	}
+
+	parser = NewParser()
+	parser.DefaultDescription = template.Must(template.New("default-description").Parse("{{.Missing}}"))
+	if _, err := parser.ParseDirectory(dir); err == nil || !strings.Contains(err.Error(), "default description for handler ListUsers") {
+		t.Errorf("ParseDirectory() error = %v, want a template error naming the handler", err)
+	}
+}
+
+func TestParseDirectorySkipsTestFiles(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+// @route GET /users
+func ListUsers() {}
+`,
+		"users_test.go": `package api
+
+// @route GET /fixtures
+func FixtureHandler() {}
+`,
+	})
+
+	handlers := func(parser *Parser) []string {
+		t.Helper()
+		routes, err := parser.ParseDirectory(dir)
+		if err != nil {
+			t.Fatalf("ParseDirectory() error = %v", err)
+		}
+		names := []string{}
+		for _, route := range routes {
+			names = append(names, route.Handler)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	if got := handlers(NewParser()); !slices.Equal(got, []string{"ListUsers"}) {
+		t.Errorf("handlers = %v, want the test file skipped", got)
+	}
+
+	parser := NewParser()
+	parser.IncludeTests = true
+	if got := handlers(parser); !slices.Equal(got, []string{"FixtureHandler", "ListUsers"}) {
+		t.Errorf("handlers with IncludeTests = %v, want the test file scanned", got)
+	}
+}
+
+func TestParseDirectorySkipsTestFileStructs(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"fixtures_test.go": `package api
+
+type CreateUserRequest struct {
+	Fixture string ` + "`json:\"fixture\"`" + `
+}
+`,
+		"users.go": `package api
+
+type CreateUserRequest struct {
+	Name string ` + "`json:\"name\"`" + `
+}
+
+// @route POST /users
+// @body CreateUserRequest
+func CreateUser() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	if len(routes) != 1 || routes[0].RequestBody == nil {
+		t.Fatalf("routes = %+v, want CreateUser with a body", routes)
+	}
+	if fields := routes[0].RequestBody.Fields; len(fields) != 1 || fields[0].Name != "Name" {
+		t.Errorf("body fields = %+v, want the Name of users.go", fields)
+	}
+}
+
+func TestParseDirectoryCapturesHandlerSource(t *testing.T) {
+	long := "func Export() {\n" + strings.Repeat("\tstep()\n", MaxSourceSnippetLines) + "}\n"
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+// @route GET /users
+func ListUsers() {
+	list()
+}
+
+// @route GET /export
+` + long,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	if routes[0].SourceSnippet != "" {
+		t.Errorf("source captured without DocsSource: %q", routes[0].SourceSnippet)
+	}
+
+	parser := NewParser()
+	parser.DocsSource = true
+	routes, err = parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	listUsers := routes[0]
+	want := "// @route GET /users\nfunc ListUsers() {\n\tlist()\n}"
+	if listUsers.SourceSnippet != want {
+		t.Errorf("SourceSnippet = %q, want %q", listUsers.SourceSnippet, want)
+	}
+	docs, _ := NewBrunoGenerator(t.TempDir(), "api.example.com").generateDocs(listUsers)
+	if section := fmt.Sprintf("### Source\n\n`%s:4`\n\n```go\n%s\n```\n", listUsers.SourceFile, want); !strings.Contains(docs, section) {
+		t.Errorf("generateDocs() = %q, want it to contain %q", docs, section)
+	}
+
+	lines := strings.Split(routes[1].SourceSnippet, "\n")
+	if len(lines) != MaxSourceSnippetLines+1 || lines[len(lines)-1] != "// ..." {
+		t.Errorf("long handler snippet has %d lines ending in %q, want it cut at %d", len(lines), lines[len(lines)-1], MaxSourceSnippetLines)
+	}
+}
+
+func TestParseDirectorySplitsHandlerRoutes(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+// @name Update User
+// @route PUT /users/:id
+// @route PATCH /users/:id
+// @description Updates a user
+func UpdateUser() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	if len(routes) != 2 {
+		t.Fatalf("expected a route per @route line, got %+v", routes)
+	}
+
+	expected := []string{"update-user_put_users_id", "update-user_patch_users_id"}
+	for i, route := range routes {
+		if route.Name != "Update User" || strings.TrimSpace(route.Description) != "Updates a user" {
+			t.Errorf("route %d should share the handler's annotations, got %+v", i, route)
+		}
+		if name := requestFileName(route); name != expected[i] {
+			t.Errorf("requestFileName() = %q, want %q", name, expected[i])
+		}
+	}
+}
+
+func TestParseDirectoryReportsMalformedRoutes(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+// @route get /users
+func ListUsers() {}
+
+// @route POST
+func CreateUser() {}
+
+// @route FETCH /users/:id
+func GetUser() {}
+
+// @route DELETE /users/:id
+func DeleteUser() {}
+`,
+	})
+
+	parser := NewParser()
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	if len(routes) != 1 || routes[0].Handler != "DeleteUser" {
+		t.Errorf("routes = %+v, want only DeleteUser", routes)
+	}
+
+	want := map[string]int{"ListUsers": 3, "CreateUser": 6, "GetUser": 9}
+	diagnostics := parser.Diagnostics()
+	if len(diagnostics) != len(want) {
+		t.Fatalf("diagnostics = %+v, want %d", diagnostics, len(want))
+	}
+	for _, diagnostic := range diagnostics {
+		if line, ok := want[diagnostic.Handler]; !ok || diagnostic.Line != line || filepath.Base(diagnostic.File) != "users.go" {
+			t.Errorf("diagnostic = %+v, want users.go:%d", diagnostic, line)
+		}
+	}
+
+	parser.Strict = true
+	if _, err := parser.ParseDirectory(dir); err == nil {
+		t.Error("ParseDirectory() in strict mode accepted malformed routes")
+	}
+}
+
+func TestParseDirectoryStrictRejectsMissingBody(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+// @route POST /users
+// @body CreateUserRequest
+func CreateUser() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil || len(routes) != 1 || routes[0].RequestBody != nil {
+		t.Fatalf("ParseDirectory() = %+v, %v, want one route without a body", routes, err)
+	}
+
+	parser := NewParser()
+	parser.Strict = true
+	_, err = parser.ParseDirectory(dir)
+	if err == nil || !strings.Contains(err.Error(), "CreateUserRequest") || !strings.Contains(err.Error(), "CreateUser") {
+		t.Errorf("ParseDirectory() in strict mode error = %v, want one naming the handler and type", err)
+	}
+}
+
+func TestParseDirectoryReportsConflictingRoutes(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+// @route GET /users
+func ListUsers() {}
+
+// @route GET /users
+func SearchUsers() {}
+`,
+	})
+	captured := captureReport(t)
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil || len(routes) != 2 {
+		t.Fatalf("ParseDirectory() = %d routes, %v, want both routes and a warning", len(routes), err)
+	}
+	if len(captured.findings) != 1 || captured.findings[0].Category != "duplicate-route" || captured.findings[0].Handler != "SearchUsers" {
+		t.Fatalf("findings = %+v, want one duplicate-route warning for SearchUsers", captured.findings)
+	}
+	if message := captured.findings[0].Message; !strings.Contains(message, "ListUsers") || !strings.Contains(message, "users.go:4") {
+		t.Errorf("warning %q should name the other handler and its position", message)
+	}
+	// A warning alone leaves the exit code at zero
+	if captured.hasErrors() {
+		t.Errorf("a duplicate route outside -strict was recorded as an error")
+	}
+
+	parser := NewParser()
+	parser.Strict = true
+	if _, err := parser.ParseDirectory(dir); err == nil || !strings.Contains(err.Error(), "duplicate route GET /users") {
+		t.Errorf("ParseDirectory() in strict mode error = %v, want a duplicate route error", err)
+	}
+}
+
+func TestParseDirectorySkipsExcludedPaths(t *testing.T) {
+	handler := func(path string) string {
+		return "package api\n\n// @route GET " + path + "\nfunc Handler() {}\n"
+	}
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go":                          handler("/users"),
+		"vendor/github.com/lib/handlers.go": handler("/vendored"),
+		"node_modules/pkg/handlers.go":      handler("/node"),
+		"mocks/handlers.go":                 handler("/mocked"),
+		"internal/legacy/handlers.go":       handler("/legacy"),
+		"internal/legacy/keep.txt":          "",
+		"build/handlers.go":                 handler("/built"),
+		".gitignore":                        "# generated\nbuild/\n",
+	})
+
+	parser := NewParser()
+	parser.Exclude = []string{"mocks", "internal/legacy/*.go"}
+	parser.Gitignore = true
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	if len(routes) != 1 || routes[0].Path != "/users" {
+		paths := []string{}
+		for _, route := range routes {
+			paths = append(paths, route.Path)
+		}
+		t.Errorf("route paths = %v, want only /users", paths)
+	}
+}
+
+func TestParseDirectoryDetectsPathParams(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"posts.go": `// @basepath /orgs/{org}
+package api
+
+// @route GET /users/:id/posts/{postId:[0-9]+}
+func GetPost() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	expected := []string{"org", "id", "postId"}
+	if len(routes) != 1 || !reflect.DeepEqual(routes[0].PathParams, expected) {
+		t.Fatalf("expected path params %v, got %+v", expected, routes)
+	}
+}
+
+func TestParseDirectoryInfersWrittenResponses(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+type User struct {
+	Name string ` + "`json:\"name\"`" + `
+}
+
+type Problem struct {
+	Title string ` + "`json:\"title\"`" + `
+}
+
+// @route POST /users
+// @response 400 Problem
+func CreateUser(w http.ResponseWriter, r *http.Request) {
+	user := User{}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user)
+	w.WriteHeader(400)
+	json.NewEncoder(w).Encode(User{})
+}
+
+// @route GET /users/:id
+func GetUser(c *gin.Context) {
+	c.JSON(http.StatusOK, User{})
+	c.JSON(200, Problem{})
+	c.JSON(404, nil)
+}
+`,
+	})
+	captured := captureReport(t)
+
+	parser := NewParser()
+	parser.Infer = true
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	want := map[string][]ResponseSpec{
+		// The annotated 400 overrides the one inferred from the second Encode
+		"CreateUser": {{StatusCode: 400, TypeName: "Problem"}, {StatusCode: 201, TypeName: "User", Inferred: true}},
+		"GetUser":    {{StatusCode: 200, TypeName: "User", Inferred: true}, {StatusCode: 404, Inferred: true}},
+	}
+	for _, route := range routes {
+		got := []ResponseSpec{}
+		for _, response := range route.Responses {
+			got = append(got, ResponseSpec{StatusCode: response.StatusCode, TypeName: response.TypeName, Inferred: response.Inferred})
+		}
+		if !reflect.DeepEqual(got, want[route.Handler]) {
+			t.Errorf("%s responses = %+v, want %+v", route.Handler, got, want[route.Handler])
+		}
+	}
+
+	if len(captured.findings) != 1 || !strings.Contains(captured.findings[0].Message, "Ambiguous inferred response for status 200 in handler GetUser") {
+		t.Errorf("findings = %+v, want the ambiguous 200 of GetUser", captured.findings)
+	}
+}
+
+func TestParseDirectoryInfersAuthFromMiddleware(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"router.go": `package api
+
+func Routes(r chi.Router) {
+	r.Get("/users", auth.Required, ListUsers)
+	r.With(middleware.BasicAuth("admin", creds)).Get("/admin", Admin)
+	r.Get("/login", logging.Trace, Login)
+	r.Get("/keys", auth.Required, ListKeys)
+}
+`,
+		"users.go": `package api
+
+// @route GET /users
+func ListUsers() {}
+
+// @route GET /admin
+func Admin() {}
+
+// @route GET /login
+func Login() {}
+
+// @route GET /keys
+// @auth apikey
+func ListKeys() {}
+`,
+	})
+	captured := captureReport(t)
+
+	parser := NewParser()
+	parser.Infer = true
+	parser.AuthMiddleware["Required"] = "bearer" // mapped by bare name, as -auth-middleware allows
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	want := map[string]string{"ListUsers": "bearer", "Admin": "basic", "Login": "", "ListKeys": "apikey"}
+	for _, route := range routes {
+		if route.Auth != want[route.Handler] {
+			t.Errorf("%s auth = %q, want %q", route.Handler, route.Auth, want[route.Handler])
+		}
+	}
+
+	if len(captured.findings) != 1 || captured.findings[0].Message != "Middleware logging.Trace on handler Login has no auth mapping" {
+		t.Errorf("findings = %+v, want a warning about the unmapped logging.Trace", captured.findings)
+	}
+}
+
+func TestParseDirectoryKeepsMiddlewareOfSameNamedHandlersApart(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"router.go": `package main
+
+import (
+	"example.com/app/admin"
+	"example.com/app/public"
+)
+
+func Routes(r chi.Router, users *admin.UserHandler) {
+	r.Get("/admin/users", auth.Required, admin.ListUsers)
+	r.Get("/users", public.ListUsers)
+	r.Post("/admin/users", auth.Required, users.Create)
+
+	orders := public.NewOrderHandler()
+	r.Post("/orders", orders.Create)
+}
+`,
+		"admin/users.go": `package admin
+
+type UserHandler struct{}
+
+// @route GET /admin/users
+func ListUsers() {}
+
+// @route POST /admin/users
+func (h *UserHandler) Create() {}
+`,
+		"public/users.go": `package public
+
+type OrderHandler struct{}
+
+// @route GET /users
+func ListUsers() {}
+
+// @route POST /orders
+func (h *OrderHandler) Create() {}
+`,
+	})
+
+	parser := NewParser()
+	parser.Infer = true
+	parser.AuthMiddleware["auth.Required"] = "bearer"
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	want := map[string]string{"/admin/users": "bearer", "/users": "", "/orders": ""}
+	for _, route := range routes {
+		if route.Auth != want[route.Path] {
+			t.Errorf("%s %s auth = %q, want %q", route.Method, route.Path, route.Auth, want[route.Path])
+		}
+	}
+}
+
+func TestParseDirectoryInfersReturnedResponse(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+type User struct {
+	Name string ` + "`json:\"name\"`" + `
+}
+
+// @route GET /users/:id
+func GetUser() (*User, error) {
+	return nil, nil
+}
+
+// @route GET /users/me
+func GetMe() (any, error) {
+	user := User{}
+	return user, nil
+}
+`,
+	})
+
+	parser := NewParser()
+	parser.Infer = true
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	for _, route := range routes {
+		if len(route.Responses) != 1 {
+			t.Fatalf("%s responses = %+v, want one", route.Handler, route.Responses)
+		}
+		response := route.Responses[0]
+		if response.StatusCode != 200 || response.TypeName != "User" || response.Body == nil {
+			t.Errorf("%s response = %+v, want a resolved 200 User", route.Handler, response)
+		}
+	}
+}
+
+func TestParseDirectoryComposesRouteGroups(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"router.go": `package api
+
+const APIPrefix = "/api"
+
+func Routes(r *gin.Engine) {
+	api := r.Group(APIPrefix)
+	v1 := api.Group("/v1/")
+	admin := v1.Group("/admin", auth.Required)
+
+	v1.GET("/users", ListUsers)
+	admin.DELETE("/users/:id", DeleteUser)
+	r.Group("/internal").GET("/health", Health)
+	v1.GET("/users/:id", GetUser)
+}
+`,
+		"users.go": `package api
+
+// @route GET /users
+func ListUsers() {}
+
+// @route DELETE /users/:id
+func DeleteUser() {}
+
+// @route GET /health
+func Health() {}
+
+// @route GET /api/v1/users/:id
+func GetUser() {}
+`,
+	})
+
+	parser := NewParser()
+	parser.Infer = true
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	want := map[string]string{
+		"ListUsers":  "/api/v1/users",
+		"DeleteUser": "/api/v1/admin/users/:id",
+		"Health":     "/internal/health",
+		"GetUser":    "/api/v1/users/:id", // already includes the group prefix
+	}
+	for _, route := range routes {
+		if route.Path != want[route.Handler] {
+			t.Errorf("%s path = %q, want %q", route.Handler, route.Path, want[route.Handler])
+		}
+	}
+}
+
+func TestParseDirectoryPrefixesEachGroupRegistration(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"router.go": `package api
+
+func Routes(r *gin.Engine) {
+	v1 := r.Group("/v1")
+	v2 := r.Group("/v2")
+
+	v1.GET("/users", ListUsers)
+	v2.GET("/users", ListUsers)
+	r.GET("/health", Health)
+	v1.GET("/health", Health)
+	v1.GET("/users/:id", GetUser)
+	v2.GET("/users/:id", GetUser)
+}
+`,
+		"users.go": `package api
+
+func ListUsers(c *gin.Context) {}
+
+func Health(c *gin.Context) {}
+
+// @route GET /users/:id
+func GetUser(c *gin.Context) {}
+`,
+	})
+
+	parser := NewParser()
+	parser.Framework = "gin"
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	got := []string{}
+	for _, route := range routes {
+		got = append(got, route.Handler+" "+route.Path)
+	}
+	sort.Strings(got)
+	want := []string{"GetUser /v1/users/:id", "GetUser /v2/users/:id", "Health /health", "Health /v1/health", "ListUsers /v1/users", "ListUsers /v2/users"}
+	if !slices.Equal(got, want) {
+		t.Errorf("routes = %q, want %q", got, want)
+	}
+}
+
+func TestParseDirectoryKeepsGroupsOfSameNamedHandlersApart(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"router.go": `package main
+
+import (
+	"example.com/app/admin"
+	"example.com/app/public"
+)
+
+func Routes(r *gin.Engine, users *admin.UserHandler) {
+	r.Group("/admin").GET("/users", admin.ListUsers)
+	r.Group("/public").GET("/users", public.ListUsers)
+	r.Group("/admin").POST("/users", users.Create)
+
+	orders := public.NewOrderHandler()
+	r.Group("/shop").POST("/orders", orders.Create)
+}
+`,
+		"admin/users.go": `package admin
+
+type UserHandler struct{}
+
+// @route GET /users
+func ListUsers() {}
+
+// @route POST /users
+func (h *UserHandler) Create() {}
+`,
+		"public/users.go": `package public
+
+type OrderHandler struct{}
+
+// @route GET /users
+func ListUsers() {}
+
+// @route POST /orders
+func (h *OrderHandler) Create() {}
+`,
+	})
+
+	parser := NewParser()
+	parser.Infer = true
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	got := []string{}
+	for _, route := range routes {
+		got = append(got, fmt.Sprintf("%s %s %s", route.Handler, route.Method, route.Path))
+	}
+	sort.Strings(got)
+	want := []string{"Create POST /admin/users", "Create POST /shop/orders", "ListUsers GET /admin/users", "ListUsers GET /public/users"}
+	if !slices.Equal(got, want) {
+		t.Errorf("routes = %q, want %q", got, want)
+	}
+}
+
+func TestParseDirectoryReadsRouteTables(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"router.go": `package api
+
+import "net/http"
+
+const UsersPath = "/users"
+
+var routes = []Route{
+	{Method: http.MethodGet, Path: UsersPath, Handler: ListUsers},
+	{Method: "post", Path: UsersPath, Handler: CreateUser},
+	{Method: "DELETE", Pattern: "/users/:id", HandlerFunc: handlers.DeleteUser},
+}
+`,
+		"users.go": `package api
+
+func ListUsers(w http.ResponseWriter, r *http.Request) {}
+
+// @name Create User
+// @description Creates a user
+func CreateUser(w http.ResponseWriter, r *http.Request) {}
+`,
+	})
+
+	parser := NewParser()
+	parser.Infer = true
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	want := map[string]Route{
+		"ListUsers":  {Name: "ListUsers", Method: "GET", Path: "/users"},
+		"CreateUser": {Name: "Create User", Method: "POST", Path: "/users", Description: "Creates a user"},
+		"DeleteUser": {Name: "DeleteUser", Method: "DELETE", Path: "/users/:id"},
+	}
+	if len(routes) != len(want) {
+		t.Fatalf("got %d routes, want %d", len(routes), len(want))
+	}
+	for _, route := range routes {
+		expected := want[route.Handler]
+		if route.Name != expected.Name || route.Method != expected.Method || route.Path != expected.Path || strings.TrimSpace(route.Description) != expected.Description {
+			t.Errorf("%s = %+v, want %+v", route.Handler, route, expected)
+		}
+	}
+}
+
+func TestParseDirectoryDiscoversGinRegistrations(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"router.go": `package api
+
+import "net/http"
+
+const UserPath = "/users/:id"
+
+func Routes(r *gin.Engine) {
+	v1 := r.Group("/v1")
+	v1.GET("/users", ListUsers)
+	v1.POST("/users", auth.Required, h.CreateUser)
+	r.Handle(http.MethodDelete, UserPath, DeleteUser)
+	r.GET("/health", Health)
+}
+`,
+		"handlers.go": `package api
+
+func ListUsers(c *gin.Context) {}
+
+// @name Create User
+func (h *Handler) CreateUser(c *gin.Context) {}
+
+func DeleteUser(c *gin.Context) {}
+
+// @route GET /healthz
+func Health(c *gin.Context) {}
+`,
+	})
+
+	parser := NewParser()
+	parser.Framework = "gin"
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	want := map[string]Route{
+		"ListUsers":  {Name: "ListUsers", Method: "GET", Path: "/v1/users"},
+		"CreateUser": {Name: "Create User", Method: "POST", Path: "/v1/users"},
+		"DeleteUser": {Name: "DeleteUser", Method: "DELETE", Path: "/users/:id"},
+		"Health":     {Method: "GET", Path: "/healthz"}, // the @route wins
+	}
+	if len(routes) != len(want) {
+		t.Fatalf("got %d routes, want %d", len(routes), len(want))
+	}
+	for _, route := range routes {
+		expected := want[route.Handler]
+		if route.Name != expected.Name || route.Method != expected.Method || route.Path != expected.Path {
+			t.Errorf("%s = %+v, want %+v", route.Handler, route, expected)
+		}
+	}
+}
+
+func TestParseDirectoryCollapsesDuplicateRoutes(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"router.go": `package api
+
+func Routes(r *gin.Engine) {
+	r.Group("/v1").GET("/users", ListUsers)
+	r.Group("/v1").GET("/users", ListUsers)
+	r.Group("/v2").GET("/users", ListUsers)
+}
+`,
+		"handlers.go": `package api
+
+func ListUsers(c *gin.Context) {}
+`,
+	})
+
+	parser := NewParser()
+	parser.Framework = "gin"
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+	// Only the repeated registration collapses, the other sub-router keeps its route
+	if len(routes) != 2 || routes[0].Path != "/v1/users" || routes[1].Path != "/v2/users" {
+		t.Fatalf("routes = %+v, want GET /v1/users and GET /v2/users", routes)
+	}
+
+	parser.routes = []*Route{
+		{Method: "GET", Path: "/users", Handler: "ListUsers"},
+		{Method: "POST", Path: "/users", Handler: "CreateUser"},
+		{Method: "get", Path: "/users", Handler: "ListUsers", Description: "Lists users"},
+	}
+	parser.collapseDuplicateRoutes()
+	if len(parser.routes) != 2 || parser.routes[0].Description != "Lists users" {
+		t.Errorf("routes = %+v, want the described ListUsers first", parser.routes)
+	}
+}
+
+func TestParseDirectoryDiscoversChiRegistrations(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"router.go": `package api
+
+import "net/http"
+
+func Routes(r chi.Router) {
+	r.Get("/users/{id}", GetUser)
+	r.Method(http.MethodPost, "/users", http.HandlerFunc(CreateUser))
+	r.MethodFunc("DELETE", "/users/{id:[0-9]+}", external.DeleteUser)
+}
+`,
+		"handlers.go": `package api
+
+// @description Fetches a user
+func GetUser(w http.ResponseWriter, r *http.Request) {}
+
+func CreateUser(w http.ResponseWriter, r *http.Request) {}
+`,
+	})
+
+	parser := NewParser()
+	parser.Framework = "chi"
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	want := map[string]Route{
+		"GetUser":    {Method: "GET", Path: "/users/{id}", PathParams: []string{"id"}, Description: "Fetches a user"},
+		"CreateUser": {Method: "POST", Path: "/users", PathParams: []string{}},
+		"DeleteUser": {Method: "DELETE", Path: "/users/{id:[0-9]+}", PathParams: []string{"id"}}, // not declared here
+	}
+	if len(routes) != len(want) {
+		t.Fatalf("got %d routes, want %d", len(routes), len(want))
+	}
+	for _, route := range routes {
+		expected := want[route.Handler]
+		if route.Method != expected.Method || route.Path != expected.Path || !reflect.DeepEqual(route.PathParams, expected.PathParams) || strings.TrimSpace(route.Description) != expected.Description {
+			t.Errorf("%s = %+v, want %+v", route.Handler, route, expected)
+		}
+	}
+}
+
+func TestParseDirectoryDiscoversEchoRegistrations(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"router.go": `package api
+
+import "net/http"
+
+func Routes(e *echo.Echo, orders *OrderHandler) {
+	users := handlers.NewUserHandler(nil)
+	e.POST("/users", users.Create)
+	e.POST("/orders", orders.Create)
+	e.Add(http.MethodPut, "/users/:id", users.Update)
+}
+`,
+		"handlers.go": `package api
+
+type UserHandler struct{}
+
+// @description Creates a user
+func (h *UserHandler) Create(c echo.Context) error { return nil }
+
+// @description Replaces a user
+func (h *UserHandler) Update(c echo.Context) error { return nil }
+
+type OrderHandler struct{}
+
+// @description Places an order
+func (h OrderHandler) Create(c echo.Context) error { return nil }
+`,
+	})
+
+	parser := NewParser()
+	parser.Framework = "echo"
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	want := []Route{
+		{Method: "POST", Path: "/users", PathParams: []string{}, Description: "Creates a user"},
+		{Method: "POST", Path: "/orders", PathParams: []string{}, Description: "Places an order"},
+		{Method: "PUT", Path: "/users/:id", PathParams: []string{"id"}, Description: "Replaces a user"},
+	}
+	if len(routes) != len(want) {
+		t.Fatalf("got %d routes, want %d", len(routes), len(want))
+	}
+	for i, route := range routes {
+		expected := want[i]
+		if route.Method != expected.Method || route.Path != expected.Path || !reflect.DeepEqual(route.PathParams, expected.PathParams) || route.Description != expected.Description {
+			t.Errorf("route %d = %+v, want %+v", i, route, expected)
+		}
+	}
+}
+
+func TestParseDirectoryDiscoversServeMuxPatterns(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"router.go": `package api
+
+import "net/http"
+
+const ItemPattern = "GET /items/{id}"
+
+func Routes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /items", CreateItem)
+	mux.HandleFunc(ItemPattern, GetItem)
+	mux.Handle("GET api.example.com/files/{path...}", http.HandlerFunc(GetFile))
+	mux.HandleFunc("/health", Health)
+}
+`,
+		"handlers.go": `package api
+
+// @description Creates an item
+// @body CreateItemRequest
+func CreateItem(w http.ResponseWriter, r *http.Request) {}
+
+type CreateItemRequest struct {
+	Name string ` + "`json:\"name\"`" + `
+}
+
+func GetItem(w http.ResponseWriter, r *http.Request) {}
+
+func GetFile(w http.ResponseWriter, r *http.Request) {}
+
+func Health(w http.ResponseWriter, r *http.Request) {}
+`,
+	})
+
+	parser := NewParser()
+	parser.Framework = "stdlib"
+	routes, err := parser.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	want := map[string]Route{
+		"CreateItem": {Method: "POST", Path: "/items", PathParams: []string{}, Description: "Creates an item"},
+		"GetItem":    {Method: "GET", Path: "/items/{id}", PathParams: []string{"id"}},
+		"GetFile":    {Method: "GET", Path: "/files/{path}", PathParams: []string{"path"}},
+	}
+	if len(routes) != len(want) {
+		t.Fatalf("got %d routes, want %d (patterns without a method are skipped)", len(routes), len(want))
+	}
+	for _, route := range routes {
+		expected := want[route.Handler]
+		if route.Method != expected.Method || route.Path != expected.Path || !reflect.DeepEqual(route.PathParams, expected.PathParams) || route.Description != expected.Description {
+			t.Errorf("%s = %+v, want %+v", route.Handler, route, expected)
+		}
+		if route.Handler == "CreateItem" && route.RequestBody == nil {
+			t.Error("CreateItem body was not resolved")
+		}
+	}
+}
+
+func TestParseDirectoryPrefersStructsFromHandlerPackage(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"billing/types.go": `package billing
+
+type CreateRequest struct {
+	Amount int ` + "`json:\"amount\"`" + `
+}
+
+type Address struct {
+	Street string ` + "`json:\"street\"`" + `
+}
+`,
+		"users/handlers.go": `package users
+
+type CreateRequest struct {
+	Name    string          ` + "`json:\"name\"`" + `
+	Billing billing.Address ` + "`json:\"billing\"`" + `
+}
+
+// @route POST /users
+// @body CreateRequest
+func CreateUser() {}
+
+// @route POST /invoices
+// @body billing.CreateRequest
+func CreateInvoice() {}
+`,
+	})
+
+	routes, err := NewParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	fieldNames := func(body *RequestBody) []string {
+		names := []string{}
+		for _, field := range body.Fields {
+			names = append(names, field.JSONName)
+		}
+		return names
+	}
+
+	for _, route := range routes {
+		if route.RequestBody == nil {
+			t.Fatalf("%s has no request body", route.Handler)
+		}
+
+		switch route.Handler {
+		case "CreateUser":
+			if got := fieldNames(route.RequestBody); !reflect.DeepEqual(got, []string{"name", "billing"}) {
+				t.Errorf("CreateUser body fields = %v, want the users.CreateRequest fields", got)
+			}
+			if nested := route.RequestBody.Fields[1].Nested; nested == nil || nested.TypeName != "Address" {
+				t.Errorf("CreateUser billing field should resolve billing.Address, got %+v", nested)
+			}
+		case "CreateInvoice":
+			if got := fieldNames(route.RequestBody); !reflect.DeepEqual(got, []string{"amount"}) {
+				t.Errorf("CreateInvoice body fields = %v, want the billing.CreateRequest fields", got)
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+// gzipMagic starts every pprof profile, which is gzip-compressed protobuf
+var gzipMagic = []byte{0x1f, 0x8b}
+
+func TestProfilesAreWritten(t *testing.T) {
+	dir := t.TempDir()
+	cpuPath := filepath.Join(dir, "cpu.pprof")
+	memPath := filepath.Join(dir, "mem.pprof")
+
+	stop, err := startCPUProfile(cpuPath)
+	if err != nil {
+		t.Fatalf("startCPUProfile() error = %v", err)
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("stopping the CPU profile: %v", err)
+	}
+	if err := writeMemProfile(memPath); err != nil {
+		t.Fatalf("writeMemProfile() error = %v", err)
+	}
+
+	for _, path := range []string{cpuPath, memPath} {
+		if profile := readFile(t, path); !bytes.HasPrefix([]byte(profile), gzipMagic) {
+			t.Errorf("%s is not a pprof profile: %q", filepath.Base(path), profile)
+		}
+	}
+}
+
+func TestProfilesReportUnwritablePaths(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "profile.pprof")
+	if _, err := startCPUProfile(path); err == nil {
+		t.Errorf("startCPUProfile() into a missing directory should fail")
+	}
+	if err := writeMemProfile(path); err == nil {
+		t.Errorf("writeMemProfile() into a missing directory should fail")
+	}
+}
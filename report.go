@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type ReportDocument struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Findings      []ReportFinding `json:"findings"`
+}
+
+type ReportFinding struct {
+	Category string `json:"category"`
+	Level    string `json:"level"`
+	Message  string `json:"message"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Handler  string `json:"handler,omitempty"`
+}
+
+const ReportSchemaVersion = 1
+
+// warningReporter collects every warning and error logged during a run
+type warningReporter struct {
+	mu       sync.Mutex
+	findings []ReportFinding
+}
+
+// reportHandler records warnings and errors before passing records on to the wrapped handler
+type reportHandler struct {
+	slog.Handler
+	reporter *warningReporter
+	attrs    []slog.Attr
+}
+
+func (h *reportHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn || h.Handler.Enabled(ctx, level)
+}
+
+func (h *reportHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelWarn {
+		h.reporter.record(record, h.attrs)
+	}
+	if !h.Handler.Enabled(ctx, record.Level) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *reportHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &reportHandler{
+		Handler:  h.Handler.WithAttrs(attrs),
+		reporter: h.reporter,
+		attrs:    append(append([]slog.Attr{}, h.attrs...), attrs...),
+	}
+}
+
+func (h *reportHandler) WithGroup(name string) slog.Handler {
+	return &reportHandler{Handler: h.Handler.WithGroup(name), reporter: h.reporter, attrs: h.attrs}
+}
+
+// record turns a log record into a finding. The category, handler, file and line come from
+// attributes of the same name, or from a file:line:column position attribute.
+func (r *warningReporter) record(record slog.Record, attrs []slog.Attr) {
+	finding := ReportFinding{
+		Level:   strings.ToLower(record.Level.String()),
+		Message: record.Message,
+	}
+
+	apply := func(attr slog.Attr) bool {
+		switch attr.Key {
+		case "category":
+			finding.Category = attr.Value.String()
+		case "handler":
+			finding.Handler = attr.Value.String()
+		case "file":
+			finding.File = attr.Value.String()
+		case "line":
+			finding.Line = int(attr.Value.Int64())
+		case "position":
+			file, line := splitPosition(attr.Value.String())
+			finding.File, finding.Line = file, line
+		}
+		return true
+	}
+	for _, attr := range attrs {
+		apply(attr)
+	}
+	record.Attrs(apply)
+
+	if finding.Category == "" {
+		finding.Category = "general"
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.findings = append(r.findings, finding)
+}
+
+// hasErrors reports whether any error was recorded
+func (r *warningReporter) hasErrors() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, finding := range r.findings {
+		if finding.Level == "error" {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteReport writes the recorded findings as JSON to path
+func (r *warningReporter) WriteReport(path string) error {
+	r.mu.Lock()
+	doc := ReportDocument{
+		SchemaVersion: ReportSchemaVersion,
+		Findings:      append([]ReportFinding{}, r.findings...),
+	}
+	r.mu.Unlock()
+
+	jsonBytes, err := json.MarshalIndent(doc, "", JSONOutputIndent)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, jsonBytes, 0644)
+}
+
+// splitPosition splits a token.Position string (file:line:column) into file and line
+func splitPosition(position string) (string, int) {
+	parts := strings.Split(position, ":")
+	if len(parts) < 3 {
+		return position, 0
+	}
+
+	line, err := strconv.Atoi(parts[len(parts)-2])
+	if err != nil {
+		return position, 0
+	}
+	return strings.Join(parts[:len(parts)-2], ":"), line
+}
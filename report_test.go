@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+)
+
+// captureReport routes the logs of a test through a fresh reporter, as -report does for a run
+func captureReport(t *testing.T) *warningReporter {
+	t.Helper()
+	captured := &warningReporter{}
+	previous := defaultLogger
+	defaultLogger = slog.New(&reportHandler{Handler: slog.NewTextHandler(io.Discard, nil), reporter: captured})
+	t.Cleanup(func() { defaultLogger = previous })
+	return captured
+}
+
+func TestWriteReportRecordsParseWarnings(t *testing.T) {
+	dir := writeSourceFiles(t, map[string]string{
+		"users.go": `package api
+
+// @route POST /users
+// @body CreateUserRequest
+func CreateUser() {}
+`,
+	})
+	captured := captureReport(t)
+
+	if _, err := NewParser().ParseDirectory(dir); err != nil {
+		t.Fatalf("ParseDirectory() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "reports", "warnings.json")
+	if err := captured.WriteReport(path); err != nil {
+		t.Fatalf("WriteReport() error = %v", err)
+	}
+
+	var doc ReportDocument
+	if err := json.Unmarshal([]byte(readFile(t, path)), &doc); err != nil {
+		t.Fatalf("report is not JSON: %v", err)
+	}
+	if doc.SchemaVersion != ReportSchemaVersion {
+		t.Errorf("schemaVersion = %d, want %d", doc.SchemaVersion, ReportSchemaVersion)
+	}
+
+	want := ReportFinding{
+		Category: "missing-body",
+		Level:    "warn",
+		Message:  "Could not find body struct CreateUserRequest for handler CreateUser, generating it without a body",
+		File:     filepath.Join(dir, "users.go"),
+		Line:     5,
+		Handler:  "CreateUser",
+	}
+	for _, finding := range doc.Findings {
+		if finding == want {
+			return
+		}
+	}
+	t.Errorf("findings = %+v, want one %+v", doc.Findings, want)
+}
+
+func TestReportHandlerReadsPositions(t *testing.T) {
+	captured := captureReport(t)
+	getLogger().Info("Processing handler")
+	getLogger().Error("Could not read route table entry", "position", "/src/api/router.go:12:3")
+
+	if len(captured.findings) != 1 || !captured.hasErrors() {
+		t.Fatalf("findings = %+v, want only the error", captured.findings)
+	}
+	finding := captured.findings[0]
+	if finding.Category != "general" || finding.File != "/src/api/router.go" || finding.Line != 12 {
+		t.Errorf("finding = %+v, want a general finding at /src/api/router.go:12", finding)
+	}
+}
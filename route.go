@@ -1,14 +1,92 @@
 package main
 
 type Route struct {
-	Name        string            // Name annotation
-	Method      string            // HTTP method (GET, POST, etc.)
-	Path        string            // URL path pattern
-	Handler     string            // Name of the handler function
-	Description string            // Description from comments
-	BodyType    string            // Name of struct to use for body
-	Tags        map[string]string // Any route tags
-	RequestBody *RequestBody      // Request body information
+	Name          string            // Name annotation
+	Method        string            // HTTP method (GET, POST, etc.)
+	Path          string            // URL path pattern
+	PathParams    []string          // Names of the path parameters in Path, e.g. id for /users/:id
+	Handler       string            // Name of the handler function
+	Receiver      string            // Receiver type of the handler when it is a method
+	FileName      string            // Request file name when it can't be derived from Name, e.g. for handlers with several routes
+	SourceFile    string            // File the handler is declared in
+	SourceLine    int               // Line of the handler declaration
+	SourceSnippet string            // Handler source for the docs, captured with -docs-source
+	Description   string            // Description from comments
+	BodyType      string            // Name of struct to use for body
+	ContentType   string            // Body content type from @bodytype (json, xml, form)
+	GraphQLQuery  string            // Query from @graphql-query, sent by @bodytype graphql routes
+	Tags          []string          // Folders the route is grouped under, the first is primary
+	Labels        map[string]string // Key/value tags from @tag key value, which don't make folders
+	Deprecated    bool              // Set by @deprecated
+	Deprecation   string            // Text following @deprecated, e.g. what to use instead
+	Auth          string            // Auth mode (none, bearer, basic, apikey)
+	AuthOptions   AuthOptions       // Extra settings for the auth mode
+	Scopes        []string          // OAuth scopes required by the route, from @scope
+	RateLimit     string            // Rate limit from @ratelimit, e.g. 100/minute per token
+	QueryParams   []QueryParam      // Query parameters from @query
+	Headers       []RequestHeader   // Request headers from @header, in annotation order
+	RequestBody   *RequestBody      // Request body information
+	URIType       string            // Struct path parameters are bound to (ShouldBindUri), when inferred
+	URIBody       *RequestBody      // Resolved definition of URIType
+	Responses     []ResponseSpec    // Documented or inferred responses
+	GRPC          *GRPCMethod       // Set for gRPC methods annotated with @grpc
+	Settings      map[string]string // Per-request client settings (followRedirects, timeout, etc.)
+	BasePath      string            // Prefix from @basepath, already applied to Path
+	Bulk          *BulkConfig       // Set for bulk endpoints annotated with @bulk
+}
+
+// GRPCRouteMethod stands in for the HTTP method of gRPC routes.
+const GRPCRouteMethod = "GRPC"
+
+type GRPCMethod struct {
+	Service string // Fully qualified proto service, e.g. helloworld.Greeter
+	Method  string // RPC method name, e.g. SayHello
+}
+
+// FullMethod returns the gRPC method path, e.g. /helloworld.Greeter/SayHello
+func (m *GRPCMethod) FullMethod() string {
+	return "/" + m.Service + "/" + m.Method
+}
+
+type AuthOptions struct {
+	In     string // Where an api key is sent (header or query)
+	Key    string // Header or query parameter name carrying the api key
+	Header string // Header carrying a bearer token, defaults to Authorization
+	Prefix string // Scheme written before a bearer token, defaults to Bearer
+
+	Grant       string // OAuth2 grant type (authorization_code, client_credentials)
+	TokenURL    string // OAuth2 access token URL
+	AuthURL     string // OAuth2 authorization URL, for the authorization code grant
+	CallbackURL string // OAuth2 redirect URL, for the authorization code grant
+}
+
+type QueryParam struct {
+	Name        string
+	Type        string // Go type of the parameter, used for its example value
+	Description string
+	Required    bool // Required parameters are enabled in the request, optional ones disabled
+}
+
+type RequestHeader struct {
+	Name  string
+	Value string // Default value, empty when the annotation doesn't give one
+}
+
+type CollectionVariable struct {
+	Name   string
+	Value  string // Empty for secrets, which only get a placeholder
+	Secret bool   // Declared with @var secret, kept out of the generated files
+}
+
+type BulkConfig struct {
+	ItemCount int // Number of example items rendered in the body's top-level arrays
+}
+
+type ResponseSpec struct {
+	StatusCode int          // HTTP status code
+	TypeName   string       // Name of struct returned in the body, if any
+	Body       *RequestBody // Resolved response body information
+	Inferred   bool         // Whether this was inferred from the handler body
 }
 
 type RequestBody struct {
@@ -18,10 +96,37 @@ type RequestBody struct {
 }
 
 type RequestBodyField struct {
-	Name        string
-	Type        string
-	JSONName    string
-	Required    bool
-	Description string
-	Tags        map[string]string
+	Name         string
+	Type         string
+	JSONName     string
+	FormName     string // Name from the form tag, used for form and multipart bodies
+	XMLName      string // Element or attribute name from the xml tag, empty to use the Go name
+	XMLAttr      bool   // xml:",attr", rendered as an attribute of the parent element
+	XMLCharData  bool   // xml:",chardata", rendered as the text of the parent element
+	Required     bool
+	OmitEmpty    bool // json:",omitempty", the field may be left out of the body
+	Pointer      bool // Declared as a pointer, so the field may be null
+	Description  string
+	Example      string // Value from the example tag, used in example bodies instead of the default
+	Tags         map[string]string
+	When         *FieldCondition  // Set when the field only applies for certain values of another field
+	Validation   *FieldValidation // Constraints from the validate and binding tags
+	Inline       bool             // json:",inline", the field's entries are flattened into the parent
+	Embedded     bool             // Declared as an embedded field, named after its type
+	ElemType     string           // Element type for array and slice fields
+	MapKeyType   string           // Key type for map fields
+	MapValueType string           // Value type for map fields
+	Nested       *RequestBody     // Resolved definition when the field (or its array element or map value) is a local struct
+}
+
+type FieldValidation struct {
+	Format string   // Format rule such as email, url or uuid
+	Min    string   // Lower bound from min or gte
+	Max    string   // Upper bound from max or lte
+	OneOf  []string // Allowed values from oneof
+}
+
+type FieldCondition struct {
+	Field string // JSON name of the field the condition depends on
+	Value string // Value that field must hold for this one to apply
 }
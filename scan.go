@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultExcludes are the directories never scanned for handlers: dependencies and test data
+var DefaultExcludes = []string{"vendor", "node_modules", "testdata"}
+
+// scanExcludes returns the patterns of the paths ParseDirectory skips in dirPath: the
+// defaults, Exclude and, with Gitignore set, the entries of the directory's .gitignore
+func (p *Parser) scanExcludes(dirPath string) ([]string, error) {
+	patterns := append(append([]string{}, DefaultExcludes...), p.Exclude...)
+	if !p.Gitignore {
+		return patterns, nil
+	}
+
+	ignored, err := readGitignore(filepath.Join(dirPath, ".gitignore"))
+	if err != nil {
+		return nil, err
+	}
+	return append(patterns, ignored...), nil
+}
+
+// readGitignore reads the patterns of a .gitignore file. Negations (!pattern) aren't
+// supported and are left out, so the paths they re-include stay ignored.
+func readGitignore(filePath string) ([]string, error) {
+	file, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	patterns := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
+
+// skipExcluded tells a filepath.Walk callback whether to skip a path under root that
+// matches the patterns, along with the error to return for it: SkipDir for directories
+func skipExcluded(root, filePath string, info os.FileInfo, patterns []string) (bool, error) {
+	rel, err := filepath.Rel(root, filePath)
+	if err != nil || rel == "." || !isExcluded(filepath.ToSlash(rel), patterns) {
+		return false, nil
+	}
+	if info.IsDir() {
+		return true, filepath.SkipDir
+	}
+	return true, nil
+}
+
+// isExcluded reports whether a slash-separated path, relative to the scanned directory,
+// matches one of the glob patterns. Patterns holding a slash match the whole path, e.g.
+// internal/legacy/*.go, others match the file or directory name, e.g. *_gen.go or mocks.
+func isExcluded(rel string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(pattern, "/")
+
+		target := path.Base(rel)
+		if strings.Contains(pattern, "/") {
+			pattern, target = strings.TrimPrefix(pattern, "/"), rel
+		}
+		if matched, _ := path.Match(pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Build metadata, injected at build time, e.g.
+//
+//	go build -ldflags "-X main.Version=v1.4.0 -X main.Commit=$(git rev-parse --short HEAD) -X main.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// versionInfo describes the build, as printed by -version
+func versionInfo() string {
+	return fmt.Sprintf("brungo %s (commit %s, built %s)", Version, Commit, BuildDate)
+}
+
+// generatedHeader is the comment heading every generated .bru file. It names the brungo version
+// that wrote it and, for request files, the handler the request comes from, and says what happens
+// to hand edits. keptBlocks is nil for files that are overwritten whole, otherwise the generated
+// blocks a re-run keeps as edited, on top of the blocks added in Bruno. Bruno ignores comments
+// outside blocks.
+func generatedHeader(route *Route, keptBlocks []string) string {
+	header := fmt.Sprintf("# Generated by brungo %s\n", Version)
+	if route != nil && route.Handler != "" {
+		source := route.Handler
+		if route.SourceFile != "" {
+			source += " in " + filepath.ToSlash(route.SourceFile)
+		}
+		header += fmt.Sprintf("# Handler: %s\n", source)
+	}
+	if keptBlocks == nil {
+		return header + "# Do not edit, changes are overwritten when brungo runs again\n"
+	}
+
+	kept := "blocks added in Bruno"
+	if n := len(keptBlocks); n > 0 {
+		names := keptBlocks[n-1]
+		if n > 1 {
+			names = strings.Join(keptBlocks[:n-1], ", ") + " and " + names
+		}
+		kept = fmt.Sprintf("the %s blocks and to %s", names, kept)
+	}
+	return header + fmt.Sprintf("# Edits to %s are kept when brungo runs again, the rest is regenerated\n", kept)
+}
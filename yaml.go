@@ -0,0 +1,588 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yamlNode is a decoded JSON value that keeps the order of object keys
+type yamlNode struct {
+	keys   []string    // Object keys, in document order
+	values []*yamlNode // Object values or array elements
+	array  bool
+	scalar string // JSON text of a string, number, bool or null; empty for objects and arrays
+}
+
+// plainYAMLKey matches keys that can be written without quotes
+var plainYAMLKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// jsonToYAML rewrites a JSON document as block-style YAML, keeping the key order. Strings
+// stay double-quoted, since JSON's escapes are also valid in YAML.
+func jsonToYAML(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	root, err := decodeYAMLNode(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	var builder strings.Builder
+	writeYAMLNode(&builder, root, 0)
+	return []byte(builder.String()), nil
+}
+
+// decodeYAMLNode reads the next JSON value from the decoder
+func decodeYAMLNode(decoder *json.Decoder) (*yamlNode, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch value := token.(type) {
+	case json.Delim:
+		node := &yamlNode{array: value == '['}
+		for decoder.More() {
+			if !node.array {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, keyToken.(string))
+			}
+			child, err := decodeYAMLNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.values = append(node.values, child)
+		}
+		// Consume the closing delimiter
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		text, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return &yamlNode{scalar: string(text)}, nil
+	case nil:
+		return &yamlNode{scalar: "null"}, nil
+	default:
+		return &yamlNode{scalar: fmt.Sprint(value)}, nil
+	}
+}
+
+// writeYAMLNode writes an object or array with its entries indented by depth levels
+func writeYAMLNode(builder *strings.Builder, node *yamlNode, depth int) {
+	indent := strings.Repeat(JSONOutputIndent, depth)
+
+	for i, child := range node.values {
+		prefix := indent + "- "
+		if !node.array {
+			prefix = indent + yamlKey(node.keys[i]) + ":"
+		}
+
+		switch {
+		case child.scalar != "":
+			builder.WriteString(fmt.Sprintf("%s %s\n", strings.TrimRight(prefix, " "), child.scalar))
+		case len(child.values) == 0 && child.array:
+			builder.WriteString(strings.TrimRight(prefix, " ") + " []\n")
+		case len(child.values) == 0:
+			builder.WriteString(strings.TrimRight(prefix, " ") + " {}\n")
+		case node.array:
+			// Nested entries start on the dash's line, e.g. "- name: id"
+			var nested strings.Builder
+			writeYAMLNode(&nested, child, depth+1)
+			builder.WriteString(prefix + strings.TrimPrefix(nested.String(), indent+JSONOutputIndent))
+		default:
+			builder.WriteString(prefix + "\n")
+			writeYAMLNode(builder, child, depth+1)
+		}
+	}
+}
+
+// yamlKey quotes keys that aren't plain words, such as paths and status codes, and words
+// YAML would read as a bool or null
+func yamlKey(key string) string {
+	switch strings.ToLower(key) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null":
+	default:
+		if plainYAMLKey.MatchString(key) {
+			return key
+		}
+	}
+	quoted, _ := json.Marshal(key)
+	return string(quoted)
+}
+
+// yamlLine is a non-blank line of a YAML document with its comment removed
+type yamlLine struct {
+	number int // Index into the raw lines
+	indent int
+	text   string
+}
+
+// yamlParser reads the block structure of a YAML document line by line. It covers what
+// OpenAPI specs use: block and flow collections, plain and quoted scalars, block scalars and
+// comments. Anchors, aliases, tags and multiple documents aren't supported.
+type yamlParser struct {
+	raw   []string // Every line, for block scalars that keep their blank lines
+	lines []yamlLine
+	pos   int
+}
+
+// yamlToJSON converts a YAML document to JSON, so it can be decoded like a JSON one
+func yamlToJSON(data []byte) ([]byte, error) {
+	p := &yamlParser{raw: strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")}
+	for i, line := range p.raw {
+		text := stripYAMLComment(line)
+		if text == "..." {
+			break // End of the document
+		}
+
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || strings.HasPrefix(text, "%") {
+			continue
+		}
+		if text == "---" || strings.HasPrefix(text, "--- ") {
+			if len(p.lines) > 0 {
+				return nil, fmt.Errorf("line %d: multiple YAML documents aren't supported", i+1)
+			}
+			continue
+		}
+		p.lines = append(p.lines, yamlLine{number: i, indent: len(text) - len(trimmed), text: trimmed})
+	}
+
+	value, err := p.parseBlock(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, p.errorf(p.lines[p.pos], "unexpected %q", p.lines[p.pos].text)
+	}
+	return json.Marshal(value)
+}
+
+// errorf reports an error at a line of the document
+func (p *yamlParser) errorf(line yamlLine, format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", line.number+1, fmt.Sprintf(format, args...))
+}
+
+// parseBlock reads the node starting at the current line, if it is indented by at least minIndent
+func (p *yamlParser) parseBlock(minIndent int) (interface{}, error) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent < minIndent {
+		return nil, nil
+	}
+
+	line := p.lines[p.pos]
+	switch {
+	case isYAMLSequenceEntry(line.text):
+		return p.parseSequence(line.indent)
+	case yamlKeyEnd(line.text) >= 0:
+		return p.parseMapping(line.indent)
+	}
+	p.pos++
+	return p.parseInline(line.text, minIndent-1, line)
+}
+
+// parseMapping reads the key: value entries indented by indent
+func (p *yamlParser) parseMapping(indent int) (map[string]interface{}, error) {
+	mapping := make(map[string]interface{})
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		end := yamlKeyEnd(line.text)
+		if line.indent > indent || end < 0 {
+			return nil, p.errorf(line, "expected a key, got %q", line.text)
+		}
+
+		key, err := yamlKeyString(line.text[:end])
+		if err != nil {
+			return nil, p.errorf(line, "%v", err)
+		}
+		p.pos++
+
+		value, err := p.parseValue(strings.TrimSpace(line.text[end+1:]), line)
+		if err != nil {
+			return nil, err
+		}
+		mapping[key] = value
+	}
+	return mapping, nil
+}
+
+// parseValue reads the value of the key on line, given the text following its colon
+func (p *yamlParser) parseValue(rest string, line yamlLine) (interface{}, error) {
+	if rest != "" {
+		return p.parseInline(rest, line.indent, line)
+	}
+
+	// Sequences may sit at the same indentation as their key
+	if p.pos < len(p.lines) && p.lines[p.pos].indent == line.indent && isYAMLSequenceEntry(p.lines[p.pos].text) {
+		return p.parseSequence(line.indent)
+	}
+	return p.parseBlock(line.indent + 1)
+}
+
+// parseSequence reads the "- " entries indented by indent
+func (p *yamlParser) parseSequence(indent int) ([]interface{}, error) {
+	items := []interface{}{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent || (line.indent == indent && !isYAMLSequenceEntry(line.text)) {
+			break
+		}
+		if line.indent > indent {
+			return nil, p.errorf(line, "unexpected indentation")
+		}
+
+		var item interface{}
+		var err error
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			item, err = p.parseBlock(indent + 1)
+		} else {
+			// The entry continues as a node indented past its dash, e.g. "- name: id"
+			p.lines[p.pos] = yamlLine{number: line.number, indent: indent + len(line.text) - len(rest), text: rest}
+			item, err = p.parseBlock(p.lines[p.pos].indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// parseInline reads a scalar, flow collection or block scalar starting on line, continued by
+// the lines indented past parentIndent
+func (p *yamlParser) parseInline(text string, parentIndent int, line yamlLine) (interface{}, error) {
+	if text[0] == '|' || text[0] == '>' {
+		return p.parseBlockScalar(text, parentIndent, line), nil
+	}
+
+	// Plain scalars and flow collections may run over several lines, but a scalar is never
+	// followed by a nested key
+	flow := text[0] == '[' || text[0] == '{'
+	for p.pos < len(p.lines) && p.lines[p.pos].indent > parentIndent {
+		if next := p.lines[p.pos]; !flow && yamlKeyEnd(next.text) >= 0 {
+			return nil, p.errorf(next, "unexpected indentation")
+		}
+		text += " " + p.lines[p.pos].text
+		p.pos++
+	}
+
+	value, err := parseYAMLFlow(text)
+	if err != nil {
+		return nil, p.errorf(line, "%v", err)
+	}
+	return value, nil
+}
+
+// parseBlockScalar reads a literal (|) or folded (>) block scalar from the raw lines
+// following its header, which keep their blank lines and anything that looks like a comment
+func (p *yamlParser) parseBlockScalar(header string, parentIndent int, line yamlLine) string {
+	lines := []string{}
+	blockIndent := -1
+	last := line.number
+	for i := line.number + 1; i < len(p.raw); i++ {
+		raw := strings.TrimRight(p.raw[i], " \t\r")
+		trimmed := strings.TrimLeft(raw, " ")
+		if trimmed == "" {
+			lines = append(lines, "")
+			continue
+		}
+
+		indent := len(raw) - len(trimmed)
+		if blockIndent < 0 {
+			blockIndent = indent
+		}
+		if indent <= parentIndent || indent < blockIndent {
+			break
+		}
+		lines = append(lines, raw[blockIndent:])
+		last = i
+	}
+	lines = lines[:last-line.number]
+
+	// Skip the lines of the block that were read as YAML
+	for p.pos < len(p.lines) && p.lines[p.pos].number <= last {
+		p.pos++
+	}
+
+	text := strings.Join(lines, "\n")
+	if header[0] == '>' {
+		// Folded scalars join their lines with spaces, blank lines become newlines
+		var builder strings.Builder
+		for i, line := range lines {
+			switch {
+			case line == "":
+				builder.WriteString("\n")
+			case i > 0 && lines[i-1] != "":
+				builder.WriteString(" ")
+			}
+			builder.WriteString(line)
+		}
+		text = builder.String()
+	}
+
+	if strings.Contains(header, "-") || text == "" {
+		return text
+	}
+	return text + "\n"
+}
+
+// isYAMLSequenceEntry reports whether a line starts a block sequence entry
+func isYAMLSequenceEntry(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// yamlKeyEnd returns the index of the colon ending a line's mapping key, or -1 when the
+// line isn't a key: value entry
+func yamlKeyEnd(text string) int {
+	start := 0
+	switch text[0] {
+	case '[', '{':
+		return -1
+	case '"', '\'':
+		start = closingQuote(text)
+		if start < 0 {
+			return -1
+		}
+		start++
+	}
+
+	for i := start; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			if start > 0 && i != start {
+				return -1
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// yamlKeyString returns a mapping key as text, keys are strings even when they look like numbers
+func yamlKeyString(key string) (string, error) {
+	key = strings.TrimSpace(key)
+	if key != "" && (key[0] == '"' || key[0] == '\'') {
+		reader := &yamlFlowReader{text: key}
+		return reader.quoted()
+	}
+	return key, nil
+}
+
+// closingQuote returns the index of the quote closing the one text starts with, or -1
+func closingQuote(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++ // '' is an escaped quote
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// stripYAMLComment removes a trailing # comment, leaving # inside quoted scalars alone
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" [{,", line[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
+
+// yamlFlowReader reads a scalar or a flow collection such as [a, b] or {type: string}
+type yamlFlowReader struct {
+	text string
+	pos  int
+}
+
+// parseYAMLFlow reads a whole scalar or flow collection
+func parseYAMLFlow(text string) (interface{}, error) {
+	reader := &yamlFlowReader{text: text}
+	value, err := reader.value(false)
+	if err != nil {
+		return nil, err
+	}
+	if reader.skipSpaces(); reader.pos < len(text) {
+		return nil, fmt.Errorf("unexpected %q", text[reader.pos:])
+	}
+	return value, nil
+}
+
+// value reads the next value, inside a flow collection plain scalars end at , ] and }
+func (r *yamlFlowReader) value(inFlow bool) (interface{}, error) {
+	r.skipSpaces()
+	switch r.peek() {
+	case '[':
+		r.pos++
+		items := []interface{}{}
+		for {
+			if r.skipSpaces(); r.peek() == ']' {
+				r.pos++
+				return items, nil
+			}
+			item, err := r.value(true)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			if err := r.separator(']'); err != nil {
+				return nil, err
+			}
+			if r.text[r.pos-1] == ']' {
+				return items, nil
+			}
+		}
+	case '{':
+		r.pos++
+		mapping := make(map[string]interface{})
+		for {
+			if r.skipSpaces(); r.peek() == '}' {
+				r.pos++
+				return mapping, nil
+			}
+			key, err := r.key()
+			if err != nil {
+				return nil, err
+			}
+			var value interface{}
+			if r.skipSpaces(); r.peek() == ':' {
+				r.pos++
+				if value, err = r.value(true); err != nil {
+					return nil, err
+				}
+			}
+			mapping[key] = value
+			if err := r.separator('}'); err != nil {
+				return nil, err
+			}
+			if r.text[r.pos-1] == '}' {
+				return mapping, nil
+			}
+		}
+	case '"', '\'':
+		return r.quoted()
+	case '&', '*', '!':
+		return nil, fmt.Errorf("YAML anchors, aliases and tags aren't supported")
+	}
+
+	start := r.pos
+	for r.pos < len(r.text) && !(inFlow && strings.IndexByte(",]}", r.text[r.pos]) >= 0) {
+		r.pos++
+	}
+	return yamlPlainScalar(strings.TrimSpace(r.text[start:r.pos])), nil
+}
+
+// key reads the key of a flow mapping entry
+func (r *yamlFlowReader) key() (string, error) {
+	if r.peek() == '"' || r.peek() == '\'' {
+		return r.quoted()
+	}
+	start := r.pos
+	for r.pos < len(r.text) && strings.IndexByte(":,}", r.text[r.pos]) < 0 {
+		r.pos++
+	}
+	return strings.TrimSpace(r.text[start:r.pos]), nil
+}
+
+// separator consumes the comma between flow entries or the closing bracket
+func (r *yamlFlowReader) separator(closing byte) error {
+	r.skipSpaces()
+	if c := r.peek(); c == ',' || c == closing {
+		r.pos++
+		return nil
+	}
+	return fmt.Errorf("unterminated flow collection %q", r.text)
+}
+
+// quoted reads a single or double-quoted scalar
+func (r *yamlFlowReader) quoted() (string, error) {
+	end := closingQuote(r.text[r.pos:])
+	if end < 0 {
+		return "", fmt.Errorf("unterminated string %s", r.text[r.pos:])
+	}
+	raw := r.text[r.pos : r.pos+end+1]
+	r.pos += end + 1
+
+	if raw[0] == '\'' {
+		return strings.ReplaceAll(raw[1:len(raw)-1], "''", "'"), nil
+	}
+	// Double-quoted YAML shares its common escapes with JSON
+	var text string
+	if err := json.Unmarshal([]byte(raw), &text); err != nil {
+		return "", fmt.Errorf("unsupported escape in %s", raw)
+	}
+	return text, nil
+}
+
+func (r *yamlFlowReader) peek() byte {
+	if r.pos < len(r.text) {
+		return r.text[r.pos]
+	}
+	return 0
+}
+
+func (r *yamlFlowReader) skipSpaces() {
+	for r.pos < len(r.text) && r.text[r.pos] == ' ' {
+		r.pos++
+	}
+}
+
+// yamlIntPattern and yamlFloatPattern match the plain scalars read as numbers
+var (
+	yamlIntPattern   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloatPattern = regexp.MustCompile(`^[-+]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+)?$`)
+)
+
+// yamlPlainScalar resolves an unquoted scalar to null, a bool, a number or a string
+func yamlPlainScalar(text string) interface{} {
+	switch text {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+
+	if yamlIntPattern.MatchString(text) {
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n
+		}
+	}
+	if yamlFloatPattern.MatchString(text) {
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+	}
+	return text
+}
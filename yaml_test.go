@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func TestJSONToYAML(t *testing.T) {
+	got, err := jsonToYAML([]byte(`{"openapi": "3.0.3", "paths": {"/users/{id}": {"get": {"parameters": [{"name": "id", "required": true}], "tags": [], "responses": {"200": {"content": {}}}}}}, "on": null}`))
+	if err != nil {
+		t.Fatalf("jsonToYAML() error = %v", err)
+	}
+
+	want := `openapi: "3.0.3"
+paths:
+  "/users/{id}":
+    get:
+      parameters:
+        - name: "id"
+          required: true
+      tags: []
+      responses:
+        "200":
+          content: {}
+"on": null
+`
+	if string(got) != want {
+		t.Errorf("jsonToYAML() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestYAMLToJSON(t *testing.T) {
+	got, err := yamlToJSON([]byte(`# Users API
+openapi: 3.0.3
+info:
+  title: "Users # API"
+  version: '1.0'
+  description: |
+    First line
+
+    # kept, not a comment
+  summary: >-
+    Folded
+    onto one line
+tags:
+- users
+- 'it''s'
+limits: {max: 100, ratio: 0.5, open: true, none: ~}
+codes:
+  200: OK
+  "404": [missing, "gone"]
+empty:
+`))
+	if err != nil {
+		t.Fatalf("yamlToJSON() error = %v", err)
+	}
+
+	want := `{"codes":{"200":"OK","404":["missing","gone"]},"empty":null,` +
+		`"info":{"description":"First line\n\n# kept, not a comment\n","summary":"Folded onto one line","title":"Users # API","version":"1.0"},` +
+		`"limits":{"max":100,"none":null,"open":true,"ratio":0.5},"openapi":"3.0.3","tags":["users","it's"]}`
+	if string(got) != want {
+		t.Errorf("yamlToJSON() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestYAMLToJSONRejectsUnsupportedSyntax(t *testing.T) {
+	for _, doc := range []string{
+		"base: &base {type: string}\nuser: *base\n",
+		"a: 1\n---\nb: 2\n",
+		"a:\n  b: 1\n    c: 2\n",
+	} {
+		if _, err := yamlToJSON([]byte(doc)); err == nil {
+			t.Errorf("yamlToJSON(%q) should fail", doc)
+		}
+	}
+}